@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/ucpr/ss-migrate/internal/engine"
 	"github.com/ucpr/ss-migrate/internal/schema"
@@ -14,12 +15,14 @@ import (
 
 func applyCommand(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("usage: ss-migrate apply <schema-file-path> [--dry-run] [--yes]")
+		return fmt.Errorf("usage: ss-migrate apply <schema-file-path> [--dry-run] [--yes] [--force] [--no-backup]")
 	}
 
 	var schemaPath string
 	dryRun := false
 	autoConfirm := false
+	force := false
+	noBackup := false
 
 	// Parse flags and find schema path
 	for _, arg := range args {
@@ -28,6 +31,10 @@ func applyCommand(args []string) error {
 			dryRun = true
 		case "--yes", "-y":
 			autoConfirm = true
+		case "--force":
+			force = true
+		case "--no-backup":
+			noBackup = true
 		default:
 			if !strings.HasPrefix(arg, "-") && schemaPath == "" {
 				schemaPath = arg
@@ -36,7 +43,7 @@ func applyCommand(args []string) error {
 	}
 
 	if schemaPath == "" {
-		return fmt.Errorf("usage: ss-migrate apply <schema-file-path> [--dry-run] [--yes]")
+		return fmt.Errorf("usage: ss-migrate apply <schema-file-path> [--dry-run] [--yes] [--force] [--no-backup]")
 	}
 
 	// Load schema from file
@@ -60,7 +67,7 @@ func applyCommand(args []string) error {
 	}
 
 	// First, generate plan to show what will be changed
-	planner := engine.NewPlanner(sheetClient)
+	planner := engine.NewPlanner(sheetClient, engine.WithForce(force))
 	diffs, err := planner.PlanAll(ctx, schemaConfig)
 	if err != nil {
 		return fmt.Errorf("failed to generate plan: %w", err)
@@ -68,13 +75,23 @@ func applyCommand(args []string) error {
 
 	// Check if there are any changes
 	hasChanges := false
+	hasErrors := false
 	for _, diff := range diffs {
+		if diff.HasChanges || diff.HasErrors() {
+			fmt.Println(diff.Format())
+		}
 		if diff.HasChanges {
 			hasChanges = true
-			fmt.Println(diff.Format())
+		}
+		if diff.HasErrors() {
+			hasErrors = true
 		}
 	}
 
+	if hasErrors {
+		return fmt.Errorf("plan contains blocking errors; nothing was applied")
+	}
+
 	if !hasChanges {
 		fmt.Println("✓ All sheets are already up to date with the schema.")
 		return nil
@@ -102,12 +119,24 @@ func applyCommand(args []string) error {
 		}
 	}
 
-	// Create applier
-	applier := engine.NewApplier(sheetClient, dryRun)
+	// Create applier, which backs up each sheet before changing it and journals each change
+	run := engine.NewRun(engine.DefaultStateDir, time.Now())
+	opts := []engine.Option{engine.WithForce(force), engine.WithRun(run)}
+	if noBackup {
+		opts = append(opts, engine.WithoutBackups())
+	}
+	applier := engine.NewApplier(sheetClient, dryRun, opts...)
 
 	// Apply changes
 	fmt.Println("\nApplying changes...")
 	results, err := applier.ApplyAll(ctx, schemaConfig)
+	switch {
+	case len(run.Backups) > 0:
+		fmt.Printf("\nRun ID: %s (undo with: ss-migrate undo %s, or restore the backups with: ss-migrate rollback %s)\n", run.ID, run.ID, run.ID)
+		fmt.Printf("Delete the backup sheets once they are no longer needed with: ss-migrate rollback %s --discard\n", run.ID)
+	case len(run.Journal) > 0:
+		fmt.Printf("\nRun ID: %s (undo with: ss-migrate undo %s)\n", run.ID, run.ID)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to apply changes: %w", err)
 	}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/engine"
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+)
+
+func checkKeysCommand(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: ss-migrate check-keys <schema-file-path>")
+	}
+
+	schemaPath := args[0]
+
+	// Load schema from file
+	schemaConfig, err := schema.LoadFromFile(schemaPath)
+	if err != nil {
+		return fmt.Errorf("failed to load schema: %w", err)
+	}
+
+	// Validate schema
+	if err := schemaConfig.Validate(); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+
+	// Create context
+	ctx := context.Background()
+
+	// Create sheet client
+	sheetClient, err := sheet.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create sheet client: %w", err)
+	}
+
+	totalViolations := 0
+	for _, resource := range schemaConfig.Resources {
+		if len(resource.PrimaryKey) == 0 {
+			continue
+		}
+
+		spreadsheetID, err := sheet.ExtractSpreadsheetID(resource.Path)
+		if err != nil {
+			return fmt.Errorf("failed to extract spreadsheet ID for resource %s: %w", resource.Name, err)
+		}
+
+		values, err := sheetClient.GetValues(ctx, spreadsheetID, resource.Name)
+		if err != nil {
+			return fmt.Errorf("failed to read sheet %s: %w", resource.Name, err)
+		}
+
+		var headers []string
+		var rows [][]any
+		if len(values) >= resource.HeaderRow {
+			for _, val := range values[resource.HeaderRow-1] {
+				headers = append(headers, fmt.Sprintf("%v", val))
+			}
+			headers = engine.FieldNames(resource, headers)
+			rows = values[resource.HeaderRow:]
+		}
+
+		violations, err := engine.FindKeyViolations(headers, rows, resource.PrimaryKey, resource.HeaderRow+1)
+		if err != nil {
+			return fmt.Errorf("failed to check keys of %s: %w", resource.Name, err)
+		}
+
+		keyName := strings.Join(resource.PrimaryKey, ", ")
+		if len(violations) == 0 {
+			fmt.Printf("✓ %s: primary key (%s) is unique and non-blank\n", resource.Name, keyName)
+			continue
+		}
+
+		fmt.Printf("✗ %s: %d row(s) violate primary key (%s)\n", resource.Name, len(violations), keyName)
+		for _, v := range violations {
+			if v.Blank {
+				fmt.Printf("  row %d: blank key (%s)\n", v.Row, strings.Join(v.Key, ", "))
+				continue
+			}
+			rowNumbers := make([]string, len(v.DuplicateOf))
+			for i, row := range v.DuplicateOf {
+				rowNumbers[i] = fmt.Sprintf("%d", row)
+			}
+			fmt.Printf("  row %d: duplicate key (%s), also in row(s) %s\n",
+				v.Row, strings.Join(v.Key, ", "), strings.Join(rowNumbers, ", "))
+		}
+		totalViolations += len(violations)
+	}
+
+	if totalViolations > 0 {
+		return fmt.Errorf("found %d primary key violation(s)", totalViolations)
+	}
+
+	return nil
+}
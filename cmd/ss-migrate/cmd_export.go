@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/engine"
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+)
+
+func exportCommand(args []string) error {
+	const usage = "usage: ss-migrate export <schema-file-path> [--output <file>]"
+	if len(args) < 1 {
+		return fmt.Errorf(usage)
+	}
+
+	var schemaPath, outputPath string
+
+	// Parse flags and find schema path
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--output" || arg == "-o":
+			if i+1 >= len(args) {
+				return fmt.Errorf(usage)
+			}
+			outputPath = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--output="):
+			outputPath = strings.TrimPrefix(arg, "--output=")
+		default:
+			if !strings.HasPrefix(arg, "-") && schemaPath == "" {
+				schemaPath = arg
+			}
+		}
+	}
+
+	if schemaPath == "" {
+		return fmt.Errorf(usage)
+	}
+
+	// Load schema from file
+	schemaConfig, err := schema.LoadFromFile(schemaPath)
+	if err != nil {
+		return fmt.Errorf("failed to load schema: %w", err)
+	}
+
+	// Validate schema
+	if err := schemaConfig.Validate(); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+
+	// Create context
+	ctx := context.Background()
+
+	// Create sheet client
+	sheetClient, err := sheet.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create sheet client: %w", err)
+	}
+
+	exported := make(map[string][]map[string]any)
+	for _, resource := range schemaConfig.Resources {
+		spreadsheetID, err := sheet.ExtractSpreadsheetID(resource.Path)
+		if err != nil {
+			return fmt.Errorf("failed to extract spreadsheet ID for resource %s: %w", resource.Name, err)
+		}
+
+		values, err := sheetClient.GetUnformattedValues(ctx, spreadsheetID, resource.Name)
+		if err != nil {
+			return fmt.Errorf("failed to read sheet %s: %w", resource.Name, err)
+		}
+
+		records, cellErrors, err := engine.ReadRecords(resource, values)
+		if err != nil {
+			return err
+		}
+		if len(cellErrors) > 0 {
+			for _, cellError := range cellErrors {
+				fmt.Fprintf(os.Stderr, "  %s!%s (%s): %s\n", resource.Name, cellError.Cell(), cellError.Field, cellError.Message)
+			}
+			return fmt.Errorf("%s has %d invalid cell(s); run 'ss-migrate validate' for details", resource.Name, len(cellErrors))
+		}
+
+		rows := make([]map[string]any, 0, len(records))
+		for _, record := range records {
+			rows = append(rows, engine.ExportRecord(resource, record))
+		}
+		exported[resource.Name] = rows
+	}
+
+	data, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode export: %w", err)
+	}
+	data = append(data, '\n')
+
+	if outputPath == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(outputPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	fmt.Printf("Exported %d resource(s) to %s\n", len(exported), outputPath)
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ucpr/ss-migrate/internal/engine"
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+)
+
+func forceUnlockCommand(args []string) error {
+	var schemaPath string
+	autoConfirm := false
+	for _, arg := range args {
+		switch arg {
+		case "--yes", "-y":
+			autoConfirm = true
+		default:
+			if !strings.HasPrefix(arg, "-") && schemaPath == "" {
+				schemaPath = arg
+			}
+		}
+	}
+
+	if schemaPath == "" {
+		return fmt.Errorf("usage: ss-migrate force-unlock <schema-file-path> [--yes]")
+	}
+
+	// Load schema from file
+	schemaConfig, err := schema.LoadFromFile(schemaPath)
+	if err != nil {
+		return fmt.Errorf("failed to load schema: %w", err)
+	}
+
+	spreadsheetIDs, err := engine.SchemaSpreadsheetIDs(schemaConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	sheetClient, err := sheet.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create sheet client: %w", err)
+	}
+	applier := engine.NewApplier(sheetClient, false)
+
+	// Find the locks on each spreadsheet of the schema
+	now := time.Now()
+	locks := map[string][]engine.Lock{}
+	for _, spreadsheetID := range spreadsheetIDs {
+		found, err := applier.Locks(ctx, spreadsheetID)
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %w", spreadsheetID, err)
+		}
+		if len(found) == 0 {
+			continue
+		}
+		locks[spreadsheetID] = found
+		fmt.Printf("Spreadsheet %s:\n", spreadsheetID)
+		for _, lock := range found {
+			status := ""
+			if lock.Expired(now) {
+				status = " (stale)"
+			}
+			fmt.Printf("  Lock %s%s\n", lock, status)
+		}
+	}
+
+	if len(locks) == 0 {
+		fmt.Println("No locks are held.")
+		return nil
+	}
+
+	if !autoConfirm {
+		fmt.Print("\nRemoving a lock while its apply is still running lets another apply interleave with it. Continue? [y/N]: ")
+		reader := bufio.NewReader(os.Stdin)
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Unlock cancelled.")
+			return nil
+		}
+	}
+
+	for _, spreadsheetID := range spreadsheetIDs {
+		if len(locks[spreadsheetID]) == 0 {
+			continue
+		}
+		if err := applier.ForceUnlock(ctx, spreadsheetID, locks[spreadsheetID]); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Removed %d lock(s) from %s\n", len(locks[spreadsheetID]), spreadsheetID)
+	}
+	return nil
+}
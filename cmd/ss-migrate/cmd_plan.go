@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/ucpr/ss-migrate/internal/engine"
 	"github.com/ucpr/ss-migrate/internal/schema"
@@ -11,10 +12,27 @@ import (
 
 func planCommand(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("usage: ss-migrate plan <schema-file-path>")
+		return fmt.Errorf("usage: ss-migrate plan <schema-file-path> [--force]")
 	}
 
-	schemaPath := args[0]
+	var schemaPath string
+	force := false
+
+	// Parse flags and find schema path
+	for _, arg := range args {
+		switch arg {
+		case "--force":
+			force = true
+		default:
+			if !strings.HasPrefix(arg, "-") && schemaPath == "" {
+				schemaPath = arg
+			}
+		}
+	}
+
+	if schemaPath == "" {
+		return fmt.Errorf("usage: ss-migrate plan <schema-file-path> [--force]")
+	}
 
 	// Load schema from file
 	schemaConfig, err := schema.LoadFromFile(schemaPath)
@@ -37,7 +55,7 @@ func planCommand(args []string) error {
 	}
 
 	// Create planner
-	planner := engine.NewPlanner(sheetClient)
+	planner := engine.NewPlanner(sheetClient, engine.WithForce(force))
 
 	// Generate plan for all resources
 	results, err := planner.PlanAll(ctx, schemaConfig)
@@ -47,14 +65,20 @@ func planCommand(args []string) error {
 
 	// Display results
 	hasAnyChanges := false
+	hasErrors := false
 	for _, result := range results {
 		fmt.Println(result.Format())
 		if result.HasChanges {
 			hasAnyChanges = true
 		}
+		if result.HasErrors() {
+			hasErrors = true
+		}
 	}
 
-	if !hasAnyChanges {
+	if hasErrors {
+		fmt.Println("\n✗ The plan contains blocking errors. Resolve them before running 'ss-migrate apply'.")
+	} else if !hasAnyChanges {
 		fmt.Println("\n✓ All sheets are up to date with the schema.")
 	} else {
 		fmt.Println("\nRun 'ss-migrate apply' to apply these changes.")
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/engine"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+)
+
+func rollbackCommand(args []string) error {
+	var runID string
+	autoConfirm := false
+	discard := false
+	for _, arg := range args {
+		switch arg {
+		case "--yes", "-y":
+			autoConfirm = true
+		case "--discard":
+			discard = true
+		default:
+			if !strings.HasPrefix(arg, "-") && runID == "" {
+				runID = arg
+			}
+		}
+	}
+
+	if runID == "" {
+		return fmt.Errorf("usage: ss-migrate rollback <run-id> [--discard] [--yes]")
+	}
+
+	run, err := engine.LoadRun(engine.DefaultStateDir, runID)
+	if err != nil {
+		return err
+	}
+	if len(run.Backups) == 0 {
+		return fmt.Errorf("run %s has no backups", runID)
+	}
+
+	fmt.Printf("Run %s started at %s backed up:\n", run.ID, run.StartedAt.Local().Format("2006-01-02 15:04:05"))
+	for _, backup := range run.Backups {
+		fmt.Printf("  %s (in '%s')\n", backup.Sheet, backup.BackupSheet)
+	}
+
+	prompt := "Restoring replaces the current contents of these sheets."
+	if discard {
+		prompt = "Deleting the backup sheets means the run can no longer be rolled back."
+	}
+	if !autoConfirm {
+		fmt.Printf("\n%s Continue? [y/N]: ", prompt)
+		reader := bufio.NewReader(os.Stdin)
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	ctx := context.Background()
+
+	sheetClient, err := sheet.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create sheet client: %w", err)
+	}
+
+	applier := engine.NewApplier(sheetClient, false)
+	if discard {
+		if err := applier.DiscardBackups(ctx, run); err != nil {
+			return fmt.Errorf("failed to delete the backups of run %s: %w", run.ID, err)
+		}
+		fmt.Printf("✓ Deleted the backup sheets of run %s\n", run.ID)
+		return nil
+	}
+
+	if err := applier.Rollback(ctx, run); err != nil {
+		return fmt.Errorf("failed to roll back run %s: %w", run.ID, err)
+	}
+
+	fmt.Printf("✓ Rolled back run %s. The backup sheets are kept; delete them with: ss-migrate rollback %s --discard\n", run.ID, run.ID)
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/engine"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+)
+
+func undoCommand(args []string) error {
+	var runID string
+	autoConfirm := false
+	for _, arg := range args {
+		switch arg {
+		case "--yes", "-y":
+			autoConfirm = true
+		default:
+			if !strings.HasPrefix(arg, "-") && runID == "" {
+				runID = arg
+			}
+		}
+	}
+
+	// Default to the latest run
+	var run *engine.Run
+	var err error
+	if runID == "" {
+		run, err = engine.LatestRun(engine.DefaultStateDir)
+	} else {
+		run, err = engine.LoadRun(engine.DefaultStateDir, runID)
+	}
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	fmt.Printf("Run %s started at %s applied:\n", run.ID, run.StartedAt.Local().Format("2006-01-02 15:04:05"))
+	for _, entry := range run.Journal {
+		status := ""
+		if entry.Undone {
+			status = " (already undone)"
+		} else {
+			pending++
+		}
+		fmt.Printf("  %s: %s%s\n", entry.Change, entry.Description, status)
+	}
+	if pending == 0 {
+		fmt.Println("Nothing to undo.")
+		return nil
+	}
+
+	if !autoConfirm {
+		fmt.Print("\nUndo these changes, latest first? [y/N]: ")
+		reader := bufio.NewReader(os.Stdin)
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Undo cancelled.")
+			return nil
+		}
+	}
+
+	ctx := context.Background()
+
+	sheetClient, err := sheet.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create sheet client: %w", err)
+	}
+
+	applier := engine.NewApplier(sheetClient, false)
+	skipped, err := applier.Undo(ctx, run)
+	if err != nil {
+		return fmt.Errorf("failed to undo run %s: %w", run.ID, err)
+	}
+
+	if len(skipped) > 0 {
+		fmt.Println("\nThese changes cannot be undone one by one:")
+		for _, change := range skipped {
+			fmt.Printf("  %s\n", change)
+		}
+		if len(run.Backups) > 0 {
+			fmt.Printf("Restore their sheets with: ss-migrate rollback %s\n", run.ID)
+		}
+	}
+
+	fmt.Printf("✓ Undid run %s\n", run.ID)
+	return nil
+}
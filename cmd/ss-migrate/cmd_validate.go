@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ucpr/ss-migrate/internal/engine"
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+)
+
+func validateCommand(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: ss-migrate validate <schema-file-path>")
+	}
+
+	schemaPath := args[0]
+
+	// Load schema from file
+	schemaConfig, err := schema.LoadFromFile(schemaPath)
+	if err != nil {
+		return fmt.Errorf("failed to load schema: %w", err)
+	}
+
+	// Validate schema
+	if err := schemaConfig.Validate(); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+
+	// Create context
+	ctx := context.Background()
+
+	// Create sheet client
+	sheetClient, err := sheet.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create sheet client: %w", err)
+	}
+
+	totalErrors := 0
+	for _, resource := range schemaConfig.Resources {
+		spreadsheetID, err := sheet.ExtractSpreadsheetID(resource.Path)
+		if err != nil {
+			return fmt.Errorf("failed to extract spreadsheet ID for resource %s: %w", resource.Name, err)
+		}
+
+		values, err := sheetClient.GetUnformattedValues(ctx, spreadsheetID, resource.Name)
+		if err != nil {
+			return fmt.Errorf("failed to read sheet %s: %w", resource.Name, err)
+		}
+
+		records, cellErrors, err := engine.ReadRecords(resource, values)
+		if err != nil {
+			return err
+		}
+
+		if len(cellErrors) == 0 {
+			fmt.Printf("✓ %s: %d row(s) valid\n", resource.Name, len(records))
+			continue
+		}
+
+		fmt.Printf("✗ %s: %d invalid cell(s)\n", resource.Name, len(cellErrors))
+		for _, cellError := range cellErrors {
+			fmt.Printf("  %s (%s): %s\n", cellError.Cell(), cellError.Field, cellError.Message)
+		}
+		totalErrors += len(cellErrors)
+	}
+
+	if totalErrors > 0 {
+		return fmt.Errorf("found %d invalid cell(s)", totalErrors)
+	}
+
+	return nil
+}
@@ -14,6 +14,12 @@ func main() {
 	c.RegisterCommand("init", initCommand)
 	c.RegisterCommand("plan", planCommand)
 	c.RegisterCommand("apply", applyCommand)
+	c.RegisterCommand("rollback", rollbackCommand)
+	c.RegisterCommand("undo", undoCommand)
+	c.RegisterCommand("force-unlock", forceUnlockCommand)
+	c.RegisterCommand("check-keys", checkKeysCommand)
+	c.RegisterCommand("validate", validateCommand)
+	c.RegisterCommand("export", exportCommand)
 
 	if err := c.Run(os.Args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
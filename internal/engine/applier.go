@@ -2,24 +2,34 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/ucpr/ss-migrate/internal/schema"
 	"github.com/ucpr/ss-migrate/internal/sheet"
+	"google.golang.org/api/sheets/v4"
 )
 
 // Applier handles applying schema changes to sheets
 type Applier struct {
 	sheetClient *sheet.Client
 	dryRun      bool
+	run         *Run
+	skipBackups bool
+	opts        []Option
 }
 
 // NewApplier creates a new applier instance
-func NewApplier(sheetClient *sheet.Client, dryRun bool) *Applier {
+func NewApplier(sheetClient *sheet.Client, dryRun bool, opts ...Option) *Applier {
+	o := newOptions(opts)
 	return &Applier{
 		sheetClient: sheetClient,
 		dryRun:      dryRun,
+		run:         o.run,
+		skipBackups: o.skipBackups,
+		opts:        opts,
 	}
 }
 
@@ -29,10 +39,23 @@ type ApplyResult struct {
 	Message        string
 	ChangesApplied int
 	Errors         []error
+	Stopped        bool // A change failed, so the changes after it were not applied
 }
 
 // Apply applies the schema changes to the sheet
 func (a *Applier) Apply(ctx context.Context, schemaConfig *schema.Schema, diff *DiffResult) (*ApplyResult, error) {
+	if diff.HasErrors() {
+		errs := make([]error, 0, len(diff.Errors))
+		for _, e := range diff.Errors {
+			errs = append(errs, fmt.Errorf("%s", e))
+		}
+		return &ApplyResult{
+			Success: false,
+			Message: fmt.Sprintf("Blocked by %d error(s) in the plan", len(diff.Errors)),
+			Errors:  errs,
+		}, nil
+	}
+
 	if !diff.HasChanges {
 		return &ApplyResult{
 			Success: true,
@@ -48,24 +71,79 @@ func (a *Applier) Apply(ctx context.Context, schemaConfig *schema.Schema, diff *
 		}, nil
 	}
 
+	// Take the backups before the first change, so that a failed apply can be rolled back as a whole
+	if err := a.backupSheets(ctx, schemaConfig, diff); err != nil {
+		return nil, err
+	}
+
 	result := &ApplyResult{
 		Success: true,
 		Errors:  []error{},
 	}
 
-	// Apply changes
+	// Apply changes, tracking the columns from the layout the plan was made against
+	layout := diff.Layout.clone()
 	for _, change := range diff.Changes {
-		err := a.applyChange(ctx, schemaConfig, change)
+		resource, spreadsheetID, err := changeTarget(schemaConfig, change)
 		if err != nil {
 			result.Errors = append(result.Errors, fmt.Errorf("failed to apply %s: %w", change.Path, err))
 			result.Success = false
-		} else {
-			result.ChangesApplied++
+			result.Stopped = true
+			break
+		}
+
+		// Stop when the columns are not where the plan expects them, as the remaining changes would land on the wrong ones
+		layout, err = a.verifyLayout(ctx, spreadsheetID, resource, layout)
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			result.Success = false
+			result.Stopped = true
+			break
+		}
+
+		// Journal how to undo the change before it alters the sheet, so that it can be undone even when it fails partway
+		if a.run != nil {
+			entry, err := a.journalEntry(ctx, resource, spreadsheetID, change, layout)
+			if err == nil {
+				err = a.recordJournal(entry)
+			}
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("failed to record how to undo %s: %w", change.Path, err))
+				result.Success = false
+				result.Stopped = true
+				break
+			}
+		}
+
+		// Stop at the first failed change, as the changes after it were planned on top of it
+		err = a.applyChange(ctx, resource, spreadsheetID, change, layout)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to apply %s: %w", change.Path, err))
+			result.Success = false
+			result.Stopped = true
+			break
+		}
+		result.ChangesApplied++
+
+		if a.run != nil {
+			if err := a.completeJournal(); err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("applied %s but could not journal it: %w", change.Path, err))
+				result.Success = false
+			}
+		}
+
+		if err := layout.advance(*resource, change); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to track the columns after %s: %w", change.Path, err))
+			result.Success = false
+			result.Stopped = true
+			break
 		}
 	}
 
 	if result.Success {
 		result.Message = fmt.Sprintf("Successfully applied %d changes", result.ChangesApplied)
+	} else if result.Stopped {
+		result.Message = fmt.Sprintf("Applied %d of %d changes, stopped at the first failed change", result.ChangesApplied, len(diff.Changes))
 	} else {
 		result.Message = fmt.Sprintf("Applied %d changes with %d errors", result.ChangesApplied, len(result.Errors))
 	}
@@ -73,20 +151,20 @@ func (a *Applier) Apply(ctx context.Context, schemaConfig *schema.Schema, diff *
 	return result, nil
 }
 
-// applyChange applies a single change to the sheet
-func (a *Applier) applyChange(ctx context.Context, schemaConfig *schema.Schema, change Change) error {
+// changeTarget finds the resource a change targets and the ID of its spreadsheet
+func changeTarget(schemaConfig *schema.Schema, change Change) (*schema.Resource, string, error) {
 	// Parse the path to get sheet name and field name
 	parts := strings.Split(change.Path, ".")
 	
-	// For REORDER changes, path is just the sheet name
-	if change.Type == ChangeTypeReorder {
+	// For REORDER and sheet-level changes, path is just the sheet name
+	if change.Type == ChangeTypeReorder || isSheetChange(change) {
 		if len(parts) < 1 {
-			return fmt.Errorf("invalid change path: %s", change.Path)
+			return nil, "", fmt.Errorf("invalid change path: %s", change.Path)
 		}
 	} else {
 		// For other changes, path should be "sheet.field"
 		if len(parts) < 2 {
-			return fmt.Errorf("invalid change path: %s", change.Path)
+			return nil, "", fmt.Errorf("invalid change path: %s", change.Path)
 		}
 	}
 
@@ -102,42 +180,409 @@ func (a *Applier) applyChange(ctx context.Context, schemaConfig *schema.Schema,
 	}
 
 	if resource == nil {
-		return fmt.Errorf("resource not found for sheet: %s", sheetName)
+		return nil, "", fmt.Errorf("resource not found for sheet: %s", sheetName)
 	}
 
 	// Extract spreadsheet ID
 	spreadsheetID, err := sheet.ExtractSpreadsheetID(resource.Path)
 	if err != nil {
-		return fmt.Errorf("failed to extract spreadsheet ID: %w", err)
+		return nil, "", fmt.Errorf("failed to extract spreadsheet ID: %w", err)
+	}
+
+	return resource, spreadsheetID, nil
+}
+
+// applyChange applies a single change to the sheet, working on the columns of the given layout
+func (a *Applier) applyChange(ctx context.Context, resource *schema.Resource, spreadsheetID string, change Change, layout *ColumnLayout) error {
+	sheetName := resource.Name
+	if isSheetChange(change) {
+		return a.applySheetChange(ctx, spreadsheetID, sheetName, change, resource, layout)
 	}
 
 	switch change.Type {
 	case ChangeTypeAdd:
-		return a.addField(ctx, spreadsheetID, sheetName, change, resource)
+		return a.addField(ctx, spreadsheetID, sheetName, change, resource, layout)
 	case ChangeTypeRemove:
-		return a.removeField(ctx, spreadsheetID, sheetName, change, resource.HeaderRow)
+		return a.removeField(ctx, spreadsheetID, sheetName, change, layout)
 	case ChangeTypeModify:
-		return a.modifyField(ctx, spreadsheetID, sheetName, change, resource.HeaderRow)
+		return a.modifyField(ctx, spreadsheetID, sheetName, change, resource, layout)
 	case ChangeTypeReorder:
-		return a.reorderFields(ctx, spreadsheetID, sheetName, change, resource.HeaderRow)
+		return a.reorderFields(ctx, spreadsheetID, sheetName, resource, layout)
 	default:
 		return fmt.Errorf("unsupported change type: %s", change.Type)
 	}
 }
 
-// addField adds a new field to the sheet in the correct position according to schema order
-func (a *Applier) addField(ctx context.Context, spreadsheetID, sheetName string, change Change, resource *schema.Resource) error {
+// isSheetChange reports whether the change targets the sheet itself rather than a single field
+func isSheetChange(change Change) bool {
+	switch change.NewValue.(type) {
+	case KeyRuleDiff, FormulaDiff, StyleDiff, SheetPropertiesDiff, ConditionalFormatsDiff, TableDiff, NamedRangesDiff, ColumnIDsDiff:
+		return true
+	}
+	return false
+}
+
+// applySheetChange applies a change to sheet-level settings such as the primary key highlight,
+// finding the fields' columns in the given layout
+func (a *Applier) applySheetChange(ctx context.Context, spreadsheetID, sheetName string, change Change, resource *schema.Resource, layout *ColumnLayout) error {
+	switch value := change.NewValue.(type) {
+	case KeyRuleDiff:
+		return a.applyKeyRule(ctx, spreadsheetID, sheetName, value, resource, layout)
+	case FormulaDiff:
+		return a.applyFormula(ctx, spreadsheetID, sheetName, value, resource, layout)
+	case StyleDiff:
+		return a.applyStyle(ctx, spreadsheetID, sheetName, value, resource, layout)
+	case SheetPropertiesDiff:
+		return a.applySheetProperties(ctx, spreadsheetID, sheetName, value)
+	case ConditionalFormatsDiff:
+		return a.applyConditionalFormats(ctx, spreadsheetID, sheetName, value, resource, layout)
+	case TableDiff:
+		return a.applyTable(ctx, spreadsheetID, sheetName, resource, layout)
+	case NamedRangesDiff:
+		return a.applyNamedRanges(ctx, spreadsheetID, sheetName, resource, layout)
+	case ColumnIDsDiff:
+		return a.applyColumnIDs(ctx, spreadsheetID, sheetName, resource, layout)
+	default:
+		return fmt.Errorf("unsupported sheet change: %s", change.Description)
+	}
+}
+
+// applyKeyRule replaces the primary key highlight managed by ss-migrate with one for the current layout
+func (a *Applier) applyKeyRule(ctx context.Context, spreadsheetID, sheetName string, keyDiff KeyRuleDiff, resource *schema.Resource, layout *ColumnLayout) error {
+	sheetMeta, err := a.sheetClient.GetSheet(ctx, spreadsheetID, sheetName)
+	if err != nil {
+		return err
+	}
+
+	requests := []*sheets.Request{}
+
+	// Delete from the highest index so that the remaining indexes stay valid
+	for i := len(sheetMeta.ConditionalFormats) - 1; i >= 0; i-- {
+		if !isKeyRule(sheetMeta.ConditionalFormats[i]) {
+			continue
+		}
+		requests = append(requests, &sheets.Request{
+			DeleteConditionalFormatRule: &sheets.DeleteConditionalFormatRuleRequest{
+				SheetId: sheetMeta.Properties.SheetId,
+				Index:   int64(i),
+			},
+		})
+	}
+
+	if keyDiff.Type != ChangeTypeRemove {
+		columns := make([]int, 0, len(keyDiff.NewFields))
+		for _, key := range keyDiff.NewFields {
+			col, err := layout.column(key)
+			if err != nil {
+				return fmt.Errorf("primary key field %s not found", key)
+			}
+			columns = append(columns, col)
+		}
+
+		requests = append(requests, &sheets.Request{
+			AddConditionalFormatRule: &sheets.AddConditionalFormatRuleRequest{
+				Rule:  NewKeyRule(sheetMeta.Properties.SheetId, keyDiff.NewFields, columns, resource.HeaderRow),
+				Index: 0,
+			},
+		})
+	}
+
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, requests); err != nil {
+		return fmt.Errorf("failed to update primary key highlight: %w", err)
+	}
+
+	if keyDiff.Type == ChangeTypeRemove {
+		fmt.Printf("Removed primary key highlight (%s)\n", strings.Join(keyDiff.OldFields, ", "))
+	} else {
+		fmt.Printf("Highlighted blank and duplicate primary keys (%s)\n", strings.Join(keyDiff.NewFields, ", "))
+	}
+	return nil
+}
+
+// applyFormula writes the formula of a computed column and replaces its read-only protection
+func (a *Applier) applyFormula(ctx context.Context, spreadsheetID, sheetName string, formulaDiff FormulaDiff, resource *schema.Resource, layout *ColumnLayout) error {
 	headerRow := resource.HeaderRow
 	if headerRow == 0 {
 		headerRow = 1
 	}
 
-	// Get current headers
-	headers, err := a.sheetClient.GetHeaders(ctx, spreadsheetID, sheetName, headerRow)
+	sheetMeta, err := a.sheetClient.GetSheet(ctx, spreadsheetID, sheetName)
 	if err != nil {
-		return fmt.Errorf("failed to get headers: %w", err)
+		return err
+	}
+
+	requests := []*sheets.Request{}
+	if protection, ok := FindFormulaProtections(sheetMeta.ProtectedRanges)[formulaDiff.Field]; ok {
+		requests = append(requests, &sheets.Request{
+			DeleteProtectedRange: &sheets.DeleteProtectedRangeRequest{
+				ProtectedRangeId: protection.ProtectedRangeId,
+			},
+		})
 	}
 
+	if formulaDiff.Type == ChangeTypeRemove {
+		if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, requests); err != nil {
+			return fmt.Errorf("failed to remove protection: %w", err)
+		}
+		fmt.Printf("Removed read-only protection of field '%s'\n", formulaDiff.Field)
+		return nil
+	}
+
+	rows, err := a.sheetClient.GetFormulas(ctx, spreadsheetID, sheetName)
+	if err != nil {
+		return err
+	}
+	if len(rows) < headerRow {
+		return fmt.Errorf("header row %d not found", headerRow)
+	}
+
+	column, err := layout.column(formulaDiff.Field)
+	if err != nil {
+		return err
+	}
+
+	// The expected cells are rewritten at once, which also clears formulas that block a spilled result
+	expected := ExpectedFormulas(formulaDiff.Formula, rows[headerRow:], column, headerRow+1)
+	if len(expected) > 0 {
+		values := make([][]any, len(expected))
+		for i, formula := range expected {
+			values[i] = []any{formula}
+		}
+		letter := sheet.ColumnToLetter(column)
+		writeRange := fmt.Sprintf("%s!%s%d:%s%d", sheetName, letter, headerRow+1, letter, headerRow+len(expected))
+		if err := a.sheetClient.UpdateValues(ctx, spreadsheetID, writeRange, values); err != nil {
+			return fmt.Errorf("failed to write formula: %w", err)
+		}
+	}
+
+	requests = append(requests, &sheets.Request{
+		AddProtectedRange: &sheets.AddProtectedRangeRequest{
+			ProtectedRange: NewFormulaProtection(sheetMeta.Properties.SheetId, formulaDiff.Field, column, headerRow),
+		},
+	})
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, requests); err != nil {
+		return fmt.Errorf("failed to protect computed column: %w", err)
+	}
+
+	fmt.Printf("Wrote formula of field '%s' to %d cell(s) in column %s and protected it as read-only\n",
+		formulaDiff.Field, len(expected), sheet.ColumnToLetter(column))
+	return nil
+}
+
+// applyStyle formats the header row or a column's data cells and sets the column width
+func (a *Applier) applyStyle(ctx context.Context, spreadsheetID, sheetName string, styleDiff StyleDiff, resource *schema.Resource, layout *ColumnLayout) error {
+	headerRow := resource.HeaderRow
+	if headerRow == 0 {
+		headerRow = 1
+	}
+
+	sheetID, err := a.sheetClient.GetSheetID(ctx, spreadsheetID, sheetName)
+	if err != nil {
+		return err
+	}
+
+	// The header style covers the header cells of the managed columns, a field style the cells below the header
+	cellRanges := headerStyleRanges(*resource, layout.Names, sheetID, headerRow)
+	column := -1
+	if styleDiff.Field != "" {
+		column, err = layout.column(styleDiff.Field)
+		if err != nil {
+			return err
+		}
+		cellRanges = []*sheets.GridRange{{
+			SheetId:          sheetID,
+			StartRowIndex:    int64(headerRow),
+			StartColumnIndex: int64(column),
+			EndColumnIndex:   int64(column + 1),
+		}}
+	}
+
+	requests := []*sheets.Request{}
+	if format, fields := StyleCellFormat(styleDiff.Style); fields != "" {
+		for _, cellRange := range cellRanges {
+			requests = append(requests, &sheets.Request{
+				RepeatCell: &sheets.RepeatCellRequest{
+					Range:  cellRange,
+					Cell:   &sheets.CellData{UserEnteredFormat: format},
+					Fields: fields,
+				},
+			})
+		}
+	}
+
+	if width := styleDiff.Style.Width; width != nil && column != -1 {
+		dimension := &sheets.DimensionRange{
+			SheetId:    sheetID,
+			Dimension:  "COLUMNS",
+			StartIndex: int64(column),
+			EndIndex:   int64(column + 1),
+		}
+		if width.Auto {
+			requests = append(requests, &sheets.Request{
+				AutoResizeDimensions: &sheets.AutoResizeDimensionsRequest{Dimensions: dimension},
+			})
+		} else {
+			requests = append(requests, &sheets.Request{
+				UpdateDimensionProperties: &sheets.UpdateDimensionPropertiesRequest{
+					Range:      dimension,
+					Properties: &sheets.DimensionProperties{PixelSize: int64(width.Pixels)},
+					Fields:     "pixelSize",
+				},
+			})
+		}
+	}
+
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, requests); err != nil {
+		return fmt.Errorf("failed to apply style: %w", err)
+	}
+
+	if styleDiff.Field == "" {
+		fmt.Printf("Styled header row (%s)\n", strings.Join(styleDiff.Changes, ", "))
+	} else {
+		fmt.Printf("Styled column %s with field '%s' (%s)\n", sheet.ColumnToLetter(column), styleDiff.Field, strings.Join(styleDiff.Changes, ", "))
+	}
+	return nil
+}
+
+// applySheetProperties updates the changed properties of a sheet, such as its frozen rows or tab position
+func (a *Applier) applySheetProperties(ctx context.Context, spreadsheetID, sheetName string, propertiesDiff SheetPropertiesDiff) error {
+	sheetMeta, err := a.sheetClient.GetSheet(ctx, spreadsheetID, sheetName)
+	if err != nil {
+		return err
+	}
+
+	if propertiesDiff.Index != nil {
+		index := moveIndex(int(sheetMeta.Properties.Index), *propertiesDiff.Index)
+		propertiesDiff.Index = &index
+	}
+	request := SheetPropertiesRequest(sheetMeta.Properties.SheetId, propertiesDiff)
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, []*sheets.Request{request}); err != nil {
+		return fmt.Errorf("failed to update sheet properties: %w", err)
+	}
+
+	fmt.Printf("Updated sheet properties (%s)\n", strings.Join(propertiesDiff.Changes, ", "))
+	return nil
+}
+
+// applyConditionalFormats replaces the conditional format rules owned by ss-migrate with those in the schema
+// and records their fingerprints in the sheet's developer metadata. Other rules are kept in place.
+func (a *Applier) applyConditionalFormats(ctx context.Context, spreadsheetID, sheetName string, conditionalDiff ConditionalFormatsDiff, resource *schema.Resource, layout *ColumnLayout) error {
+	sheetMeta, err := a.sheetClient.GetSheet(ctx, spreadsheetID, sheetName)
+	if err != nil {
+		return err
+	}
+	headers := layout.Names
+	sheetID := sheetMeta.Properties.SheetId
+	owned, entry := ownedRuleFingerprints(sheetMeta.DeveloperMetadata)
+
+	requests := []*sheets.Request{}
+
+	// Delete from the highest index so that the remaining indexes stay valid
+	remaining := len(sheetMeta.ConditionalFormats)
+	for i := len(sheetMeta.ConditionalFormats) - 1; i >= 0; i-- {
+		rule := sheetMeta.ConditionalFormats[i]
+		if isKeyRule(rule) || !owned[RuleFingerprint(ruleField(rule, headers), rule)] {
+			continue
+		}
+		requests = append(requests, &sheets.Request{
+			DeleteConditionalFormatRule: &sheets.DeleteConditionalFormatRuleRequest{
+				SheetId: sheetID,
+				Index:   int64(i),
+			},
+		})
+		remaining--
+	}
+
+	fingerprints := []string{}
+	for i, rule := range desiredRules(*resource, headers, sheetID) {
+		if ruleField(rule.Rule, headers) != rule.Field {
+			return fmt.Errorf("field %s not found", rule.Field)
+		}
+		requests = append(requests, &sheets.Request{
+			AddConditionalFormatRule: &sheets.AddConditionalFormatRuleRequest{
+				Rule:  rule.Rule,
+				Index: int64(remaining + i),
+			},
+		})
+		fingerprints = append(fingerprints, rule.Fingerprint)
+	}
+
+	if request := metadataListRequest(sheetID, conditionalFormatsMetadataKey, entry, fingerprints); request != nil {
+		requests = append(requests, request)
+	}
+
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, requests); err != nil {
+		return fmt.Errorf("failed to update conditional formats: %w", err)
+	}
+
+	fmt.Printf("Updated conditional formats (%d added, %d removed)\n", len(conditionalDiff.Added), len(conditionalDiff.Removed))
+	return nil
+}
+
+// applyTable makes the banding and basic filter cover the table's current columns
+func (a *Applier) applyTable(ctx context.Context, spreadsheetID, sheetName string, resource *schema.Resource, layout *ColumnLayout) error {
+	sheetMeta, err := a.sheetClient.GetSheet(ctx, spreadsheetID, sheetName)
+	if err != nil {
+		return err
+	}
+
+	// Earlier changes may have moved the banding and filter, so they are compared again
+	current := compareTable(*resource, layout.Names, sheetMeta)
+	if current == nil {
+		fmt.Printf("Banding and filter already cover the table\n")
+		return nil
+	}
+
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, TableRequests(sheetMeta, *resource, layout.Names, *current)); err != nil {
+		return fmt.Errorf("failed to update banding and filter: %w", err)
+	}
+
+	fmt.Printf("Updated table (%s)\n", strings.Join(current.Changes, ", "))
+	return nil
+}
+
+// applyNamedRanges points the named ranges declared by the schema at the fields' current columns
+func (a *Applier) applyNamedRanges(ctx context.Context, spreadsheetID, sheetName string, resource *schema.Resource, layout *ColumnLayout) error {
+	spreadsheet, err := a.sheetClient.GetSpreadsheet(ctx, spreadsheetID)
+	if err != nil {
+		return err
+	}
+	var sheetMeta *sheets.Sheet
+	for _, tab := range spreadsheet.Sheets {
+		if tab.Properties.Title == sheetName {
+			sheetMeta = tab
+			break
+		}
+	}
+	if sheetMeta == nil {
+		return fmt.Errorf("sheet %s not found", sheetName)
+	}
+	// Sheets shifts named ranges when columns are inserted, moved or deleted, so they are compared again
+	requests, changes, err := NamedRangesRequests(*resource, layout.Names, spreadsheet.NamedRanges, sheetMeta)
+	if err != nil {
+		return err
+	}
+	if len(requests) == 0 {
+		fmt.Printf("Named ranges already cover their columns\n")
+		return nil
+	}
+
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, requests); err != nil {
+		return fmt.Errorf("failed to update named ranges: %w", err)
+	}
+
+	fmt.Printf("Updated named ranges (%s)\n", strings.Join(changes, ", "))
+	return nil
+}
+
+// addField adds a new field to the sheet in the correct position according to schema order
+func (a *Applier) addField(ctx context.Context, spreadsheetID, sheetName string, change Change, resource *schema.Resource, layout *ColumnLayout) error {
+	headerRow := resource.HeaderRow
+	if headerRow == 0 {
+		headerRow = 1
+	}
+	headers := layout.Names
+
 	// Find the field info from the change
 	fieldInfo, ok := change.NewValue.(FieldInfo)
 	if !ok {
@@ -164,31 +609,13 @@ func (a *Applier) addField(ctx context.Context, spreadsheetID, sheetName string,
 		return fmt.Errorf("field %s not found in schema", fieldInfo.Name)
 	}
 
-	// Determine the insert position
-	insertColumnIndex := len(headers) // Default to end
-
-	// Find where to insert based on schema order
-	// Look for the first field after this one that exists in the current headers
-	for i := schemaFieldIndex + 1; i < len(resource.Fields); i++ {
-		nextFieldName := resource.Fields[i].Name
-		// Find this field in current headers
-		for j, header := range headers {
-			if header == nextFieldName {
-				// Insert before this field
-				insertColumnIndex = j
-				break
-			}
-		}
-		// If we found a position, stop searching
-		if insertColumnIndex < len(headers) {
-			break
-		}
-	}
+	// Determine the insert position from the schema order, or the end of the sheet when appending
+	insertColumnIndex := insertIndex(*resource, headers, schemaFieldIndex)
 
 	// If we need to insert in the middle, we need to shift existing columns
 	if insertColumnIndex < len(headers) {
 		// For now, we'll insert at the position by using InsertColumn
-		err = a.sheetClient.InsertColumn(ctx, spreadsheetID, sheetName, insertColumnIndex)
+		err := a.sheetClient.InsertColumn(ctx, spreadsheetID, sheetName, insertColumnIndex)
 		if err != nil {
 			return fmt.Errorf("failed to insert column: %w", err)
 		}
@@ -198,23 +625,57 @@ func (a *Applier) addField(ctx context.Context, spreadsheetID, sheetName string,
 	columnLetter := sheet.ColumnToLetter(insertColumnIndex)
 	cellRange := fmt.Sprintf("%s!%s%d", sheetName, columnLetter, headerRow)
 
-	// Update the header cell
+	// Update the header cell, which shows the field's label when it has one
+	header := fieldInfo.Header
+	if header == "" {
+		header = fieldInfo.Name
+	}
 	values := [][]interface{}{
-		{fieldInfo.Name},
+		{header},
 	}
 
-	err = a.sheetClient.UpdateValues(ctx, spreadsheetID, cellRange, values)
+	err := a.sheetClient.UpdateValues(ctx, spreadsheetID, cellRange, values)
 	if err != nil {
 		return fmt.Errorf("failed to add field header: %w", err)
 	}
 
 	// Apply type formatting to the new column
-	err = a.sheetClient.FormatColumn(ctx, spreadsheetID, sheetName, insertColumnIndex, fieldInfo.Type, fieldInfo.Format)
+	err = a.formatColumn(ctx, spreadsheetID, sheetName, insertColumnIndex, fieldInfo.Type, fieldInfo.Format, fieldInfo.Pattern, fieldInfo.PatternType)
 	if err != nil {
 		// If formatting fails, show warning but don't fail the entire operation
 		fmt.Printf("Warning: Could not apply formatting for new field %s: %v\n", fieldInfo.Name, err)
 	}
 
+	// Tag the column so that it keeps its field when the header cell is edited
+	if err := a.tagColumn(ctx, spreadsheetID, sheetName, insertColumnIndex, resource.Fields[schemaFieldIndex]); err != nil {
+		return err
+	}
+
+	if fieldInfo.Note != "" {
+		if err := a.setHeaderNote(ctx, spreadsheetID, sheetName, headerRow, insertColumnIndex, fieldInfo.Name, fieldInfo.Note); err != nil {
+			return err
+		}
+	}
+
+	// Backfill existing rows with the default in a single update
+	if fieldInfo.Default != nil {
+		rows, err := a.sheetClient.GetValues(ctx, spreadsheetID, sheetName)
+		if err != nil {
+			return fmt.Errorf("failed to read rows to backfill: %w", err)
+		}
+		var dataRows [][]any
+		if len(rows) > headerRow {
+			dataRows = rows[headerRow:]
+		}
+		if values := BackfillValues(fieldInfo.Default, dataRows, headerRow+1); len(values) > 0 {
+			writeRange := fmt.Sprintf("%s!%s%d:%s%d", sheetName, columnLetter, headerRow+1, columnLetter, headerRow+len(values))
+			if err := a.sheetClient.UpdateValues(ctx, spreadsheetID, writeRange, values); err != nil {
+				return fmt.Errorf("failed to backfill default: %w", err)
+			}
+			fmt.Printf("Backfilled %d row(s) of field '%s' with %v\n", countDataRows(dataRows), fieldInfo.Name, fieldInfo.Default)
+		}
+	}
+
 	// If the field should be hidden, hide the column
 	if fieldInfo.Hidden {
 		err = a.sheetClient.HideColumn(ctx, spreadsheetID, sheetName, insertColumnIndex)
@@ -232,17 +693,7 @@ func (a *Applier) addField(ctx context.Context, spreadsheetID, sheetName string,
 }
 
 // removeField removes a field from the sheet by deleting the entire column
-func (a *Applier) removeField(ctx context.Context, spreadsheetID, sheetName string, change Change, headerRow int) error {
-	if headerRow == 0 {
-		headerRow = 1
-	}
-
-	// Get current headers
-	headers, err := a.sheetClient.GetHeaders(ctx, spreadsheetID, sheetName, headerRow)
-	if err != nil {
-		return fmt.Errorf("failed to get headers: %w", err)
-	}
-
+func (a *Applier) removeField(ctx context.Context, spreadsheetID, sheetName string, change Change, layout *ColumnLayout) error {
 	// Find the field info from the change
 	fieldInfo, ok := change.OldValue.(FieldInfo)
 	if !ok {
@@ -250,16 +701,9 @@ func (a *Applier) removeField(ctx context.Context, spreadsheetID, sheetName stri
 	}
 
 	// Find the column index
-	columnIndex := -1
-	for i, header := range headers {
-		if header == fieldInfo.Name {
-			columnIndex = i
-			break
-		}
-	}
-
-	if columnIndex == -1 {
-		return fmt.Errorf("field %s not found", fieldInfo.Name)
+	columnIndex, err := layout.column(fieldInfo.Name)
+	if err != nil {
+		return err
 	}
 
 	// Delete the entire column (all rows)
@@ -274,17 +718,12 @@ func (a *Applier) removeField(ctx context.Context, spreadsheetID, sheetName stri
 }
 
 // modifyField modifies field properties including visibility
-func (a *Applier) modifyField(ctx context.Context, spreadsheetID, sheetName string, change Change, headerRow int) error {
+func (a *Applier) modifyField(ctx context.Context, spreadsheetID, sheetName string, change Change, resource *schema.Resource, layout *ColumnLayout) error {
+	headerRow := resource.HeaderRow
 	if headerRow == 0 {
 		headerRow = 1
 	}
 
-	// Get current headers to find column index
-	headers, err := a.sheetClient.GetHeaders(ctx, spreadsheetID, sheetName, headerRow)
-	if err != nil {
-		return fmt.Errorf("failed to get headers: %w", err)
-	}
-
 	// Find the field diff from the change
 	fieldDiff, ok := change.NewValue.(FieldDiff)
 	if !ok {
@@ -292,16 +731,9 @@ func (a *Applier) modifyField(ctx context.Context, spreadsheetID, sheetName stri
 	}
 
 	// Find the column index
-	columnIndex := -1
-	for i, header := range headers {
-		if header == fieldDiff.Name {
-			columnIndex = i
-			break
-		}
-	}
-
-	if columnIndex == -1 {
-		return fmt.Errorf("field %s not found", fieldDiff.Name)
+	columnIndex, err := layout.column(fieldDiff.Name)
+	if err != nil {
+		return err
 	}
 
 	// Handle hidden status changes
@@ -322,10 +754,32 @@ func (a *Applier) modifyField(ctx context.Context, spreadsheetID, sheetName stri
 		}
 	}
 
+	// Rewrite the header cell when the label changed
+	if fieldDiff.OldHeader != fieldDiff.NewHeader {
+		cellRange := fmt.Sprintf("%s!%s%d", sheetName, sheet.ColumnToLetter(columnIndex), headerRow)
+		if err := a.sheetClient.UpdateValues(ctx, spreadsheetID, cellRange, [][]any{{fieldDiff.NewHeader}}); err != nil {
+			return fmt.Errorf("failed to rewrite header: %w", err)
+		}
+		fmt.Printf("Renamed header of field '%s' from %q to %q\n", fieldDiff.Name, fieldDiff.OldHeader, fieldDiff.NewHeader)
+	}
+
+	// Handle header note changes
+	if fieldDiff.OldNote != fieldDiff.NewNote {
+		if err := a.setHeaderNote(ctx, spreadsheetID, sheetName, headerRow, columnIndex, fieldDiff.Name, fieldDiff.NewNote); err != nil {
+			return err
+		}
+		if fieldDiff.NewNote == "" {
+			fmt.Printf("Removed header note of field '%s'\n", fieldDiff.Name)
+		} else {
+			fmt.Printf("Updated header note of field '%s'\n", fieldDiff.Name)
+		}
+	}
+
 	// Handle type changes by applying number formatting
-	if fieldDiff.OldType != fieldDiff.NewType || fieldDiff.OldFormat != fieldDiff.NewFormat {
+	if fieldDiff.OldType != fieldDiff.NewType || fieldDiff.OldFormat != fieldDiff.NewFormat ||
+		fieldDiff.OldPattern != fieldDiff.NewPattern || fieldDiff.OldPatternType != fieldDiff.NewPatternType {
 		columnLetter := sheet.ColumnToLetter(columnIndex)
-		err = a.sheetClient.FormatColumn(ctx, spreadsheetID, sheetName, columnIndex, fieldDiff.NewType, fieldDiff.NewFormat)
+		err = a.formatColumn(ctx, spreadsheetID, sheetName, columnIndex, fieldDiff.NewType, fieldDiff.NewFormat, fieldDiff.NewPattern, fieldDiff.NewPatternType)
 		if err != nil {
 			// If formatting fails, show warning but don't fail the entire operation
 			fmt.Printf("Warning: Could not apply formatting for field %s (column %s): %v\n", 
@@ -345,82 +799,124 @@ func (a *Applier) modifyField(ctx context.Context, spreadsheetID, sheetName stri
 	return nil
 }
 
-// reorderFields reorders columns to match the schema order
-func (a *Applier) reorderFields(ctx context.Context, spreadsheetID, sheetName string, change Change, headerRow int) error {
+// applyColumnIDs tags the managed columns with the IDs of their fields
+func (a *Applier) applyColumnIDs(ctx context.Context, spreadsheetID, sheetName string, resource *schema.Resource, layout *ColumnLayout) error {
+	headerRow := resource.HeaderRow
 	if headerRow == 0 {
 		headerRow = 1
 	}
-
-	// Get current headers
-	currentHeaders, err := a.sheetClient.GetHeaders(ctx, spreadsheetID, sheetName, headerRow)
+	sheetID, err := a.sheetClient.GetSheetID(ctx, spreadsheetID, sheetName)
 	if err != nil {
-		return fmt.Errorf("failed to get headers: %w", err)
+		return err
+	}
+	grid, err := a.sheetClient.GetGridData(ctx, spreadsheetID, sheetName, fmt.Sprintf("%d:%d", headerRow, headerRow))
+	if err != nil {
+		return fmt.Errorf("failed to get column metadata: %w", err)
 	}
 
-	// Get expected order from change
-	expectedOrder, ok := change.NewValue.([]string)
-	if !ok {
-		return fmt.Errorf("invalid expected order in change")
+	requests, fields := ColumnIDRequests(*resource, sheetID, layout.Names, grid)
+	if len(requests) == 0 {
+		fmt.Printf("Columns already tagged with their field ID\n")
+		return nil
+	}
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, requests); err != nil {
+		return fmt.Errorf("failed to tag columns: %w", err)
 	}
 
-	// Create a map of current positions
-	currentPositions := make(map[string]int)
-	for i, header := range currentHeaders {
-		currentPositions[header] = i
+	fmt.Printf("Tagged %d column(s) with their field ID (%s)\n", len(fields), strings.Join(fields, ", "))
+	return nil
+}
+
+// tagColumn tags a column with the ID of the field it holds
+func (a *Applier) tagColumn(ctx context.Context, spreadsheetID, sheetName string, columnIndex int, field schema.Field) error {
+	sheetID, err := a.sheetClient.GetSheetID(ctx, spreadsheetID, sheetName)
+	if err != nil {
+		return err
 	}
+	request := columnIDRequest(sheetID, columnIndex, field.ColumnID(), nil)
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, []*sheets.Request{request}); err != nil {
+		return fmt.Errorf("failed to tag column: %w", err)
+	}
+	return nil
+}
 
-	// Reorder columns to match expected order
-	// We'll use a simple approach: for each expected field, move it to its correct position
-	for targetIndex, fieldName := range expectedOrder {
-		currentIndex, exists := currentPositions[fieldName]
-		if !exists {
-			// Field doesn't exist in current headers, skip
-			continue
-		}
+// setHeaderNote writes the note of a field's header cell, clearing it when note is empty
+func (a *Applier) setHeaderNote(ctx context.Context, spreadsheetID, sheetName string, headerRow, columnIndex int, field, note string) error {
+	sheetMeta, err := a.sheetClient.GetSheet(ctx, spreadsheetID, sheetName)
+	if err != nil {
+		return err
+	}
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, HeaderNoteRequests(sheetMeta, headerRow, columnIndex, field, note)); err != nil {
+		return fmt.Errorf("failed to update header note: %w", err)
+	}
+	return nil
+}
 
-		if currentIndex != targetIndex {
-			// Need to move this column
-			err = a.sheetClient.MoveColumn(ctx, spreadsheetID, sheetName, currentIndex, targetIndex)
-			if err != nil {
-				return fmt.Errorf("failed to move column %s from %d to %d: %w", 
-					fieldName, currentIndex, targetIndex, err)
-			}
+// formatColumn applies the number format planned for a field, falling back to the type's default format
+func (a *Applier) formatColumn(ctx context.Context, spreadsheetID, sheetName string, columnIndex int, fieldType, format, pattern, patternType string) error {
+	if pattern == "" {
+		return a.sheetClient.FormatColumn(ctx, spreadsheetID, sheetName, columnIndex, fieldType, format)
+	}
+	return a.sheetClient.SetColumnNumberFormat(ctx, spreadsheetID, sheetName, columnIndex, &sheets.NumberFormat{
+		Type:    patternType,
+		Pattern: pattern,
+	})
+}
 
-			// Update positions after the move
-			// When we move a column, we need to update our tracking
-			if currentIndex < targetIndex {
-				// Moving right: columns between source and destination shift left
-				for name, pos := range currentPositions {
-					if pos > currentIndex && pos <= targetIndex {
-						currentPositions[name] = pos - 1
-					}
-				}
-			} else {
-				// Moving left: columns between destination and source shift right
-				for name, pos := range currentPositions {
-					if pos >= targetIndex && pos < currentIndex {
-						currentPositions[name] = pos + 1
-					}
-				}
-			}
-			currentPositions[fieldName] = targetIndex
-			
-			fmt.Printf("Moved field '%s' from column %s to %s\n", 
-				fieldName, 
-				sheet.ColumnToLetter(currentIndex),
-				sheet.ColumnToLetter(targetIndex))
-		}
+// reorderFields reorders columns to match the schema order with the fewest moves, sent in one batch
+func (a *Applier) reorderFields(ctx context.Context, spreadsheetID, sheetName string, resource *schema.Resource, layout *ColumnLayout) error {
+	// Move the fields into schema order within the columns they occupy, leaving other columns in place
+	moves := reorderMoves(*resource, layout.Names)
+	if len(moves) == 0 {
+		fmt.Printf("Fields already match the schema order\n")
+		return nil
+	}
+
+	sheetID, err := a.sheetClient.GetSheetID(ctx, spreadsheetID, sheetName)
+	if err != nil {
+		return err
+	}
+	requests := make([]*sheets.Request, len(moves))
+	for i, move := range moves {
+		requests[i] = sheet.MoveColumnRequest(sheetID, move.From, move.To)
+	}
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, requests); err != nil {
+		return fmt.Errorf("failed to move columns: %w", err)
 	}
 
-	fmt.Printf("Fields reordered to match schema\n")
+	for _, move := range moves {
+		fmt.Printf("Moved field '%s' from column %s to %s\n",
+			move.Field,
+			sheet.ColumnToLetter(move.From),
+			sheet.ColumnToLetter(move.To))
+	}
+	fmt.Printf("Fields reordered to match schema in %d move(s)\n", len(moves))
 	return nil
 }
 
 // ApplyAll applies changes for all resources in the schema
-func (a *Applier) ApplyAll(ctx context.Context, schemaConfig *schema.Schema) ([]*ApplyResult, error) {
+func (a *Applier) ApplyAll(ctx context.Context, schemaConfig *schema.Schema) (results []*ApplyResult, err error) {
+	// Hold the lock on each spreadsheet from planning to the last change, so that no other apply interleaves
+	opts := a.opts
+	var locks heldLocks
+	if !a.dryRun {
+		spreadsheetIDs, err := SchemaSpreadsheetIDs(schemaConfig)
+		if err != nil {
+			return nil, err
+		}
+		locks, err = a.lockSpreadsheets(ctx, spreadsheetIDs)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			err = errors.Join(err, a.releaseLocks(ctx, locks))
+		}()
+		opts = append(slices.Clip(opts), withoutLockCheck())
+	}
+
 	// First, create a planner to get the diffs
-	planner := NewPlanner(a.sheetClient)
-	
+	planner := NewPlanner(a.sheetClient, opts...)
+
 	// Get all diffs
 	diffs, err := planner.PlanAll(ctx, schemaConfig)
 	if err != nil {
@@ -428,7 +924,7 @@ func (a *Applier) ApplyAll(ctx context.Context, schemaConfig *schema.Schema) ([]
 	}
 
 	// Apply each diff
-	results := []*ApplyResult{}
+	results = []*ApplyResult{}
 	for i, diff := range diffs {
 		if !diff.HasChanges {
 			results = append(results, &ApplyResult{
@@ -438,11 +934,35 @@ func (a *Applier) ApplyAll(ctx context.Context, schemaConfig *schema.Schema) ([]
 			continue
 		}
 
+		// Restart the lifetime of the locks before each sheet, so that a long apply keeps them
+		if err := a.renewLocks(ctx, locks); err != nil {
+			return results, err
+		}
+
 		result, err := a.Apply(ctx, schemaConfig, diff)
 		if err != nil {
 			return nil, fmt.Errorf("failed to apply changes for %s: %w", schemaConfig.Resources[i].Name, err)
 		}
 		results = append(results, result)
+
+		// The remaining sheets are left as they are once a change fails, so that the run can be undone as a whole
+		if result.Stopped {
+			for _, resource := range schemaConfig.Resources[i+1:] {
+				results = append(results, &ApplyResult{
+					Success: false,
+					Message: fmt.Sprintf("Skipped %s because an earlier change failed", resource.Name),
+				})
+			}
+			break
+		}
+	}
+
+	// Plan the applied resources again to check that the sheets now match the schema
+	if !a.dryRun {
+		if err := a.renewLocks(ctx, locks); err != nil {
+			return results, err
+		}
+		a.verifyConverged(ctx, planner, schemaConfig, diffs, results)
 	}
 
 	return results, nil
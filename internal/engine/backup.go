@@ -0,0 +1,473 @@
+package engine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+	"google.golang.org/api/sheets/v4"
+)
+
+// DefaultStateDir is the directory, relative to the working directory, where ss-migrate records its runs
+const DefaultStateDir = ".ss-migrate"
+
+// backupMetadataKey is the developer metadata key that marks a backup sheet with the ID of its run
+const backupMetadataKey = "ss-migrate:backup"
+
+// maxSheetNameLength is the longest sheet name Sheets accepts
+const maxSheetNameLength = 100
+
+// Backup is a hidden copy of a sheet taken before a run changed it. A copy does not hold everything a rollback
+// puts back, so the tab's properties, the column ID tags and the sheet's objects are recorded along with it.
+type Backup struct {
+	SpreadsheetID string             `json:"spreadsheetId"`
+	Sheet         string             `json:"sheet"`
+	SheetID       int64              `json:"sheetId"`
+	Hidden        bool               `json:"hidden"` // Whether the sheet was hidden, as the copy always is
+	Index         int64              `json:"index"`
+	TabColorStyle *sheets.ColorStyle `json:"tabColorStyle,omitempty"`
+	RightToLeft   bool               `json:"rightToLeft"`
+	ColumnIDs     []string           `json:"columnIds,omitempty"` // Field ID each column was tagged with, "" for none
+	Objects       *SheetObjects      `json:"objects,omitempty"`
+	BackupSheet   string             `json:"backupSheet"`
+	BackupSheetID int64              `json:"backupSheetId"`
+}
+
+// Run records the backups taken by an apply and the changes it applied, so that it can be rolled back or undone
+type Run struct {
+	ID        string         `json:"id"`
+	StartedAt time.Time      `json:"startedAt"`
+	Backups   []Backup       `json:"backups"`
+	Journal   []JournalEntry `json:"journal"`
+
+	dir string
+}
+
+// NewRun starts the record of an apply, kept under dir. The run ID sorts by start time.
+func NewRun(dir string, now time.Time) *Run {
+	suffix := make([]byte, 2)
+	_, _ = rand.Read(suffix)
+	return &Run{
+		ID:        fmt.Sprintf("%s-%s", now.UTC().Format("20060102-150405"), hex.EncodeToString(suffix)),
+		StartedAt: now.UTC(),
+		Backups:   []Backup{},
+		Journal:   []JournalEntry{},
+		dir:       dir,
+	}
+}
+
+// LoadRun reads the record of a run from dir
+func LoadRun(dir, id string) (*Run, error) {
+	data, err := os.ReadFile(runPath(dir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("run %s not found in %s", id, dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run %s: %w", id, err)
+	}
+
+	run := &Run{dir: dir}
+	if err := json.Unmarshal(data, run); err != nil {
+		return nil, fmt.Errorf("failed to parse run %s: %w", id, err)
+	}
+	return run, nil
+}
+
+// Save writes the record of the run, replacing the previous one
+func (r *Run) Save() error {
+	path := runPath(r.dir, r.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create run directory: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run %s: %w", r.ID, err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write run %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// hasBackup reports whether the run already backed up a sheet
+func (r *Run) hasBackup(spreadsheetID string, sheetID int64) bool {
+	for _, backup := range r.Backups {
+		if backup.SpreadsheetID == spreadsheetID && backup.SheetID == sheetID {
+			return true
+		}
+	}
+	return false
+}
+
+// spreadsheetIDs returns the spreadsheets the run backed up or changed, each once and in sorted order
+func (r *Run) spreadsheetIDs() []string {
+	ids := []string{}
+	for _, backup := range r.Backups {
+		ids = append(ids, backup.SpreadsheetID)
+	}
+	for _, entry := range r.Journal {
+		ids = append(ids, entry.SpreadsheetID)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// sheetOfPath returns the sheet a change path such as "orders.amount" points to
+func sheetOfPath(path string) string {
+	sheetName, _, _ := strings.Cut(path, ".")
+	return sheetName
+}
+
+func runPath(dir, id string) string {
+	return filepath.Join(dir, "runs", id+".json")
+}
+
+// backupSheetName names the backup of a sheet, within the length Sheets allows
+func backupSheetName(runID, sheetName string) string {
+	name := []rune(fmt.Sprintf("ss-migrate backup %s %s", runID, sheetName))
+	if len(name) > maxSheetNameLength {
+		name = name[:maxSheetNameLength]
+	}
+	return string(name)
+}
+
+// backupRequests builds the requests that hide a backup sheet and mark it with the ID of its run
+func backupRequests(backupSheetID int64, runID string) []*sheets.Request {
+	return []*sheets.Request{
+		{
+			UpdateSheetProperties: &sheets.UpdateSheetPropertiesRequest{
+				Properties: &sheets.SheetProperties{SheetId: backupSheetID, Hidden: true},
+				Fields:     "hidden",
+			},
+		},
+		{
+			CreateDeveloperMetadata: &sheets.CreateDeveloperMetadataRequest{
+				DeveloperMetadata: &sheets.DeveloperMetadata{
+					MetadataKey:   backupMetadataKey,
+					MetadataValue: runID,
+					Location:      &sheets.DeveloperMetadataLocation{SheetId: backupSheetID},
+					Visibility:    "DOCUMENT",
+				},
+			},
+		},
+	}
+}
+
+// RestoreRequests builds the requests that put a sheet back as its backup holds it: the grid size and
+// frozen rows and columns, the cells with their values, formulas and formats, the width and visibility of
+// each column, and the tab's position, color, direction and whether it was hidden. The sheet keeps its ID,
+// so references to it stay valid. original holds the current properties of the sheet.
+// Pasting the cells also copies the copy's conditional format rules; restoreObjectsRequests replaces them.
+func RestoreRequests(backup Backup, original, backupProperties *sheets.SheetProperties, columns []*sheets.DimensionProperties) []*sheets.Request {
+	grid := backupProperties.GridProperties
+	if grid == nil {
+		grid = &sheets.GridProperties{}
+	}
+
+	// A tab moved towards the end goes before the tab that has the given index before the move
+	index := backup.Index
+	if original.Index < index {
+		index++
+	}
+
+	requests := []*sheets.Request{
+		{
+			UpdateSheetProperties: &sheets.UpdateSheetPropertiesRequest{
+				Properties: &sheets.SheetProperties{
+					SheetId:       backup.SheetID,
+					Hidden:        backup.Hidden,
+					Index:         index,
+					TabColorStyle: backup.TabColorStyle,
+					RightToLeft:   backup.RightToLeft,
+					GridProperties: &sheets.GridProperties{
+						RowCount:          grid.RowCount,
+						ColumnCount:       grid.ColumnCount,
+						FrozenRowCount:    grid.FrozenRowCount,
+						FrozenColumnCount: grid.FrozenColumnCount,
+						ForceSendFields:   []string{"FrozenRowCount", "FrozenColumnCount"},
+					},
+					ForceSendFields: []string{"Hidden", "Index", "RightToLeft"},
+				},
+				Fields: "hidden,index,tabColorStyle,rightToLeft,gridProperties.rowCount,gridProperties.columnCount,gridProperties.frozenRowCount,gridProperties.frozenColumnCount",
+			},
+		},
+		{
+			CopyPaste: &sheets.CopyPasteRequest{
+				Source: &sheets.GridRange{
+					SheetId:        backup.BackupSheetID,
+					EndRowIndex:    grid.RowCount,
+					EndColumnIndex: grid.ColumnCount,
+				},
+				Destination: &sheets.GridRange{
+					SheetId:        backup.SheetID,
+					EndRowIndex:    grid.RowCount,
+					EndColumnIndex: grid.ColumnCount,
+				},
+				PasteType:        "PASTE_NORMAL",
+				PasteOrientation: "NORMAL",
+			},
+		},
+	}
+
+	for i, column := range columns {
+		if column == nil {
+			continue
+		}
+		requests = append(requests, &sheets.Request{
+			UpdateDimensionProperties: &sheets.UpdateDimensionPropertiesRequest{
+				Range: &sheets.DimensionRange{
+					SheetId:    backup.SheetID,
+					Dimension:  "COLUMNS",
+					StartIndex: int64(i),
+					EndIndex:   int64(i + 1),
+				},
+				Properties: &sheets.DimensionProperties{
+					PixelSize:       column.PixelSize,
+					HiddenByUser:    column.HiddenByUser,
+					ForceSendFields: []string{"HiddenByUser"},
+				},
+				Fields: "pixelSize,hiddenByUser",
+			},
+		})
+	}
+	return requests
+}
+
+// restoreColumnIDTagsRequests builds the requests that tag each column of a restored sheet with the field ID
+// it had when it was backed up, and remove the tags of the columns that had none. columns are the current
+// properties of the sheet's columns.
+func restoreColumnIDTagsRequests(sheetID int64, ids []string, columns []*sheets.DimensionProperties) []*sheets.Request {
+	entries := columnIDEntries(&sheets.GridData{ColumnMetadata: columns})
+	requests := []*sheets.Request{}
+	for i := range max(len(ids), len(entries)) {
+		var entry *sheets.DeveloperMetadata
+		if i < len(entries) {
+			entry = entries[i]
+		}
+		id := columnID(ids, i)
+		switch {
+		case id == "" && entry != nil:
+			requests = append(requests, &sheets.Request{
+				DeleteDeveloperMetadata: &sheets.DeleteDeveloperMetadataRequest{DataFilter: metadataIDFilter(entry.MetadataId)},
+			})
+		case id != "" && (entry == nil || entry.MetadataValue != id):
+			requests = append(requests, columnIDRequest(sheetID, i, id, entry))
+		}
+	}
+	return requests
+}
+
+// isDestructive reports whether a change loses cell contents: removing a field deletes its column, and writing
+// a formula replaces the cells that do not hold it. The other changes can be reversed from the journal alone.
+func isDestructive(change Change) bool {
+	if formulaDiff, ok := change.NewValue.(FormulaDiff); ok {
+		return len(formulaDiff.Stale)+len(formulaDiff.Overwritten) > 0
+	}
+	return change.Type == ChangeTypeRemove && !isSheetChange(change)
+}
+
+// backupSheets copies the sheets that a diff changes destructively into hidden backup sheets before any change
+// is applied, since a copy of a whole sheet counts against the spreadsheet's cell limit. Sheets that do not
+// exist yet have nothing to back up. Nothing is done without a run or in dry-run mode.
+func (a *Applier) backupSheets(ctx context.Context, schemaConfig *schema.Schema, diff *DiffResult) error {
+	if a.run == nil || a.skipBackups || a.dryRun {
+		return nil
+	}
+
+	done := make(map[string]bool)
+	for _, change := range diff.Changes {
+		if !isDestructive(change) {
+			continue
+		}
+		sheetName := sheetOfPath(change.Path)
+		if done[sheetName] {
+			continue
+		}
+		done[sheetName] = true
+
+		for _, resource := range schemaConfig.Resources {
+			if resource.Name != sheetName {
+				continue
+			}
+			spreadsheetID, err := sheet.ExtractSpreadsheetID(resource.Path)
+			if err != nil {
+				return fmt.Errorf("failed to extract spreadsheet ID: %w", err)
+			}
+			if err := a.backupSheet(ctx, spreadsheetID, sheetName); err != nil {
+				return fmt.Errorf("failed to back up %s: %w", sheetName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// backupSheet duplicates a sheet into a hidden sheet at the end of the spreadsheet and records it in the run
+func (a *Applier) backupSheet(ctx context.Context, spreadsheetID, sheetName string) error {
+	spreadsheet, err := a.sheetClient.GetSpreadsheet(ctx, spreadsheetID)
+	if err != nil {
+		return err
+	}
+	var sheetMeta *sheets.Sheet
+	for _, tab := range spreadsheet.Sheets {
+		if tab.Properties.Title == sheetName {
+			sheetMeta = tab
+			break
+		}
+	}
+	if sheetMeta == nil || a.run.hasBackup(spreadsheetID, sheetMeta.Properties.SheetId) {
+		return nil
+	}
+	source := sheetMeta.Properties
+
+	columns, err := a.sheetClient.GetColumnProperties(ctx, spreadsheetID, source.SheetId)
+	if err != nil {
+		return err
+	}
+
+	name := backupSheetName(a.run.ID, sheetName)
+	properties, err := a.sheetClient.DuplicateSheet(ctx, spreadsheetID, source.SheetId, len(spreadsheet.Sheets), name)
+	if err != nil {
+		return err
+	}
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, backupRequests(properties.SheetId, a.run.ID)); err != nil {
+		return fmt.Errorf("failed to hide backup sheet: %w", err)
+	}
+
+	a.run.Backups = append(a.run.Backups, Backup{
+		SpreadsheetID: spreadsheetID,
+		Sheet:         sheetName,
+		SheetID:       source.SheetId,
+		Hidden:        source.Hidden,
+		Index:         source.Index,
+		TabColorStyle: source.TabColorStyle,
+		RightToLeft:   source.RightToLeft,
+		ColumnIDs:     columnIDs(&sheets.GridData{ColumnMetadata: columns}),
+		Objects:       captureObjects(sheetMeta, spreadsheet.NamedRanges, allObjectKinds...),
+		BackupSheet:   properties.Title,
+		BackupSheetID: properties.SheetId,
+	})
+	if err := a.run.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Backed up %s to hidden sheet '%s'\n", sheetName, properties.Title)
+	return nil
+}
+
+// Rollback restores the sheets backed up by a run, latest backup first. It holds the lock on their
+// spreadsheets meanwhile, so that it cannot interleave with an apply.
+func (a *Applier) Rollback(ctx context.Context, run *Run) (err error) {
+	locks, err := a.lockSpreadsheets(ctx, run.spreadsheetIDs())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, a.releaseLocks(ctx, locks))
+	}()
+
+	for i := len(run.Backups) - 1; i >= 0; i-- {
+		backup := run.Backups[i]
+		if err := a.restoreBackup(ctx, backup); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", backup.Sheet, err)
+		}
+		fmt.Printf("Restored %s from '%s'\n", backup.Sheet, backup.BackupSheet)
+	}
+	return nil
+}
+
+// DiscardBackups deletes the backup sheets of a run and removes them from its record, so that the run can
+// no longer be rolled back. Backup sheets that were already deleted are only removed from the record.
+func (a *Applier) DiscardBackups(ctx context.Context, run *Run) (err error) {
+	locks, err := a.lockSpreadsheets(ctx, run.spreadsheetIDs())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, a.releaseLocks(ctx, locks))
+	}()
+
+	for len(run.Backups) > 0 {
+		backup := run.Backups[len(run.Backups)-1]
+		if err := a.discardBackup(ctx, backup); err != nil {
+			return fmt.Errorf("failed to delete backup sheet '%s': %w", backup.BackupSheet, err)
+		}
+		run.Backups = run.Backups[:len(run.Backups)-1]
+		if err := run.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted backup sheet '%s'\n", backup.BackupSheet)
+	}
+	return nil
+}
+
+// discardBackup deletes a backup sheet unless it no longer exists
+func (a *Applier) discardBackup(ctx context.Context, backup Backup) error {
+	spreadsheet, err := a.sheetClient.GetSpreadsheet(ctx, backup.SpreadsheetID)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(spreadsheet.Sheets, func(tab *sheets.Sheet) bool { return tab.Properties.SheetId == backup.BackupSheetID }) {
+		return nil
+	}
+	return a.sheetClient.BatchUpdate(ctx, backup.SpreadsheetID, []*sheets.Request{
+		{DeleteSheet: &sheets.DeleteSheetRequest{SheetId: backup.BackupSheetID}},
+	})
+}
+
+// restoreBackup puts a sheet back as its backup holds it. The cells and the tab are restored first, then
+// the column ID tags and the sheet's objects, which are compared with the sheet as the first step left it.
+func (a *Applier) restoreBackup(ctx context.Context, backup Backup) error {
+	spreadsheet, err := a.sheetClient.GetSpreadsheet(ctx, backup.SpreadsheetID)
+	if err != nil {
+		return err
+	}
+	var original, copied *sheets.SheetProperties
+	for _, tab := range spreadsheet.Sheets {
+		switch tab.Properties.SheetId {
+		case backup.SheetID:
+			original = tab.Properties
+		case backup.BackupSheetID:
+			copied = tab.Properties
+		}
+	}
+	if original == nil {
+		return fmt.Errorf("sheet %s no longer exists", backup.Sheet)
+	}
+	if copied == nil {
+		return fmt.Errorf("backup sheet '%s' no longer exists", backup.BackupSheet)
+	}
+
+	columns, err := a.sheetClient.GetColumnProperties(ctx, backup.SpreadsheetID, backup.BackupSheetID)
+	if err != nil {
+		return err
+	}
+	if err := a.sheetClient.BatchUpdate(ctx, backup.SpreadsheetID, RestoreRequests(backup, original, copied, columns)); err != nil {
+		return fmt.Errorf("failed to restore sheet: %w", err)
+	}
+
+	sheetMeta, namedRanges, err := a.sheetByID(ctx, backup.SpreadsheetID, backup.SheetID)
+	if err != nil {
+		return err
+	}
+	if columns, err = a.sheetClient.GetColumnProperties(ctx, backup.SpreadsheetID, backup.SheetID); err != nil {
+		return err
+	}
+	requests := restoreColumnIDTagsRequests(backup.SheetID, backup.ColumnIDs, columns)
+	if backup.Objects != nil {
+		requests = append(requests, restoreObjectsRequests(sheetMeta, namedRanges, backup.Objects)...)
+	}
+	if err := a.sheetClient.BatchUpdate(ctx, backup.SpreadsheetID, requests); err != nil {
+		return fmt.Errorf("failed to restore the objects of the sheet: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestNewRun(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 5, 0, time.UTC)
+	run := NewRun(t.TempDir(), now)
+
+	if !regexp.MustCompile(`^20261018-093005-[0-9a-f]{4}$`).MatchString(run.ID) {
+		t.Errorf("unexpected run ID %q", run.ID)
+	}
+	if other := NewRun(t.TempDir(), now); other.ID == run.ID {
+		t.Errorf("runs started at the same second should get different IDs, got %q twice", run.ID)
+	}
+}
+
+func TestRunSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	run := NewRun(dir, time.Date(2026, 10, 18, 9, 30, 5, 0, time.UTC))
+	run.Backups = append(run.Backups, Backup{
+		SpreadsheetID: "abc",
+		Sheet:         "orders",
+		SheetID:       10,
+		BackupSheet:   "ss-migrate backup " + run.ID + " orders",
+		ColumnIDs:     []string{"id", "", "amount"},
+		BackupSheetID: 99,
+	})
+	if err := run.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	loaded, err := LoadRun(dir, run.ID)
+	if err != nil {
+		t.Fatalf("LoadRun() error: %v", err)
+	}
+	if loaded.ID != run.ID || !loaded.StartedAt.Equal(run.StartedAt) || len(loaded.Backups) != 1 || !reflect.DeepEqual(loaded.Backups[0], run.Backups[0]) {
+		t.Errorf("LoadRun() = %+v, want %+v", loaded, run)
+	}
+	if !loaded.hasBackup("abc", 10) || loaded.hasBackup("abc", 11) {
+		t.Error("hasBackup() should only report the backed up sheet")
+	}
+
+	if _, err := LoadRun(dir, "missing"); err == nil || !strings.Contains(err.Error(), "run missing not found") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestBackupSheetName(t *testing.T) {
+	if got := backupSheetName("20261018-093005-ab12", "orders"); got != "ss-migrate backup 20261018-093005-ab12 orders" {
+		t.Errorf("backupSheetName() = %q", got)
+	}
+	long := backupSheetName("20261018-093005-ab12", strings.Repeat("注", 120))
+	if len([]rune(long)) != maxSheetNameLength {
+		t.Errorf("expected the name to be cut to %d characters, got %d", maxSheetNameLength, len([]rune(long)))
+	}
+}
+
+func TestRestoreRequests(t *testing.T) {
+	backup := Backup{SheetID: 10, BackupSheetID: 99, Hidden: false, Index: 2, RightToLeft: true,
+		TabColorStyle: &sheets.ColorStyle{RgbColor: &sheets.Color{Red: 1}}}
+	original := &sheets.SheetProperties{SheetId: 10, Index: 0}
+	properties := &sheets.SheetProperties{
+		SheetId: 99,
+		Hidden:  true,
+		GridProperties: &sheets.GridProperties{
+			RowCount:       1000,
+			ColumnCount:    5,
+			FrozenRowCount: 1,
+		},
+	}
+	columns := []*sheets.DimensionProperties{
+		{PixelSize: 100},
+		{PixelSize: 80, HiddenByUser: true},
+	}
+
+	requests := RestoreRequests(backup, original, properties, columns)
+	if len(requests) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(requests))
+	}
+
+	update := requests[0].UpdateSheetProperties
+	if update == nil || update.Properties.SheetId != 10 || update.Properties.Hidden ||
+		update.Properties.GridProperties.ColumnCount != 5 || update.Properties.GridProperties.FrozenRowCount != 1 {
+		t.Errorf("expected the sheet to be resized and shown, got %+v", requests[0].UpdateSheetProperties)
+	}
+	if update.Properties.Index != 3 || !update.Properties.RightToLeft || update.Properties.TabColorStyle != backup.TabColorStyle ||
+		!strings.Contains(update.Fields, "index,tabColorStyle,rightToLeft") {
+		t.Errorf("expected the tab to move back after the sheet now at index 2 with its color and direction, got %+v", update)
+	}
+	if moved := RestoreRequests(backup, &sheets.SheetProperties{SheetId: 10, Index: 4}, properties, columns); moved[0].UpdateSheetProperties.Properties.Index != 2 {
+		t.Errorf("expected a tab moved towards the start to get its index as is, got %d", moved[0].UpdateSheetProperties.Properties.Index)
+	}
+
+	paste := requests[1].CopyPaste
+	if paste == nil || paste.Source.SheetId != 99 || paste.Destination.SheetId != 10 ||
+		paste.Destination.EndRowIndex != 1000 || paste.Destination.EndColumnIndex != 5 || paste.PasteType != "PASTE_NORMAL" {
+		t.Errorf("expected the backup cells to be pasted over the sheet, got %+v", requests[1].CopyPaste)
+	}
+
+	hidden := requests[3].UpdateDimensionProperties
+	if hidden == nil || hidden.Range.SheetId != 10 || hidden.Range.StartIndex != 1 || !hidden.Properties.HiddenByUser || hidden.Properties.PixelSize != 80 {
+		t.Errorf("expected column B to be hidden again, got %+v", requests[3].UpdateDimensionProperties)
+	}
+}
+
+func TestRestoreColumnIDTagsRequests(t *testing.T) {
+	tag := func(id int64, value string) *sheets.DimensionProperties {
+		return &sheets.DimensionProperties{DeveloperMetadata: []*sheets.DeveloperMetadata{{MetadataId: id, MetadataKey: columnIDMetadataKey, MetadataValue: value}}}
+	}
+	// amount was removed and note moved into its column, and a column was inserted at the end
+	columns := []*sheets.DimensionProperties{tag(1, "id"), tag(3, "note"), {}, tag(4, "added")}
+
+	requests := restoreColumnIDTagsRequests(10, []string{"id", "amount", "note"}, columns)
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(requests))
+	}
+	if update := requests[0].UpdateDeveloperMetadata; update == nil || update.DataFilters[0].DeveloperMetadataLookup.MetadataId != 3 || update.DeveloperMetadata.MetadataValue != "amount" {
+		t.Errorf("expected column B to be tagged with amount again, got %+v", requests[0])
+	}
+	if create := requests[1].CreateDeveloperMetadata; create == nil || create.DeveloperMetadata.MetadataValue != "note" ||
+		create.DeveloperMetadata.Location.DimensionRange.StartIndex != 2 {
+		t.Errorf("expected column C to be tagged with note, got %+v", requests[1])
+	}
+	if remove := requests[2].DeleteDeveloperMetadata; remove == nil || remove.DataFilter.DeveloperMetadataLookup.MetadataId != 4 {
+		t.Errorf("expected the tag of column D to be removed, got %+v", requests[2])
+	}
+}
+
+func TestIsDestructive(t *testing.T) {
+	tests := []struct {
+		name   string
+		change Change
+		want   bool
+	}{
+		{name: "removed field", change: Change{Type: ChangeTypeRemove, OldValue: FieldInfo{Name: "amount"}}, want: true},
+		{name: "added field", change: Change{Type: ChangeTypeAdd, NewValue: FieldInfo{Name: "amount"}}},
+		{name: "modified field", change: Change{Type: ChangeTypeModify, NewValue: FieldDiff{Name: "amount"}}},
+		{name: "formula over stale cells", change: Change{Type: ChangeTypeModify, NewValue: FormulaDiff{Type: ChangeTypeModify, Stale: []string{"C2"}}}, want: true},
+		{name: "formula over values", change: Change{Type: ChangeTypeAdd, NewValue: FormulaDiff{Type: ChangeTypeAdd, Overwritten: []string{"C3"}}}, want: true},
+		{name: "formula protection only", change: Change{Type: ChangeTypeModify, NewValue: FormulaDiff{Type: ChangeTypeModify, Protect: true}}},
+		{name: "formula no longer computed", change: Change{Type: ChangeTypeRemove, NewValue: FormulaDiff{Type: ChangeTypeRemove}}},
+		{name: "removed conditional formats", change: Change{Type: ChangeTypeRemove, NewValue: ConditionalFormatsDiff{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDestructive(tt.change); got != tt.want {
+				t.Errorf("isDestructive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSheetOfPath(t *testing.T) {
+	for path, want := range map[string]string{"orders.amount": "orders", "orders": "orders"} {
+		if got := sheetOfPath(path); got != want {
+			t.Errorf("sheetOfPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
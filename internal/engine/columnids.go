@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+// columnIDMetadataKey is the developer metadata key under which ss-migrate tags a column with the ID
+// of the field it holds. The tag moves with the column, so it identifies the field even after the header
+// cell has been edited by hand.
+const columnIDMetadataKey = "ss-migrate:column-id"
+
+// ColumnIDsDiff represents managed columns that are not yet tagged with the ID of their field
+type ColumnIDsDiff struct {
+	Type   ChangeType
+	Fields []string // Fields whose column is untagged or tagged with another ID
+}
+
+// columnIDEntries returns the ID tag of each column in grid data, nil for columns without one
+func columnIDEntries(grid *sheets.GridData) []*sheets.DeveloperMetadata {
+	if grid == nil {
+		return nil
+	}
+	entries := make([]*sheets.DeveloperMetadata, len(grid.ColumnMetadata))
+	for i, column := range grid.ColumnMetadata {
+		if column == nil {
+			continue
+		}
+		for _, entry := range column.DeveloperMetadata {
+			if entry.MetadataKey == columnIDMetadataKey {
+				entries[i] = entry
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// columnIDs returns the field ID each column is tagged with, "" for columns without one
+func columnIDs(grid *sheets.GridData) []string {
+	entries := columnIDEntries(grid)
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		if entry != nil {
+			ids[i] = entry.MetadataValue
+		}
+	}
+	return ids
+}
+
+// columnID returns the ID a column is tagged with, "" when it has none
+func columnID(ids []string, column int) string {
+	if column < len(ids) {
+		return ids[column]
+	}
+	return ""
+}
+
+// columnFieldNames maps the columns of a sheet to the names of the fields they hold. A column tagged
+// with a field's ID holds that field whatever its header shows; the other columns are matched by header.
+// Each field is taken by at most one tagged column. Columns with a blank header are never matched.
+func columnFieldNames(resource schema.Resource, headers, ids []string) []string {
+	byID := make(map[string]string, len(resource.Fields))
+	for _, field := range resource.Fields {
+		byID[field.ColumnID()] = field.Name
+	}
+
+	names := FieldNames(resource, headers)
+	tagged := make(map[string]bool)
+	for i, id := range ids {
+		if i >= len(headers) || headers[i] == "" {
+			continue
+		}
+		if name, ok := byID[id]; ok && !tagged[name] {
+			names[i] = name
+			tagged[name] = true
+		}
+	}
+	if len(tagged) == 0 {
+		return names
+	}
+
+	// Untagged columns cannot take a field that a tagged column already holds. They keep their header,
+	// suffixed as name_2, name_3, ... when it is taken by a field or another column.
+	taken := make(map[string]bool, len(resource.Fields)+len(names))
+	for _, field := range resource.Fields {
+		taken[field.Name] = true
+	}
+	for _, name := range names {
+		taken[name] = true
+	}
+	for i, name := range names {
+		if !tagged[name] || byID[columnID(ids, i)] == name {
+			continue
+		}
+		names[i] = headers[i]
+		for n := 2; taken[names[i]]; n++ {
+			names[i] = fmt.Sprintf("%s_%d", headers[i], n)
+		}
+		taken[names[i]] = true
+	}
+	return names
+}
+
+// compareColumnIDs lists the fields whose existing column is not tagged with the field's ID.
+// Added columns are tagged when they are inserted, so only fields read from the sheet are checked.
+func compareColumnIDs(resource schema.Resource, currentFields []FieldInfo) *ColumnIDsDiff {
+	ids := make(map[string]string, len(currentFields))
+	for _, field := range currentFields {
+		ids[field.Name] = field.ID
+	}
+
+	fields := []string{}
+	for _, field := range resource.Fields {
+		if id, ok := ids[field.Name]; ok && id != field.ColumnID() {
+			fields = append(fields, field.Name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ColumnIDsDiff{Type: ChangeTypeModify, Fields: fields}
+}
+
+// columnIDRequest builds the request that tags a column with a field ID, replacing the value of
+// the column's existing tag when entry is not nil
+func columnIDRequest(sheetID int64, column int, id string, entry *sheets.DeveloperMetadata) *sheets.Request {
+	if entry != nil {
+		return &sheets.Request{
+			UpdateDeveloperMetadata: &sheets.UpdateDeveloperMetadataRequest{
+				DataFilters:       []*sheets.DataFilter{metadataIDFilter(entry.MetadataId)},
+				DeveloperMetadata: &sheets.DeveloperMetadata{MetadataValue: id},
+				Fields:            "metadataValue",
+			},
+		}
+	}
+	return &sheets.Request{
+		CreateDeveloperMetadata: &sheets.CreateDeveloperMetadataRequest{
+			DeveloperMetadata: &sheets.DeveloperMetadata{
+				MetadataKey:   columnIDMetadataKey,
+				MetadataValue: id,
+				Location: &sheets.DeveloperMetadataLocation{
+					DimensionRange: &sheets.DimensionRange{
+						SheetId:    sheetID,
+						Dimension:  "COLUMNS",
+						StartIndex: int64(column),
+						EndIndex:   int64(column + 1),
+					},
+				},
+				Visibility: "DOCUMENT",
+			},
+		},
+	}
+}
+
+// ColumnIDRequests builds the requests that tag each managed column with the ID of its field.
+// names are the columns mapped to field names and grid holds the columns' metadata.
+// It also returns the fields whose column gets tagged.
+func ColumnIDRequests(resource schema.Resource, sheetID int64, names []string, grid *sheets.GridData) ([]*sheets.Request, []string) {
+	entries := columnIDEntries(grid)
+	columns := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+
+	requests := []*sheets.Request{}
+	fields := []string{}
+	for _, field := range resource.Fields {
+		column, ok := columns[field.Name]
+		if !ok {
+			continue
+		}
+		var entry *sheets.DeveloperMetadata
+		if column < len(entries) {
+			entry = entries[column]
+		}
+		if entry != nil && entry.MetadataValue == field.ColumnID() {
+			continue
+		}
+		requests = append(requests, columnIDRequest(sheetID, column, field.ColumnID(), entry))
+		fields = append(fields, field.Name)
+	}
+	return requests, fields
+}
+
+func describeColumnIDsDiff(d *ColumnIDsDiff) string {
+	return fmt.Sprintf("Tag %d column(s) with their field ID (%s)", len(d.Fields), strings.Join(d.Fields, ", "))
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+// taggedGrid builds header row grid data whose columns are tagged with the given IDs, "" leaving a column untagged
+func taggedGrid(ids ...string) *sheets.GridData {
+	grid := &sheets.GridData{}
+	for i, id := range ids {
+		column := &sheets.DimensionProperties{}
+		if id != "" {
+			column.DeveloperMetadata = []*sheets.DeveloperMetadata{
+				{MetadataId: int64(100 + i), MetadataKey: "other", MetadataValue: "x"},
+				{MetadataId: int64(i + 1), MetadataKey: columnIDMetadataKey, MetadataValue: id},
+			}
+		}
+		grid.ColumnMetadata = append(grid.ColumnMetadata, column)
+	}
+	return grid
+}
+
+func TestColumnFieldNames(t *testing.T) {
+	resource := labeledResource()
+	id := resource.Fields[0].ColumnID()
+	customer := resource.Fields[1].ColumnID()
+	region := resource.Fields[2].ColumnID()
+
+	tests := []struct {
+		name    string
+		headers []string
+		ids     []string
+		want    []string
+	}{
+		{
+			name:    "untagged columns match by header",
+			headers: []string{"id", "Customer Name", "memo"},
+			ids:     nil,
+			want:    []string{"id", "customer_name", "memo"},
+		},
+		{
+			name:    "header edited by hand",
+			headers: []string{"id", "Client", "memo"},
+			ids:     []string{id, customer, ""},
+			want:    []string{"id", "customer_name", "memo"},
+		},
+		{
+			name:    "tag wins over a header showing another field",
+			headers: []string{"id", "region", "Customer Name"},
+			ids:     []string{id, customer, region},
+			want:    []string{"id", "customer_name", "region"},
+		},
+		{
+			name:    "untagged column cannot take a tagged field",
+			headers: []string{"Client", "Customer Name"},
+			ids:     []string{customer, ""},
+			want:    []string{"customer_name", "Customer Name"},
+		},
+		{
+			name:    "untagged column showing the name of a tagged field",
+			headers: []string{"Area", "region"},
+			ids:     []string{region, ""},
+			want:    []string{"region", "region_2"},
+		},
+		{
+			name:    "unknown tag falls back to the header",
+			headers: []string{"id", "region"},
+			ids:     []string{"dropped", ""},
+			want:    []string{"id", "region"},
+		},
+		{
+			name:    "blank header is not matched",
+			headers: []string{"id", ""},
+			ids:     []string{"", customer},
+			want:    []string{"id", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := columnFieldNames(resource, tt.headers, tt.ids); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("columnFieldNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnIDs(t *testing.T) {
+	want := []string{"a", "", "c"}
+	if got := columnIDs(taggedGrid("a", "", "c")); !reflect.DeepEqual(got, want) {
+		t.Errorf("columnIDs() = %v, want %v", got, want)
+	}
+	if got := columnIDs(nil); len(got) != 0 {
+		t.Errorf("columnIDs(nil) = %v, want none", got)
+	}
+}
+
+func TestCompareFieldsTaggedColumnRenamedByHand(t *testing.T) {
+	desired := convertSchemaFields(labeledResource().Fields)
+	current := append([]FieldInfo{}, desired...)
+	current[1].Header = "Client"
+
+	diff := CompareFields(current, desired)
+	if len(diff.FieldsToAdd) != 0 || len(diff.FieldsToRemove) != 0 {
+		t.Fatalf("expected the tagged column to be kept, got add %v remove %v", diff.FieldsToAdd, diff.FieldsToRemove)
+	}
+	if len(diff.FieldsToModify) != 1 || diff.FieldsToModify[0].Description != `header from "Client" to "Customer Name"` {
+		t.Errorf("expected a header modify, got %+v", diff.FieldsToModify)
+	}
+}
+
+func TestCompareColumnIDs(t *testing.T) {
+	resource := labeledResource()
+	current := []FieldInfo{
+		{Name: "id", ID: resource.Fields[0].ColumnID()},
+		{Name: "customer_name"},
+		{Name: "region", ID: "stale"},
+		{Name: "memo"},
+	}
+
+	got := compareColumnIDs(resource, current)
+	if got == nil || !reflect.DeepEqual(got.Fields, []string{"customer_name", "region"}) {
+		t.Fatalf("compareColumnIDs() = %+v, want customer_name and region", got)
+	}
+	if desc := describeColumnIDsDiff(got); desc != "Tag 2 column(s) with their field ID (customer_name, region)" {
+		t.Errorf("unexpected description: %s", desc)
+	}
+
+	current[1].ID = resource.Fields[1].ColumnID()
+	current[2].ID = resource.Fields[2].ColumnID()
+	if got := compareColumnIDs(resource, current); got != nil {
+		t.Errorf("compareColumnIDs() = %+v, want nil when every column is tagged", got)
+	}
+}
+
+func TestColumnIDRequests(t *testing.T) {
+	resource := schema.Resource{
+		Name: "orders",
+		Fields: []schema.Field{
+			{Name: "id", Type: "integer"},
+			{Name: "amount", Type: "number", ID: "total"},
+			{Name: "status", Type: "string"},
+		},
+	}
+	names := []string{"status", "memo", "amount", "id"}
+	grid := taggedGrid(resource.Fields[2].ColumnID(), "", "amount-old", "")
+
+	requests, fields := ColumnIDRequests(resource, 7, names, grid)
+	if !reflect.DeepEqual(fields, []string{"id", "amount"}) {
+		t.Fatalf("fields = %v, want id and amount", fields)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+
+	create := requests[0].CreateDeveloperMetadata
+	if create == nil {
+		t.Fatalf("expected the untagged column to get a new tag, got %+v", requests[0])
+	}
+	location := create.DeveloperMetadata.Location.DimensionRange
+	if create.DeveloperMetadata.MetadataValue != resource.Fields[0].ColumnID() || location.SheetId != 7 ||
+		location.Dimension != "COLUMNS" || location.StartIndex != 3 || location.EndIndex != 4 {
+		t.Errorf("unexpected create request: %+v at %+v", create.DeveloperMetadata, location)
+	}
+
+	update := requests[1].UpdateDeveloperMetadata
+	if update == nil || update.DeveloperMetadata.MetadataValue != "total" || update.DataFilters[0].DeveloperMetadataLookup.MetadataId != 3 {
+		t.Errorf("expected the stale tag to be updated to the x-id, got %+v", requests[1])
+	}
+}
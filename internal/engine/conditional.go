@@ -0,0 +1,270 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+// conditionalFormatsMetadataKey is the developer metadata key under which ss-migrate records
+// the fingerprints of the conditional format rules it created in a sheet
+const conditionalFormatsMetadataKey = "ss-migrate:conditional-formats"
+
+// ConditionalFormatsDiff represents changes to the conditional format rules owned by ss-migrate in a sheet
+type ConditionalFormatsDiff struct {
+	Type    ChangeType
+	Added   []string // Descriptions of the rules to add
+	Removed []string // Descriptions of the rules to remove
+}
+
+// NewConditionalFormatRule builds the rule for a field's conditional format on the column's data cells
+func NewConditionalFormatRule(sheetID int64, column, headerRow int, cf schema.ConditionalFormat) *sheets.ConditionalFormatRule {
+	rule := &sheets.ConditionalFormatRule{
+		Ranges: []*sheets.GridRange{{
+			SheetId:          sheetID,
+			StartRowIndex:    int64(headerRow),
+			StartColumnIndex: int64(column),
+			EndColumnIndex:   int64(column + 1),
+		}},
+	}
+
+	if cf.Type == "color-scale" {
+		rule.GradientRule = &sheets.GradientRule{
+			Minpoint: interpolationPoint("MIN", cf.MinValue, cf.MinColor),
+			Maxpoint: interpolationPoint("MAX", cf.MaxValue, cf.MaxColor),
+		}
+		if cf.MidColor != "" {
+			rule.GradientRule.Midpoint = &sheets.InterpolationPoint{
+				Type:       "PERCENTILE",
+				Value:      "50",
+				ColorStyle: &sheets.ColorStyle{RgbColor: parseColor(cf.MidColor)},
+			}
+		}
+		return rule
+	}
+
+	condition := &sheets.BooleanCondition{}
+	if cf.Type == "text-contains" {
+		condition.Type = "TEXT_CONTAINS"
+		condition.Values = []*sheets.ConditionValue{{UserEnteredValue: cf.Text}}
+	} else {
+		condition.Type = "CUSTOM_FORMULA"
+		condition.Values = []*sheets.ConditionValue{{UserEnteredValue: cf.Formula}}
+	}
+
+	format := &sheets.CellFormat{}
+	if cf.BackgroundColor != "" {
+		format.BackgroundColorStyle = &sheets.ColorStyle{RgbColor: parseColor(cf.BackgroundColor)}
+	}
+	if cf.FontColor != "" || cf.Bold {
+		format.TextFormat = &sheets.TextFormat{Bold: cf.Bold}
+		if cf.FontColor != "" {
+			format.TextFormat.ForegroundColorStyle = &sheets.ColorStyle{RgbColor: parseColor(cf.FontColor)}
+		}
+	}
+	rule.BooleanRule = &sheets.BooleanRule{Condition: condition, Format: format}
+	return rule
+}
+
+// interpolationPoint builds a color scale end point, fixed at a number when a value is given
+func interpolationPoint(pointType string, value *float64, color string) *sheets.InterpolationPoint {
+	point := &sheets.InterpolationPoint{
+		Type:       pointType,
+		ColorStyle: &sheets.ColorStyle{RgbColor: parseColor(color)},
+	}
+	if value != nil {
+		point.Type = "NUMBER"
+		point.Value = strconv.FormatFloat(*value, 'f', -1, 64)
+	}
+	return point
+}
+
+// ruleSignature describes the content of a rule independently of its range.
+// Colors are compared as #rrggbb since Sheets may return them with less precision than they were sent.
+func ruleSignature(rule *sheets.ConditionalFormatRule) string {
+	if rule.GradientRule != nil {
+		parts := []string{"color-scale"}
+		for _, point := range []*sheets.InterpolationPoint{rule.GradientRule.Minpoint, rule.GradientRule.Midpoint, rule.GradientRule.Maxpoint} {
+			if point == nil {
+				parts = append(parts, "-")
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s:%s:%s", point.Type, point.Value, styledColorHex(point.ColorStyle, point.Color, "")))
+		}
+		return strings.Join(parts, "|")
+	}
+
+	if rule.BooleanRule == nil || rule.BooleanRule.Condition == nil {
+		return ""
+	}
+	condition := rule.BooleanRule.Condition
+	parts := []string{condition.Type}
+	for _, value := range condition.Values {
+		parts = append(parts, value.UserEnteredValue)
+	}
+
+	format := rule.BooleanRule.Format
+	if format == nil {
+		format = &sheets.CellFormat{}
+	}
+	textFormat := format.TextFormat
+	if textFormat == nil {
+		textFormat = &sheets.TextFormat{}
+	}
+	parts = append(parts,
+		"background:"+styledColorHex(format.BackgroundColorStyle, format.BackgroundColor, ""),
+		"font:"+styledColorHex(textFormat.ForegroundColorStyle, textFormat.ForegroundColor, ""),
+		"bold:"+strconv.FormatBool(textFormat.Bold))
+	return strings.Join(parts, "|")
+}
+
+// styledColorHex returns the color of a color style, or of the legacy color field, as #rrggbb
+func styledColorHex(style *sheets.ColorStyle, color *sheets.Color, fallback string) string {
+	if style != nil && style.RgbColor != nil {
+		color = style.RgbColor
+	}
+	return colorHex(color, fallback)
+}
+
+// RuleFingerprint identifies a rule by the field it applies to and its content
+func RuleFingerprint(field string, rule *sheets.ConditionalFormatRule) string {
+	sum := sha256.Sum256([]byte(field + "\x00" + ruleSignature(rule)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// describeRule summarizes a rule for the plan output
+func describeRule(field string, rule *sheets.ConditionalFormatRule) string {
+	if rule.GradientRule != nil {
+		return fmt.Sprintf("%s: color scale", field)
+	}
+	if rule.BooleanRule == nil || rule.BooleanRule.Condition == nil || len(rule.BooleanRule.Condition.Values) == 0 {
+		return fmt.Sprintf("%s: rule", field)
+	}
+	condition := rule.BooleanRule.Condition
+	if condition.Type == "TEXT_CONTAINS" {
+		return fmt.Sprintf("%s: text contains %q", field, condition.Values[0].UserEnteredValue)
+	}
+	return fmt.Sprintf("%s: formula %s", field, condition.Values[0].UserEnteredValue)
+}
+
+// ownedRuleFingerprints reads the fingerprints of the rules owned by ss-migrate from a sheet's developer metadata.
+// It also returns the metadata entry, which is nil when ss-migrate has not created rules in the sheet.
+func ownedRuleFingerprints(metadata []*sheets.DeveloperMetadata) (map[string]bool, *sheets.DeveloperMetadata) {
+	fingerprints, entry := metadataList(metadata, conditionalFormatsMetadataKey)
+	owned := make(map[string]bool, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		owned[fingerprint] = true
+	}
+	return owned, entry
+}
+
+// ruleField returns the header of the column a rule applies to, or "" when it does not target a single column
+func ruleField(rule *sheets.ConditionalFormatRule, headers []string) string {
+	if len(rule.Ranges) != 1 {
+		return ""
+	}
+	r := rule.Ranges[0]
+	if r.EndColumnIndex != r.StartColumnIndex+1 || int(r.StartColumnIndex) >= len(headers) {
+		return ""
+	}
+	return headers[r.StartColumnIndex]
+}
+
+// desiredRule is a conditional format rule declared in the schema, placed on its field's current column
+type desiredRule struct {
+	Field       string
+	Rule        *sheets.ConditionalFormatRule
+	Fingerprint string
+}
+
+// desiredRules builds the rules declared by the resource for the columns in headers.
+// Fields missing from the headers are placed after the existing columns.
+func desiredRules(resource schema.Resource, headers []string, sheetID int64) []desiredRule {
+	rules := []desiredRule{}
+	for i, field := range resource.Fields {
+		column := -1
+		for j, header := range headers {
+			if header == field.Name {
+				column = j
+				break
+			}
+		}
+		if column == -1 {
+			column = len(headers) + i
+		}
+		for _, cf := range field.ConditionalFormats {
+			rule := NewConditionalFormatRule(sheetID, column, resource.HeaderRow, cf)
+			rules = append(rules, desiredRule{
+				Field:       field.Name,
+				Rule:        rule,
+				Fingerprint: RuleFingerprint(field.Name, rule),
+			})
+		}
+	}
+	return rules
+}
+
+// compareConditionalFormats compares the rules owned by ss-migrate with those declared in the schema.
+// Rules that ss-migrate did not create are left alone.
+func compareConditionalFormats(resource schema.Resource, headers []string, sheetMeta *sheets.Sheet) *ConditionalFormatsDiff {
+	var existing []*sheets.ConditionalFormatRule
+	var metadata []*sheets.DeveloperMetadata
+	if sheetMeta != nil {
+		existing = sheetMeta.ConditionalFormats
+		metadata = sheetMeta.DeveloperMetadata
+	}
+	owned, _ := ownedRuleFingerprints(metadata)
+
+	desired := desiredRules(resource, headers, 0)
+	wanted := make(map[string]bool)
+	for _, rule := range desired {
+		wanted[rule.Fingerprint] = true
+	}
+
+	diff := &ConditionalFormatsDiff{}
+	present := make(map[string]bool)
+	for _, rule := range existing {
+		field := ruleField(rule, headers)
+		fingerprint := RuleFingerprint(field, rule)
+		if !owned[fingerprint] {
+			continue
+		}
+		present[fingerprint] = true
+		if !wanted[fingerprint] {
+			diff.Removed = append(diff.Removed, describeRule(field, rule))
+		}
+	}
+	for _, rule := range desired {
+		if !present[rule.Fingerprint] {
+			diff.Added = append(diff.Added, describeRule(rule.Field, rule.Rule))
+		}
+	}
+
+	switch {
+	case len(diff.Added) == 0 && len(diff.Removed) == 0:
+		return nil
+	case len(diff.Removed) == 0:
+		diff.Type = ChangeTypeAdd
+	case len(diff.Added) == 0:
+		diff.Type = ChangeTypeRemove
+	default:
+		diff.Type = ChangeTypeModify
+	}
+	return diff
+}
+
+func describeConditionalFormatsDiff(d *ConditionalFormatsDiff) string {
+	parts := []string{}
+	if len(d.Added) > 0 {
+		parts = append(parts, fmt.Sprintf("add %s", strings.Join(d.Added, "; ")))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, fmt.Sprintf("remove %s", strings.Join(d.Removed, "; ")))
+	}
+	return fmt.Sprintf("Update conditional formats: %s", strings.Join(parts, ", "))
+}
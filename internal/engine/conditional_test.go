@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestNewConditionalFormatRule(t *testing.T) {
+	scale := NewConditionalFormatRule(3, 2, 1, schema.ConditionalFormat{
+		Type:     "color-scale",
+		MinColor: "#ff0000",
+		MaxColor: "#00ff00",
+		MaxValue: floatPtr(100),
+	})
+	if scale.Ranges[0].StartColumnIndex != 2 || scale.Ranges[0].EndColumnIndex != 3 || scale.Ranges[0].StartRowIndex != 1 {
+		t.Errorf("unexpected range %+v", scale.Ranges[0])
+	}
+	if scale.GradientRule.Minpoint.Type != "MIN" || scale.GradientRule.Maxpoint.Type != "NUMBER" || scale.GradientRule.Maxpoint.Value != "100" {
+		t.Errorf("unexpected gradient %+v / %+v", scale.GradientRule.Minpoint, scale.GradientRule.Maxpoint)
+	}
+	if scale.GradientRule.Midpoint != nil {
+		t.Errorf("expected no midpoint, got %+v", scale.GradientRule.Midpoint)
+	}
+
+	text := NewConditionalFormatRule(3, 0, 1, schema.ConditionalFormat{Type: "text-contains", Text: "urgent", Bold: true})
+	condition := text.BooleanRule.Condition
+	if condition.Type != "TEXT_CONTAINS" || condition.Values[0].UserEnteredValue != "urgent" || !text.BooleanRule.Format.TextFormat.Bold {
+		t.Errorf("unexpected boolean rule %+v", text.BooleanRule)
+	}
+}
+
+func TestRuleFingerprint(t *testing.T) {
+	cf := schema.ConditionalFormat{Type: "formula", Formula: "=$C2>100", BackgroundColor: "#f4cccc"}
+	rule := NewConditionalFormatRule(0, 2, 1, cf)
+
+	// As returned by Sheets: moved to another column, with the color in the legacy field and less precision
+	returned := &sheets.ConditionalFormatRule{
+		Ranges: []*sheets.GridRange{{StartRowIndex: 1, StartColumnIndex: 4, EndColumnIndex: 5}},
+		BooleanRule: &sheets.BooleanRule{
+			Condition: rule.BooleanRule.Condition,
+			Format: &sheets.CellFormat{
+				BackgroundColor: &sheets.Color{Red: 0.95686275, Green: 0.8, Blue: 0.8},
+			},
+		},
+	}
+
+	if RuleFingerprint("total", rule) != RuleFingerprint("total", returned) {
+		t.Error("expected fingerprint to ignore range and color precision")
+	}
+	if RuleFingerprint("total", rule) == RuleFingerprint("price", rule) {
+		t.Error("expected fingerprint to depend on the field")
+	}
+}
+
+func TestCompareConditionalFormats(t *testing.T) {
+	resource := schema.Resource{
+		Name:      "orders",
+		HeaderRow: 1,
+		Fields: []schema.Field{
+			{Name: "status", ConditionalFormats: []schema.ConditionalFormat{{Type: "text-contains", Text: "late", BackgroundColor: "#f4cccc"}}},
+			{Name: "total", ConditionalFormats: []schema.ConditionalFormat{{Type: "color-scale", MinColor: "#ffffff", MaxColor: "#57bb8a"}}},
+		},
+	}
+	headers := []string{"status", "total"}
+
+	handMade := NewConditionalFormatRule(0, 0, 1, schema.ConditionalFormat{Type: "text-contains", Text: "vip", Bold: true})
+	status := NewConditionalFormatRule(0, 0, 1, resource.Fields[0].ConditionalFormats[0])
+	oldScale := NewConditionalFormatRule(0, 1, 1, schema.ConditionalFormat{Type: "color-scale", MinColor: "#ffffff", MaxColor: "#e67c73"})
+	owned, _ := json.Marshal([]string{RuleFingerprint("status", status), RuleFingerprint("total", oldScale)})
+
+	sheetMeta := &sheets.Sheet{
+		ConditionalFormats: []*sheets.ConditionalFormatRule{handMade, status, oldScale},
+		DeveloperMetadata: []*sheets.DeveloperMetadata{
+			{MetadataKey: conditionalFormatsMetadataKey, MetadataValue: string(owned)},
+		},
+	}
+
+	diff := compareConditionalFormats(resource, headers, sheetMeta)
+	if diff == nil {
+		t.Fatal("expected conditional format changes")
+	}
+	if diff.Type != ChangeTypeModify {
+		t.Errorf("expected MODIFY, got %s", diff.Type)
+	}
+	if !reflect.DeepEqual(diff.Added, []string{"total: color scale"}) || !reflect.DeepEqual(diff.Removed, []string{"total: color scale"}) {
+		t.Errorf("unexpected diff %+v", diff)
+	}
+
+	// Once the new scale is recorded the hand-made rule is still left alone
+	newScale := NewConditionalFormatRule(0, 1, 1, resource.Fields[1].ConditionalFormats[0])
+	owned, _ = json.Marshal([]string{RuleFingerprint("status", status), RuleFingerprint("total", newScale)})
+	sheetMeta.ConditionalFormats = []*sheets.ConditionalFormatRule{handMade, status, newScale}
+	sheetMeta.DeveloperMetadata[0].MetadataValue = string(owned)
+	if diff := compareConditionalFormats(resource, headers, sheetMeta); diff != nil {
+		t.Errorf("expected no changes, got %+v", diff)
+	}
+
+	// Rules are removed when they are dropped from the schema
+	resource.Fields[0].ConditionalFormats = nil
+	resource.Fields[1].ConditionalFormats = nil
+	diff = compareConditionalFormats(resource, headers, sheetMeta)
+	if diff == nil || diff.Type != ChangeTypeRemove || len(diff.Removed) != 2 {
+		t.Errorf("expected both owned rules to be removed, got %+v", diff)
+	}
+}
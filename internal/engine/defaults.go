@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+)
+
+// isFormulaDefault reports whether a default value is a formula rather than a literal
+func isFormulaDefault(value any) bool {
+	s, ok := value.(string)
+	return ok && strings.HasPrefix(s, "=")
+}
+
+// validateDefault checks that a literal default can be read back as the field type
+func validateDefault(resource schema.Resource, field FieldInfo) error {
+	if isFormulaDefault(field.Default) {
+		return nil
+	}
+
+	opts := sheet.ValueOptions{}
+	for _, f := range resource.Fields {
+		if f.Name == field.Name {
+			opts = valueOptions(f)
+			break
+		}
+	}
+	_, err := sheet.ConvertValueWithOptions(field.Type, field.Format, opts, field.Default)
+	return err
+}
+
+// countDataRows returns the number of rows below the header that are not entirely empty
+func countDataRows(rows [][]any) int {
+	count := 0
+	for _, row := range rows {
+		if !isEmptyRow(row) {
+			count++
+		}
+	}
+	return count
+}
+
+// BackfillValues builds the values written to a new column, one row per data row up to the last one.
+// Rows that are entirely empty are left blank. Formula defaults may use {row} like x-formula.
+func BackfillValues(defaultValue any, rows [][]any, firstDataRow int) [][]any {
+	last := -1
+	for i, row := range rows {
+		if !isEmptyRow(row) {
+			last = i
+		}
+	}
+
+	values := make([][]any, last+1)
+	for i := range values {
+		if isEmptyRow(rows[i]) {
+			values[i] = []any{""}
+			continue
+		}
+		value := defaultValue
+		if isFormulaDefault(value) {
+			value = FormulaForRow(value.(string), firstDataRow+i)
+		}
+		values[i] = []any{value}
+	}
+	return values
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+)
+
+func TestBackfillValues(t *testing.T) {
+	rows := [][]any{
+		{"a", "1"},
+		{},
+		{"", "2"},
+		{""},
+	}
+
+	tests := []struct {
+		name         string
+		defaultValue any
+		want         [][]any
+	}{
+		{
+			name:         "literal",
+			defaultValue: 0,
+			want:         [][]any{{0}, {""}, {0}},
+		},
+		{
+			name:         "formula template",
+			defaultValue: "=B{row}*2",
+			want:         [][]any{{"=B2*2"}, {""}, {"=B4*2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BackfillValues(tt.defaultValue, rows, 2)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BackfillValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := BackfillValues("n/a", nil, 2); len(got) != 0 {
+		t.Errorf("expected no values for an empty sheet, got %v", got)
+	}
+}
+
+func TestValidateDefault(t *testing.T) {
+	resource := schema.Resource{
+		Name:   "orders",
+		Fields: []schema.Field{{Name: "tags", Type: "array", ItemType: "integer", Delimiter: ";"}},
+	}
+
+	tests := []struct {
+		name    string
+		field   FieldInfo
+		wantErr bool
+	}{
+		{"valid integer", FieldInfo{Name: "qty", Type: "integer", Default: 0}, false},
+		{"invalid integer", FieldInfo{Name: "qty", Type: "integer", Default: "none"}, true},
+		{"formula", FieldInfo{Name: "qty", Type: "integer", Default: "=ROW()"}, false},
+		{"array options", FieldInfo{Name: "tags", Type: "array", Default: "1;2"}, false},
+		{"invalid array item", FieldInfo{Name: "tags", Type: "array", Default: "1;x"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateDefault(resource, tt.field); (err != nil) != tt.wantErr {
+				t.Errorf("validateDefault() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackfillDescription(t *testing.T) {
+	diff := &SheetDiff{
+		FieldsToAdd: []FieldInfo{{Name: "status", Type: "string", Position: 1, Default: "active", Backfill: 5000}},
+	}
+
+	result := ConvertDiffToResult(diff, "users")
+	if len(result.Changes) != 1 || !strings.Contains(result.Changes[0].Description, "backfilling 5000 row(s) with active") {
+		t.Errorf("expected backfill in description, got %+v", result.Changes)
+	}
+}
@@ -3,6 +3,8 @@ package engine
 import (
 	"fmt"
 	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/sheet"
 )
 
 // ChangeType represents the type of change detected
@@ -30,19 +32,31 @@ type DiffResult struct {
 	Changes     []Change
 	HasChanges  bool
 	Summary     string
+	Errors      []string      // Problems that block the migration
+	Warnings    []string      // Problems that do not block the migration
+	Suggestions []string      // Schema changes that would make the migration less destructive
+	Layout      *ColumnLayout // Header row the plan was made against, nil when the sheet could not be read
 }
 
 // FieldDiff represents differences in a field
 type FieldDiff struct {
-	Name        string
-	Type        ChangeType
-	OldType     string
-	NewType     string
-	OldFormat   string
-	NewFormat   string
-	OldHidden   bool
-	NewHidden   bool
-	Description string
+	Name           string
+	Type           ChangeType
+	OldType        string
+	NewType        string
+	OldFormat      string
+	NewFormat      string
+	OldHidden      bool
+	NewHidden      bool
+	OldPattern     string
+	NewPattern     string
+	OldPatternType string
+	NewPatternType string
+	OldNote        string
+	NewNote        string
+	OldHeader      string
+	NewHeader      string
+	Description    string
 }
 
 // SheetDiff represents differences in sheet structure
@@ -51,38 +65,86 @@ type SheetDiff struct {
 	FieldsToAdd     []FieldInfo
 	FieldsToRemove  []FieldInfo
 	FieldsToModify  []FieldDiff
-	FieldsToReorder bool     // Indicates if fields need reordering
-	ExpectedOrder   []string // Expected field order from schema
+	FieldsToReorder bool         // Indicates if fields need reordering
+	ExpectedOrder   []string     // Expected field order from schema
+	Moves           []ColumnMove // Column moves that reorder the fields, computed by the planner
+	KeyRule         *KeyRuleDiff
+	Formulas        []FormulaDiff
+	Styles          []StyleDiff
+	Properties      *SheetPropertiesDiff
+	Conditional     *ConditionalFormatsDiff
+	Table           *TableDiff
+	NamedRanges     *NamedRangesDiff
+	ColumnIDs       *ColumnIDsDiff
+	Errors          []string
+	Warnings        []string
+	Suggestions     []string
 }
 
 // FieldInfo represents basic field information
 type FieldInfo struct {
-	Name     string
-	Type     string
-	Format   string
-	Hidden   bool
-	Position int // Position in schema for ordering
+	Name        string
+	Type        string
+	Format      string
+	Hidden      bool
+	Position    int    // Position in schema for ordering
+	Pattern     string // Number format pattern of the column
+	PatternType string // Number format type of the column (NUMBER, CURRENCY, DATE, ...)
+	Default     any    // Value written to existing rows when the field is added
+	Backfill    int    // Number of existing data rows that receive the default
+	Note        string // Note on the header cell, "" when ss-migrate does not manage it
+	Header      string // Text of the header cell, which is the name unless the field has a label
+	Column      int    // Index of the column in the sheet, for fields read from the sheet
+	ID          string // Field ID the column is tagged with, for fields read from the sheet
 }
 
 // FormatDiff formats the diff result for display
 func (d *DiffResult) Format() string {
-	if !d.HasChanges {
+	if !d.HasChanges && !d.HasErrors() && len(d.Warnings) == 0 {
 		return "No changes detected. Sheet matches the schema."
 	}
 
 	var sb strings.Builder
 	sb.WriteString("=== Schema Migration Plan ===\n\n")
 	sb.WriteString(d.Summary)
-	sb.WriteString("\n\nChanges to be applied:\n")
 
-	for _, change := range d.Changes {
-		sb.WriteString(formatChange(change))
-		sb.WriteString("\n")
+	if d.HasChanges {
+		sb.WriteString("\n\nChanges to be applied:\n")
+		for _, change := range d.Changes {
+			sb.WriteString(formatChange(change))
+			sb.WriteString("\n")
+		}
+	}
+
+	if d.HasErrors() {
+		sb.WriteString("\nBlocking errors:\n")
+		for _, e := range d.Errors {
+			sb.WriteString(fmt.Sprintf("  ✗ %s\n", e))
+		}
+	}
+
+	if len(d.Warnings) > 0 {
+		sb.WriteString("\nWarnings:\n")
+		for _, w := range d.Warnings {
+			sb.WriteString(fmt.Sprintf("  ! %s\n", w))
+		}
+	}
+
+	if len(d.Suggestions) > 0 {
+		sb.WriteString("\nSuggestions:\n")
+		for _, s := range d.Suggestions {
+			sb.WriteString(fmt.Sprintf("  ? %s\n", s))
+		}
 	}
 
 	return sb.String()
 }
 
+// HasErrors reports whether the plan contains problems that block the migration
+func (d *DiffResult) HasErrors() bool {
+	return len(d.Errors) > 0
+}
+
 func formatChange(c Change) string {
 	switch c.Type {
 	case ChangeTypeAdd:
@@ -98,8 +160,25 @@ func formatChange(c Change) string {
 	}
 }
 
+// isDefaultPattern reports whether a column without a number format is taken as having the planned
+// pattern, which it does when the pattern is the default of the field's type
+func isDefaultPattern(current, planned FieldInfo) bool {
+	if current.Pattern != "" || current.Type != planned.Type {
+		return false
+	}
+	defaultFormat := sheet.NumberFormatWithOptions(planned.Type, "", sheet.FormatOptions{})
+	return defaultFormat != nil && defaultFormat.Pattern == planned.Pattern && defaultFormat.Type == planned.PatternType
+}
+
 // CompareFields compares two sets of fields and returns the differences
 func CompareFields(currentFields, schemaFields []FieldInfo) *SheetDiff {
+	return CompareFieldsWithOrder(currentFields, schemaFields, "strict")
+}
+
+// CompareFieldsWithOrder compares two sets of fields under a resource's x-order mode.
+// strict reorders columns into schema order, append adds new fields after the existing columns and never
+// moves them, and ignore leaves the column order out of the comparison.
+func CompareFieldsWithOrder(currentFields, schemaFields []FieldInfo, order string) *SheetDiff {
 	diff := &SheetDiff{
 		FieldsToAdd:     []FieldInfo{},
 		FieldsToRemove:  []FieldInfo{},
@@ -140,24 +219,47 @@ func CompareFields(currentFields, schemaFields []FieldInfo) *SheetDiff {
 		if currentField, exists := currentMap[schemaField.Name]; exists {
 			hasChanges := false
 			fieldDiff := FieldDiff{
-				Name:      schemaField.Name,
-				Type:      ChangeTypeModify,
-				OldType:   currentField.Type,
-				NewType:   schemaField.Type,
-				OldFormat: currentField.Format,
-				NewFormat: schemaField.Format,
-				OldHidden: currentField.Hidden,
-				NewHidden: schemaField.Hidden,
+				Name:           schemaField.Name,
+				Type:           ChangeTypeModify,
+				OldType:        currentField.Type,
+				NewType:        schemaField.Type,
+				OldFormat:      currentField.Format,
+				NewFormat:      schemaField.Format,
+				OldHidden:      currentField.Hidden,
+				NewHidden:      schemaField.Hidden,
+				OldPattern:     currentField.Pattern,
+				NewPattern:     schemaField.Pattern,
+				OldPatternType: currentField.PatternType,
+				NewPatternType: schemaField.PatternType,
+				OldNote:        currentField.Note,
+				NewNote:        currentField.Note,
+				OldHeader:      currentField.Header,
+				NewHeader:      currentField.Header,
 			}
 			
 			var changes []string
 			
+			// When the schema defines a number format, the format is compared through
+			// the pattern instead, as formats such as currency:JPY are not inferable.
+			// A column that already has the exact pattern needs no type change either.
+			patternMatches := schemaField.Pattern != "" &&
+				(currentField.Pattern == schemaField.Pattern && currentField.PatternType == schemaField.PatternType ||
+					isDefaultPattern(currentField, schemaField))
+			typeChanged := currentField.Type != schemaField.Type && !patternMatches
+			formatChanged := currentField.Format != schemaField.Format && schemaField.Pattern == ""
+			patternChanged := schemaField.Pattern != "" && !patternMatches
+
 			// Check for type/format changes
-			if currentField.Type != schemaField.Type || currentField.Format != schemaField.Format {
+			if typeChanged || formatChanged {
 				hasChanges = true
 				changes = append(changes, fmt.Sprintf("type from %s to %s",
 					formatFieldType(currentField.Type, currentField.Format),
 					formatFieldType(schemaField.Type, schemaField.Format)))
+			} else if patternChanged {
+				hasChanges = true
+				changes = append(changes, fmt.Sprintf("number format from %s to %s",
+					formatNumberFormat(currentField.Pattern, currentField.PatternType),
+					formatNumberFormat(schemaField.Pattern, schemaField.PatternType)))
 			}
 			
 			// Check for hidden status changes
@@ -170,6 +272,24 @@ func CompareFields(currentFields, schemaFields []FieldInfo) *SheetDiff {
 				}
 			}
 			
+			// A changed label is rewritten in place rather than replacing the column
+			if schemaField.Header != "" && currentField.Header != "" && currentField.Header != schemaField.Header {
+				hasChanges = true
+				fieldDiff.NewHeader = schemaField.Header
+				changes = append(changes, fmt.Sprintf("header from %q to %q", currentField.Header, schemaField.Header))
+			}
+
+			// Header notes are only compared for fields with a title or description
+			if schemaField.Note != "" && currentField.Note != schemaField.Note {
+				hasChanges = true
+				fieldDiff.NewNote = schemaField.Note
+				if currentField.Note == "" {
+					changes = append(changes, "add header note")
+				} else {
+					changes = append(changes, "update header note")
+				}
+			}
+
 			if hasChanges {
 				fieldDiff.Description = strings.Join(changes, ", ")
 				diff.FieldsToModify = append(diff.FieldsToModify, fieldDiff)
@@ -194,8 +314,20 @@ func CompareFields(currentFields, schemaFields []FieldInfo) *SheetDiff {
 		}
 	}
 
+	// Appended fields go after the columns that remain, in schema order
+	if order == "append" {
+		width := len(currentFields)
+		for _, field := range currentFields {
+			width = max(width, field.Column+1)
+		}
+		width -= len(diff.FieldsToRemove)
+		for i := range diff.FieldsToAdd {
+			diff.FieldsToAdd[i].Position = width + i
+		}
+	}
+
 	// Compare orders
-	if len(currentOrder) == len(diff.ExpectedOrder) {
+	if order != "append" && order != "ignore" && len(currentOrder) == len(diff.ExpectedOrder) {
 		for i := range currentOrder {
 			if currentOrder[i] != diff.ExpectedOrder[i] {
 				diff.FieldsToReorder = true
@@ -207,6 +339,14 @@ func CompareFields(currentFields, schemaFields []FieldInfo) *SheetDiff {
 	return diff
 }
 
+// describeReorder lists the column moves of a reorder when the planner computed them
+func describeReorder(diff *SheetDiff) string {
+	if len(diff.Moves) == 0 {
+		return fmt.Sprintf("Reorder fields to match schema: %s", strings.Join(diff.ExpectedOrder, ", "))
+	}
+	return fmt.Sprintf("Reorder fields to match schema in %d move(s): %s", len(diff.Moves), describeMoves(diff.Moves))
+}
+
 func formatFieldType(fieldType, format string) string {
 	if format != "" {
 		return fmt.Sprintf("%s(%s)", fieldType, format)
@@ -214,6 +354,16 @@ func formatFieldType(fieldType, format string) string {
 	return fieldType
 }
 
+func formatNumberFormat(pattern, patternType string) string {
+	if pattern == "" {
+		return "none"
+	}
+	if patternType == "" {
+		return pattern
+	}
+	return fmt.Sprintf("%s [%s]", pattern, patternType)
+}
+
 // ConvertDiffToResult converts a SheetDiff to a DiffResult
 func ConvertDiffToResult(diff *SheetDiff, sheetName string) *DiffResult {
 	return ConvertDiffToResultWithOrder(diff, sheetName, nil)
@@ -235,10 +385,14 @@ func ConvertDiffToResultWithOrder(diff *SheetDiff, sheetName string, schemaField
 		if field.Position >= 0 {
 			positionInfo = fmt.Sprintf(" at position %d", field.Position+1)
 		}
+		backfillInfo := ""
+		if field.Default != nil && field.Backfill > 0 {
+			backfillInfo = fmt.Sprintf(", backfilling %d row(s) with %v", field.Backfill, field.Default)
+		}
 		change := Change{
 			Type:        ChangeTypeAdd,
 			Path:        fmt.Sprintf("%s.%s", sheetName, field.Name),
-			Description: fmt.Sprintf("Add new field '%s' of type %s%s", field.Name, formatFieldType(field.Type, field.Format), positionInfo),
+			Description: fmt.Sprintf("Add new field '%s' of type %s%s%s", field.Name, formatFieldType(field.Type, field.Format), positionInfo, backfillInfo),
 			NewValue:    field,
 		}
 		changesByField[field.Name] = append(changesByField[field.Name], change)
@@ -325,17 +479,111 @@ func ConvertDiffToResultWithOrder(diff *SheetDiff, sheetName string, schemaField
 		}
 	}
 
-	// Add field reordering if needed (always at the end)
+	// Add field reordering if needed (always after field changes)
 	if diff.FieldsToReorder {
 		result.Changes = append(result.Changes, Change{
 			Type:        ChangeTypeReorder,
 			Path:        sheetName,
-			Description: fmt.Sprintf("Reorder fields to match schema: %s", strings.Join(diff.ExpectedOrder, ", ")),
+			Description: describeReorder(diff),
 			NewValue:    diff.ExpectedOrder,
 		})
 		result.HasChanges = true
 	}
 
+	// Sheet-level changes depend on the final column layout, so they come last
+	if diff.ColumnIDs != nil {
+		result.Changes = append(result.Changes, Change{
+			Type:        diff.ColumnIDs.Type,
+			Path:        sheetName,
+			Description: describeColumnIDsDiff(diff.ColumnIDs),
+			OldValue:    *diff.ColumnIDs,
+			NewValue:    *diff.ColumnIDs,
+		})
+		result.HasChanges = true
+	}
+	if diff.KeyRule != nil {
+		result.Changes = append(result.Changes, Change{
+			Type:        diff.KeyRule.Type,
+			Path:        sheetName,
+			Description: describeKeyRuleDiff(diff.KeyRule),
+			OldValue:    *diff.KeyRule,
+			NewValue:    *diff.KeyRule,
+		})
+		result.HasChanges = true
+	}
+	for i := range diff.Formulas {
+		formulaDiff := diff.Formulas[i]
+		result.Changes = append(result.Changes, Change{
+			Type:        formulaDiff.Type,
+			Path:        fmt.Sprintf("%s.%s", sheetName, formulaDiff.Field),
+			Description: describeFormulaDiff(&formulaDiff),
+			OldValue:    formulaDiff,
+			NewValue:    formulaDiff,
+		})
+		result.HasChanges = true
+	}
+
+	for i := range diff.Styles {
+		styleDiff := diff.Styles[i]
+		path := sheetName
+		if styleDiff.Field != "" {
+			path = fmt.Sprintf("%s.%s", sheetName, styleDiff.Field)
+		}
+		result.Changes = append(result.Changes, Change{
+			Type:        styleDiff.Type,
+			Path:        path,
+			Description: describeStyleDiff(&styleDiff),
+			OldValue:    styleDiff,
+			NewValue:    styleDiff,
+		})
+		result.HasChanges = true
+	}
+
+	if diff.Conditional != nil {
+		result.Changes = append(result.Changes, Change{
+			Type:        diff.Conditional.Type,
+			Path:        sheetName,
+			Description: describeConditionalFormatsDiff(diff.Conditional),
+			OldValue:    *diff.Conditional,
+			NewValue:    *diff.Conditional,
+		})
+		result.HasChanges = true
+	}
+	if diff.Table != nil {
+		result.Changes = append(result.Changes, Change{
+			Type:        diff.Table.Type,
+			Path:        sheetName,
+			Description: describeTableDiff(diff.Table),
+			OldValue:    *diff.Table,
+			NewValue:    *diff.Table,
+		})
+		result.HasChanges = true
+	}
+	if diff.NamedRanges != nil {
+		result.Changes = append(result.Changes, Change{
+			Type:        diff.NamedRanges.Type,
+			Path:        sheetName,
+			Description: describeNamedRangesDiff(diff.NamedRanges),
+			OldValue:    *diff.NamedRanges,
+			NewValue:    *diff.NamedRanges,
+		})
+		result.HasChanges = true
+	}
+	if diff.Properties != nil {
+		result.Changes = append(result.Changes, Change{
+			Type:        diff.Properties.Type,
+			Path:        sheetName,
+			Description: describeSheetPropertiesDiff(diff.Properties),
+			OldValue:    *diff.Properties,
+			NewValue:    *diff.Properties,
+		})
+		result.HasChanges = true
+	}
+
+	result.Errors = append(result.Errors, diff.Errors...)
+	result.Warnings = append(result.Warnings, diff.Warnings...)
+	result.Suggestions = append(result.Suggestions, diff.Suggestions...)
+
 	// Generate summary
 	result.Summary = generateSummary(diff, sheetName)
 
@@ -357,10 +605,49 @@ func generateSummary(diff *SheetDiff, sheetName string) string {
 	if diff.FieldsToReorder {
 		parts = append(parts, "fields need reordering")
 	}
+	if diff.ColumnIDs != nil {
+		parts = append(parts, fmt.Sprintf("%d column(s) to tag", len(diff.ColumnIDs.Fields)))
+	}
+	if diff.KeyRule != nil {
+		parts = append(parts, "primary key check to update")
+	}
+	if len(diff.Formulas) > 0 {
+		parts = append(parts, fmt.Sprintf("%d computed column(s) to update", len(diff.Formulas)))
+	}
+	if len(diff.Styles) > 0 {
+		parts = append(parts, fmt.Sprintf("%d style(s) to update", len(diff.Styles)))
+	}
+	if diff.Conditional != nil {
+		parts = append(parts, "conditional formats to update")
+	}
+	if diff.Table != nil {
+		parts = append(parts, "banding or filter to update")
+	}
+	if diff.NamedRanges != nil {
+		parts = append(parts, "named ranges to update")
+	}
+	if diff.Properties != nil {
+		parts = append(parts, "sheet properties to update")
+	}
+	if len(diff.Errors) > 0 {
+		parts = append(parts, fmt.Sprintf("%d blocking error(s)", len(diff.Errors)))
+	}
 
 	if len(parts) == 0 {
 		return fmt.Sprintf("Sheet '%s' is up to date", sheetName)
 	}
 
 	return fmt.Sprintf("Sheet '%s': %s", sheetName, strings.Join(parts, ", "))
-}
\ No newline at end of file
+}
+
+func describeKeyRuleDiff(d *KeyRuleDiff) string {
+	switch d.Type {
+	case ChangeTypeAdd:
+		return fmt.Sprintf("Highlight blank and duplicate primary keys (%s)", strings.Join(d.NewFields, ", "))
+	case ChangeTypeRemove:
+		return fmt.Sprintf("Remove primary key highlight (%s)", strings.Join(d.OldFields, ", "))
+	default:
+		return fmt.Sprintf("Update primary key highlight from (%s) to (%s)",
+			strings.Join(d.OldFields, ", "), strings.Join(d.NewFields, ", "))
+	}
+}
@@ -163,4 +163,64 @@ func TestReorderChangeInDiffResult(t *testing.T) {
 	if result.Summary == "" {
 		t.Error("expected non-empty summary")
 	}
-}
\ No newline at end of file
+}
+
+func TestCompareFieldsOrderModes(t *testing.T) {
+	schemaFields := []FieldInfo{
+		{Name: "id", Type: "integer", Position: 0},
+		{Name: "name", Type: "string", Position: 1},
+		{Name: "email", Type: "string", Position: 2},
+		{Name: "phone", Type: "string", Position: 3},
+	}
+	currentFields := []FieldInfo{
+		{Name: "email", Type: "string", Column: 0},
+		{Name: "id", Type: "integer", Column: 1},
+		{Name: "legacy", Type: "string", Column: 2},
+		{Name: "name", Type: "string", Column: 3},
+	}
+
+	tests := []struct {
+		order         string
+		expectReorder bool
+		phonePosition int
+	}{
+		{order: "", expectReorder: true, phonePosition: 3},
+		{order: "strict", expectReorder: true, phonePosition: 3},
+		{order: "append", expectReorder: false, phonePosition: 3},
+		{order: "ignore", expectReorder: false, phonePosition: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			diff := CompareFieldsWithOrder(currentFields, schemaFields, tt.order)
+			if diff.FieldsToReorder != tt.expectReorder {
+				t.Errorf("FieldsToReorder = %v, want %v", diff.FieldsToReorder, tt.expectReorder)
+			}
+			if len(diff.FieldsToAdd) != 1 || diff.FieldsToAdd[0].Position != tt.phonePosition {
+				t.Errorf("expected phone to be added at position %d, got %+v", tt.phonePosition, diff.FieldsToAdd)
+			}
+		})
+	}
+}
+
+func TestCompareFieldsAppendPositions(t *testing.T) {
+	schemaFields := []FieldInfo{
+		{Name: "id", Type: "integer", Position: 0},
+		{Name: "created_at", Type: "datetime", Position: 1},
+		{Name: "name", Type: "string", Position: 2},
+		{Name: "updated_at", Type: "datetime", Position: 3},
+	}
+	currentFields := []FieldInfo{
+		{Name: "name", Type: "string", Column: 0},
+		{Name: "id", Type: "integer", Column: 2},
+	}
+
+	diff := CompareFieldsWithOrder(currentFields, schemaFields, "append")
+	if diff.FieldsToReorder {
+		t.Error("append mode should never reorder existing columns")
+	}
+	// The blank column B stays, so new fields go to D and E
+	if len(diff.FieldsToAdd) != 2 || diff.FieldsToAdd[0].Position != 3 || diff.FieldsToAdd[1].Position != 4 {
+		t.Errorf("expected fields appended at positions 3 and 4, got %+v", diff.FieldsToAdd)
+	}
+}
@@ -3,6 +3,8 @@ package engine
 import (
 	"strings"
 	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
 )
 
 func TestCompareFields(t *testing.T) {
@@ -204,4 +206,98 @@ func TestConvertDiffToResult(t *testing.T) {
 	if changeTypes[ChangeTypeModify] != 1 {
 		t.Errorf("expected 1 MODIFY change, got %d", changeTypes[ChangeTypeModify])
 	}
-}
\ No newline at end of file
+}
+func TestCompareFieldsNumberFormat(t *testing.T) {
+	tests := []struct {
+		name          string
+		current       FieldInfo
+		schema        FieldInfo
+		expectModify  bool
+		expectMessage string
+	}{
+		{
+			name:         "same pattern",
+			current:      FieldInfo{Name: "price", Type: "number", Pattern: `"¥"#,##0`, PatternType: "CURRENCY"},
+			schema:       FieldInfo{Name: "price", Type: "number", Format: "currency:JPY", Pattern: `"¥"#,##0`, PatternType: "CURRENCY"},
+			expectModify: false,
+		},
+		{
+			name:          "different currency",
+			current:       FieldInfo{Name: "price", Type: "number", Pattern: `"$"#,##0.00`, PatternType: "CURRENCY"},
+			schema:        FieldInfo{Name: "price", Type: "number", Format: "currency:JPY", Pattern: `"¥"#,##0`, PatternType: "CURRENCY"},
+			expectModify:  true,
+			expectMessage: `number format from "$"#,##0.00 [CURRENCY] to "¥"#,##0 [CURRENCY]`,
+		},
+		{
+			name:          "same pattern with different type",
+			current:       FieldInfo{Name: "rate", Type: "number", Pattern: "0.00", PatternType: "PERCENT"},
+			schema:        FieldInfo{Name: "rate", Type: "number", Pattern: "0.00", PatternType: "NUMBER"},
+			expectModify:  true,
+			expectMessage: "number format from 0.00 [PERCENT] to 0.00 [NUMBER]",
+		},
+		{
+			name:         "inferred type differs but pattern matches",
+			current:      FieldInfo{Name: "qty", Type: "number", Pattern: "0%", PatternType: "PERCENT"},
+			schema:       FieldInfo{Name: "qty", Type: "integer", Format: "percent", Pattern: "0%", PatternType: "PERCENT"},
+			expectModify: false,
+		},
+		{
+			name:          "unformatted column",
+			current:       FieldInfo{Name: "qty", Type: "integer"},
+			schema:        FieldInfo{Name: "qty", Type: "integer", Pattern: "#,##0", PatternType: "NUMBER"},
+			expectModify:  true,
+			expectMessage: "number format from none to #,##0 [NUMBER]",
+		},
+		{
+			name:         "unformatted column planned with the type's default pattern",
+			current:      FieldInfo{Name: "qty", Type: "integer"},
+			schema:       FieldInfo{Name: "qty", Type: "integer", Pattern: "0", PatternType: "NUMBER"},
+			expectModify: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := CompareFields([]FieldInfo{tt.current}, []FieldInfo{tt.schema})
+			if (len(diff.FieldsToModify) == 1) != tt.expectModify {
+				t.Fatalf("expected modify=%v, got %+v", tt.expectModify, diff.FieldsToModify)
+			}
+			if tt.expectModify && diff.FieldsToModify[0].Description != tt.expectMessage {
+				t.Errorf("expected description %q, got %q", tt.expectMessage, diff.FieldsToModify[0].Description)
+			}
+		})
+	}
+}
+
+func TestCompareFieldsDefaultFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   schema.Field
+		current FieldInfo
+	}{
+		{
+			name:    "plain string",
+			field:   schema.Field{Name: "name", Type: "string"},
+			current: FieldInfo{Name: "name", Header: "name", Type: "string"},
+		},
+		{
+			name:    "plain integer",
+			field:   schema.Field{Name: "qty", Type: "integer"},
+			current: FieldInfo{Name: "qty", Header: "qty", Type: "integer"},
+		},
+		{
+			name:    "plain date",
+			field:   schema.Field{Name: "day", Type: "date"},
+			current: FieldInfo{Name: "day", Header: "day", Type: "date", Pattern: "yyyy-mm-dd", PatternType: "DATE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := CompareFields([]FieldInfo{tt.current}, convertSchemaFields([]schema.Field{tt.field}))
+			if len(diff.FieldsToModify) != 0 {
+				t.Errorf("expected no changes, got %+v", diff.FieldsToModify)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+	"google.golang.org/api/sheets/v4"
+)
+
+// formulaProtectionMarker prefixes the description of the protected ranges that ss-migrate
+// creates for computed columns. It is followed by the field name.
+const formulaProtectionMarker = "ss-migrate:formula:"
+
+// rowPlaceholder is replaced by the sheet row number in per-row formula templates
+const rowPlaceholder = "{row}"
+
+// maxListedCells limits the number of cell references shown in plan descriptions
+const maxListedCells = 5
+
+// FormulaDiff represents a change to a computed column
+type FormulaDiff struct {
+	Type        ChangeType
+	Field       string
+	Formula     string   // Desired formula, empty when the column is no longer computed
+	Stale       []string // Cells whose formula is missing or differs from the desired one
+	Overwritten []string // Cells where the formula has been replaced with a value
+	Protect     bool     // Whether the read-only protection must be (re)created
+}
+
+// IsRowTemplate reports whether the formula is written to every data row.
+// Other formulas, such as an ARRAYFORMULA, are written once in the first data row.
+func IsRowTemplate(formula string) bool {
+	return strings.Contains(formula, rowPlaceholder)
+}
+
+// FormulaForRow returns the formula to write in the given 1-based sheet row
+func FormulaForRow(formula string, row int) string {
+	return strings.ReplaceAll(formula, rowPlaceholder, fmt.Sprintf("%d", row))
+}
+
+// ExpectedFormulas returns the content a computed column should have, one entry per row below the header.
+// Templates fill every row that holds data in another column; other formulas occupy the first data row
+// and expect no formula below it, so that the result can spill there. The values read below it are
+// the spilled results, so the expected content ends at the last cell holding a formula.
+// rows are the rows below the header and column is the 0-based index of the computed column.
+func ExpectedFormulas(formula string, rows [][]any, column, firstDataRow int) []string {
+	if !IsRowTemplate(formula) {
+		n := 1
+		for i, row := range rows {
+			if column < len(row) && isFormulaCell(row[column]) {
+				n = i + 1
+			}
+		}
+		expected := make([]string, n)
+		expected[0] = formula
+		return expected
+	}
+
+	n := 0
+	for i, row := range rows {
+		for j, cell := range row {
+			if j != column && !isBlankCell(cell) {
+				n = i + 1
+				break
+			}
+		}
+	}
+	expected := make([]string, n)
+	for i := range expected {
+		expected[i] = FormulaForRow(formula, firstDataRow+i)
+	}
+	return expected
+}
+
+// CompareFormulaCells compares a computed column with its expected content.
+// rows must be read with formulas rendered as written. It returns the cell references
+// whose formula is missing or outdated and those whose formula was replaced with a value.
+// Values below a formula that is not a template are taken as its spilled result and not reported.
+func CompareFormulaCells(formula string, rows [][]any, column, firstDataRow int) (stale, overwritten []string) {
+	letter := sheet.ColumnToLetter(column)
+	for i, want := range ExpectedFormulas(formula, rows, column, firstDataRow) {
+		var cell any
+		if i < len(rows) && column < len(rows[i]) {
+			cell = rows[i][column]
+		}
+
+		got := ""
+		if !isBlankCell(cell) {
+			got = strings.TrimSpace(fmt.Sprintf("%v", cell))
+		}
+		if normalizeFormula(got) == normalizeFormula(want) || want == "" && !strings.HasPrefix(got, "=") {
+			continue
+		}
+
+		ref := fmt.Sprintf("%s%d", letter, firstDataRow+i)
+		if got != "" && !strings.HasPrefix(got, "=") {
+			overwritten = append(overwritten, ref)
+		} else {
+			stale = append(stale, ref)
+		}
+	}
+	return stale, overwritten
+}
+
+// normalizeFormula removes whitespace and folds case outside string literals,
+// as Sheets upper-cases function names and references when a formula is entered
+func normalizeFormula(formula string) string {
+	var sb strings.Builder
+	inString := false
+	for _, r := range formula {
+		switch {
+		case r == '"':
+			inString = !inString
+			sb.WriteRune(r)
+		case inString:
+			sb.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+		default:
+			sb.WriteString(strings.ToUpper(string(r)))
+		}
+	}
+	return sb.String()
+}
+
+// isFormulaCell reports whether a cell read with formulas rendered as written holds a formula
+func isFormulaCell(cell any) bool {
+	text, ok := cell.(string)
+	return ok && strings.HasPrefix(strings.TrimSpace(text), "=")
+}
+
+// isBlankCell reports whether a cell value is empty
+func isBlankCell(cell any) bool {
+	return cell == nil || strings.TrimSpace(fmt.Sprintf("%v", cell)) == ""
+}
+
+// FindFormulaProtections returns the protected ranges managed by ss-migrate keyed by field name
+func FindFormulaProtections(ranges []*sheets.ProtectedRange) map[string]*sheets.ProtectedRange {
+	protections := make(map[string]*sheets.ProtectedRange)
+	for _, protectedRange := range ranges {
+		if field, ok := strings.CutPrefix(protectedRange.Description, formulaProtectionMarker); ok {
+			protections[field] = protectedRange
+		}
+	}
+	return protections
+}
+
+// NewFormulaProtection builds the read-only protection of a computed column below the header
+func NewFormulaProtection(sheetID int64, field string, column, headerRow int) *sheets.ProtectedRange {
+	return &sheets.ProtectedRange{
+		Description: formulaProtectionMarker + field,
+		Range: &sheets.GridRange{
+			SheetId:          sheetID,
+			StartRowIndex:    int64(headerRow),
+			StartColumnIndex: int64(column),
+			EndColumnIndex:   int64(column + 1),
+		},
+	}
+}
+
+// protectsColumn reports whether a protected range covers exactly the data rows of the column
+func protectsColumn(protectedRange *sheets.ProtectedRange, column, headerRow int) bool {
+	r := protectedRange.Range
+	return r != nil && r.StartRowIndex == int64(headerRow) && r.EndRowIndex == 0 &&
+		r.StartColumnIndex == int64(column) && r.EndColumnIndex == int64(column+1)
+}
+
+// compareFormulas determines which computed columns must be rewritten, protected or unprotected.
+// layout is the header row once the fields are migrated, and rows are all sheet rows starting at
+// row 1 read with formulas rendered as written, and are nil when the sheet does not exist yet.
+func compareFormulas(resource schema.Resource, layout []string, rows [][]any, protections map[string]*sheets.ProtectedRange) ([]FormulaDiff, []string) {
+	headerRow := resource.HeaderRow
+	if headerRow == 0 {
+		headerRow = 1
+	}
+
+	var headers []string
+	var dataRows [][]any
+	if len(rows) >= headerRow {
+		headers = FieldNames(resource, cellStrings(rows[headerRow-1]))
+		dataRows = rows[headerRow:]
+	}
+
+	diffs := []FormulaDiff{}
+	warnings := []string{}
+	computed := make(map[string]bool)
+	for _, field := range resource.Fields {
+		if field.Formula == "" {
+			continue
+		}
+		computed[field.Name] = true
+
+		column := -1
+		for j, header := range headers {
+			if header == field.Name {
+				column = j
+				break
+			}
+		}
+
+		protection := protections[field.Name]
+		if column == -1 {
+			diffs = append(diffs, FormulaDiff{Type: ChangeTypeAdd, Field: field.Name, Formula: field.Formula, Protect: true})
+			continue
+		}
+
+		stale, overwritten := CompareFormulaCells(field.Formula, dataRows, column, headerRow+1)
+		// The protection must cover the column the field has once the fields are migrated, which is
+		// where the applier protects it
+		protect := protection == nil || !protectsColumn(protection, slices.Index(layout, field.Name), headerRow)
+		if len(stale) == 0 && len(overwritten) == 0 && !protect {
+			continue
+		}
+
+		if len(overwritten) > 0 {
+			warnings = append(warnings, fmt.Sprintf("Formula of computed field '%s' has been overwritten with values in %s!%s; apply will restore it",
+				field.Name, resource.Name, listCells(overwritten)))
+		}
+
+		changeType := ChangeTypeModify
+		if protection == nil {
+			changeType = ChangeTypeAdd
+		}
+		diffs = append(diffs, FormulaDiff{
+			Type:        changeType,
+			Field:       field.Name,
+			Formula:     field.Formula,
+			Stale:       stale,
+			Overwritten: overwritten,
+			Protect:     protect,
+		})
+	}
+
+	// Columns that are no longer computed keep their values but lose the protection
+	for _, field := range resource.Fields {
+		if _, ok := protections[field.Name]; ok && !computed[field.Name] {
+			diffs = append(diffs, FormulaDiff{Type: ChangeTypeRemove, Field: field.Name})
+		}
+	}
+
+	return diffs, warnings
+}
+
+// listCells joins cell references, abbreviating long lists
+func listCells(cells []string) string {
+	if len(cells) <= maxListedCells {
+		return strings.Join(cells, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(cells[:maxListedCells], ", "), len(cells)-maxListedCells)
+}
+
+func describeFormulaDiff(d *FormulaDiff) string {
+	if d.Type == ChangeTypeRemove {
+		return "Remove read-only protection of column that is no longer computed"
+	}
+	if d.Type == ChangeTypeAdd && len(d.Stale) == 0 && len(d.Overwritten) == 0 {
+		return fmt.Sprintf("Write formula %s and protect column as read-only", d.Formula)
+	}
+
+	parts := []string{}
+	if n := len(d.Stale) + len(d.Overwritten); n > 0 {
+		part := fmt.Sprintf("Write formula %s to %d cell(s)", d.Formula, n)
+		if len(d.Overwritten) > 0 {
+			part += fmt.Sprintf(" (%d overwritten with values)", len(d.Overwritten))
+		}
+		parts = append(parts, part)
+	}
+	if d.Protect {
+		if len(parts) == 0 {
+			return "Protect computed column as read-only"
+		}
+		parts = append(parts, "protect column as read-only")
+	}
+	return strings.Join(parts, " and ")
+}
@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestCompareFormulaCells(t *testing.T) {
+	tests := []struct {
+		name            string
+		formula         string
+		rows            [][]any
+		wantStale       []string
+		wantOverwritten []string
+	}{
+		{
+			name:    "row template up to date",
+			formula: "=B{row}*C{row}",
+			rows: [][]any{
+				{"a", 2.0, 3.0, "=B2*C2"},
+				{"b", 4.0, 5.0, "=b3 * c3"},
+			},
+		},
+		{
+			name:    "row template with missing and overwritten cells",
+			formula: "=B{row}*C{row}",
+			rows: [][]any{
+				{"a", 2.0, 3.0, "=B2*C2"},
+				{"b", 4.0, 5.0, 20.0},
+				{"c", 6.0, 7.0},
+				{},
+				{"d", 8.0, 9.0, "=B5+C5"},
+			},
+			wantStale:       []string{"D4", "D5", "D6"},
+			wantOverwritten: []string{"D3"},
+		},
+		{
+			name:    "array formula with spilled values",
+			formula: "=ARRAYFORMULA(B2:B*C2:C)",
+			rows: [][]any{
+				{"a", 2.0, 3.0, "=ARRAYFORMULA(B2:B*C2:C)"},
+				{"b", 4.0, 5.0, 20.0},
+				{"c", 6.0, 7.0, 42.0},
+			},
+		},
+		{
+			name:    "array formula blocked by a formula",
+			formula: "=ARRAYFORMULA(B2:B*C2:C)",
+			rows: [][]any{
+				{"a", 2.0, 3.0, "=ARRAYFORMULA(B2:B*C2:C)"},
+				{"b", 4.0, 5.0, 20.0},
+				{"c", 6.0, 7.0, "=B4*C4"},
+			},
+			wantStale: []string{"D4"},
+		},
+		{
+			name:    "array formula replaced with a value",
+			formula: "=ARRAYFORMULA(B2:B*C2:C)",
+			rows: [][]any{
+				{"a", 2.0, 3.0, 6.0},
+				{"b", 4.0, 5.0, 20.0},
+			},
+			wantOverwritten: []string{"D2"},
+		},
+		{
+			name:      "array formula missing",
+			formula:   "=ARRAYFORMULA(B2:B*C2:C)",
+			rows:      [][]any{},
+			wantStale: []string{"D2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stale, overwritten := CompareFormulaCells(tt.formula, tt.rows, 3, 2)
+			if !reflect.DeepEqual(stale, tt.wantStale) {
+				t.Errorf("stale = %v, want %v", stale, tt.wantStale)
+			}
+			if !reflect.DeepEqual(overwritten, tt.wantOverwritten) {
+				t.Errorf("overwritten = %v, want %v", overwritten, tt.wantOverwritten)
+			}
+		})
+	}
+}
+
+func TestCompareFormulas(t *testing.T) {
+	resource := schema.Resource{
+		Name:      "orders",
+		HeaderRow: 1,
+		Fields: []schema.Field{
+			{Name: "price", Type: "number"},
+			{Name: "qty", Type: "integer"},
+			{Name: "total", Type: "number", Formula: "=A{row}*B{row}"},
+			{Name: "note", Type: "string"},
+		},
+	}
+	layout := []string{"price", "qty", "total", "note"}
+	rows := [][]any{
+		{"price", "qty", "total", "note"},
+		{2.0, 3.0, "=A2*B2"},
+		{4.0, 5.0, 20.0},
+	}
+
+	t.Run("missing protection and overwritten value", func(t *testing.T) {
+		diffs, warnings := compareFormulas(resource, layout, rows, nil)
+		if len(diffs) != 1 {
+			t.Fatalf("expected 1 formula diff, got %+v", diffs)
+		}
+		if diffs[0].Type != ChangeTypeAdd || !diffs[0].Protect || !reflect.DeepEqual(diffs[0].Overwritten, []string{"C3"}) {
+			t.Errorf("unexpected diff %+v", diffs[0])
+		}
+		if len(warnings) != 1 || !strings.Contains(warnings[0], "orders!C3") {
+			t.Errorf("expected warning referencing orders!C3, got %v", warnings)
+		}
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		fixed := [][]any{rows[0], rows[1], {4.0, 5.0, "=A3*B3"}}
+		protections := map[string]*sheets.ProtectedRange{
+			"total": NewFormulaProtection(0, "total", 2, 1),
+		}
+		diffs, warnings := compareFormulas(resource, layout, fixed, protections)
+		if len(diffs) != 0 || len(warnings) != 0 {
+			t.Errorf("expected no changes, got %+v %v", diffs, warnings)
+		}
+	})
+
+	t.Run("kept blank column", func(t *testing.T) {
+		blank := [][]any{
+			{"price", "qty", "", "total", "note"},
+			{2.0, 3.0, "", "=A2*B2"},
+		}
+		protections := map[string]*sheets.ProtectedRange{
+			"total": NewFormulaProtection(0, "total", 3, 1),
+		}
+		diffs, warnings := compareFormulas(resource, []string{"price", "qty", "", "total", "note"}, blank, protections)
+		if len(diffs) != 0 || len(warnings) != 0 {
+			t.Errorf("expected no changes, got %+v %v", diffs, warnings)
+		}
+	})
+
+	t.Run("new column", func(t *testing.T) {
+		diffs, _ := compareFormulas(resource, layout, nil, nil)
+		if len(diffs) != 1 || diffs[0].Type != ChangeTypeAdd || diffs[0].Field != "total" {
+			t.Errorf("expected ADD for total, got %+v", diffs)
+		}
+	})
+
+	t.Run("no longer computed", func(t *testing.T) {
+		plain := resource
+		plain.Fields = []schema.Field{{Name: "price"}, {Name: "qty"}, {Name: "total"}, {Name: "note"}}
+		protections := FindFormulaProtections([]*sheets.ProtectedRange{
+			{Description: "Finance only"},
+			NewFormulaProtection(0, "total", 2, 1),
+		})
+		diffs, _ := compareFormulas(plain, layout, rows, protections)
+		if len(diffs) != 1 || diffs[0].Type != ChangeTypeRemove {
+			t.Errorf("expected REMOVE for total, got %+v", diffs)
+		}
+	})
+}
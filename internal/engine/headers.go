@@ -0,0 +1,373 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+)
+
+// FieldNames maps the header cells of a sheet to the names of the fields they hold.
+// A cell matches a field by its name or by its x-header label, after normalization when the resource
+// uses normalized header matching. Cells that match no field are kept as they are.
+func FieldNames(resource schema.Resource, headers []string) []string {
+	key := headerKey(resource)
+	byHeader := make(map[string]string, len(resource.Fields))
+	for _, field := range resource.Fields {
+		if field.Header != "" {
+			byHeader[key(field.Header)] = field.Name
+		}
+	}
+	for _, field := range resource.Fields {
+		byHeader[key(field.Name)] = field.Name
+	}
+
+	names := make([]string, len(headers))
+	for i, header := range headers {
+		if name, ok := byHeader[key(header)]; ok && header != "" {
+			names[i] = name
+		} else {
+			names[i] = header
+		}
+	}
+	if resource.DuplicateHeaders == "suffix" {
+		suffixDuplicates(names)
+	}
+	return names
+}
+
+// suffixDuplicates renames the second and later occurrences of a header to name_2, name_3, ...,
+// skipping suffixes that are already taken by another column
+func suffixDuplicates(names []string) {
+	taken := make(map[string]bool, len(names))
+	for _, name := range names {
+		taken[name] = true
+	}
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		if !seen[name] {
+			seen[name] = true
+			continue
+		}
+		for n := 2; ; n++ {
+			suffixed := fmt.Sprintf("%s_%d", name, n)
+			if !taken[suffixed] {
+				names[i] = suffixed
+				taken[suffixed] = true
+				break
+			}
+		}
+	}
+}
+
+// checkHeaders reports duplicate and blank header cells, which would make columns ambiguous.
+// headers are the header cells mapped to field names, as returned by FieldNames.
+func checkHeaders(resource schema.Resource, headers []string) []string {
+	headerRow := resource.HeaderRow
+	if headerRow == 0 {
+		headerRow = 1
+	}
+	cell := func(column int) string {
+		return fmt.Sprintf("%s%d", sheet.ColumnToLetter(column), headerRow)
+	}
+
+	errs := []string{}
+	columns := make(map[string][]string)
+	order := []string{}
+	blanks := []string{}
+	for i, header := range headers {
+		if header == "" {
+			blanks = append(blanks, cell(i))
+			continue
+		}
+		if _, ok := columns[header]; !ok {
+			order = append(order, header)
+		}
+		columns[header] = append(columns[header], cell(i))
+	}
+
+	for _, header := range order {
+		if len(columns[header]) > 1 {
+			errs = append(errs, fmt.Sprintf("Duplicate header %q in %s of %s; rename the columns or set x-duplicate-headers: suffix",
+				header, strings.Join(columns[header], ", "), resource.Name))
+		}
+	}
+	if len(blanks) > 0 && resource.BlankHeaders != "unmanaged" {
+		errs = append(errs, fmt.Sprintf("Blank header in %s of %s; name the columns or set x-blank-headers: unmanaged",
+			strings.Join(blanks, ", "), resource.Name))
+	}
+	return errs
+}
+
+// ColumnMove moves the column at From so that it ends up at To. Moves are applied in sequence,
+// so the indexes of a move account for the moves before it.
+type ColumnMove struct {
+	Field string
+	From  int
+	To    int
+}
+
+// reorderMoves computes the fewest moves that put the fields of a resource in schema order. The fields keep
+// to the columns they occupy between them, so columns that are not managed, such as blank ones, stay in place.
+// The columns forming the longest run already in schema order stay where they are and the others are moved.
+func reorderMoves(resource schema.Resource, headers []string) []ColumnMove {
+	present := make(map[string]int)
+	for i, header := range headers {
+		present[header] = i
+	}
+
+	// Columns are tracked by their original index, as blank columns share the same header
+	desired := make([]int, len(headers))
+	for i := range desired {
+		desired[i] = i
+	}
+	slot := 0
+	for _, field := range resource.Fields {
+		index, ok := present[field.Name]
+		if !ok {
+			continue
+		}
+		for slot < len(headers) && !isManagedColumn(resource, headers[slot]) {
+			slot++
+		}
+		desired[slot] = index
+		slot++
+	}
+
+	keep := keptColumns(resource, headers, desired)
+
+	current := make([]int, len(headers))
+	for i := range current {
+		current[i] = i
+	}
+	indexOf := func(column int) int {
+		for i, c := range current {
+			if c == column {
+				return i
+			}
+		}
+		return -1
+	}
+
+	// Each moved column is placed right after the column that precedes it in schema order,
+	// which is already in its final place as columns are handled from left to right
+	moves := []ColumnMove{}
+	for position, column := range desired {
+		if keep[column] {
+			continue
+		}
+		from := indexOf(column)
+		to := 0
+		if position > 0 {
+			to = indexOf(desired[position-1])
+			if from > to {
+				to++
+			}
+		}
+		if from == to {
+			continue
+		}
+		moves = append(moves, ColumnMove{Field: headers[column], From: from, To: to})
+		copy(current[from:], current[from+1:])
+		copy(current[to+1:], current[to:len(current)-1])
+		current[to] = column
+	}
+	return moves
+}
+
+// insertIndex returns the column at which the field at schemaIndex of the resource is inserted into headers.
+// The field goes before the first following field of the schema that is present, or after the last column
+// when there is none or when the resource appends new fields.
+func insertIndex(resource schema.Resource, headers []string, schemaIndex int) int {
+	if resource.Order == "append" {
+		return len(headers)
+	}
+	for _, next := range resource.Fields[schemaIndex+1:] {
+		if j := slices.Index(headers, next.Name); j != -1 {
+			return j
+		}
+	}
+	return len(headers)
+}
+
+// plannedLayout returns the header row as it will be once the planned columns are removed and added,
+// with each column mapped to its field name. Added fields are placed by insertIndex, as the applier does.
+func plannedLayout(resource schema.Resource, currentFields []FieldInfo, diff *SheetDiff) []string {
+	width := 0
+	for _, field := range currentFields {
+		width = max(width, field.Column+1)
+	}
+	layout := make([]string, width)
+	for _, field := range currentFields {
+		layout[field.Column] = field.Name
+	}
+
+	removed := make(map[int]bool)
+	for _, field := range diff.FieldsToRemove {
+		removed[field.Column] = true
+	}
+	kept := []string{}
+	for i, name := range layout {
+		if !removed[i] {
+			kept = append(kept, name)
+		}
+	}
+	layout = kept
+
+	added := make(map[string]bool)
+	for _, field := range diff.FieldsToAdd {
+		added[field.Name] = true
+	}
+	for i, field := range resource.Fields {
+		if !added[field.Name] {
+			continue
+		}
+		layout = slices.Insert(layout, insertIndex(resource, layout, i), field.Name)
+	}
+	return layout
+}
+
+// movedLayout returns the header row as it is once the given column moves are made
+func movedLayout(layout []string, moves []ColumnMove) []string {
+	layout = slices.Clone(layout)
+	for _, move := range moves {
+		name := layout[move.From]
+		layout = slices.Insert(slices.Delete(layout, move.From, move.From+1), move.To, name)
+	}
+	return layout
+}
+
+// keptColumns picks the columns that stay in place: the longest sequence of columns whose targets are
+// already in increasing order. Unmanaged columns must stay, so they weigh more than all fields together.
+func keptColumns(resource schema.Resource, headers []string, desired []int) []bool {
+	n := len(headers)
+	target := make([]int, n)
+	for position, column := range desired {
+		target[column] = position
+	}
+	weight := make([]int, n)
+	for i, header := range headers {
+		weight[i] = 1
+		if !isManagedColumn(resource, header) {
+			weight[i] = n + 1
+		}
+	}
+
+	best := make([]int, n)
+	prev := make([]int, n)
+	last := -1
+	for i := range n {
+		best[i], prev[i] = weight[i], -1
+		for j := range i {
+			if target[j] < target[i] && best[j]+weight[i] > best[i] {
+				best[i], prev[i] = best[j]+weight[i], j
+			}
+		}
+		if last == -1 || best[i] > best[last] {
+			last = i
+		}
+	}
+
+	keep := make([]bool, n)
+	for i := last; i != -1; i = prev[i] {
+		keep[i] = true
+	}
+	return keep
+}
+
+// describeMoves lists column moves for the plan, e.g. "move c from A to C"
+func describeMoves(moves []ColumnMove) string {
+	parts := make([]string, len(moves))
+	for i, move := range moves {
+		parts[i] = fmt.Sprintf("move %s from %s to %s", move.Field, sheet.ColumnToLetter(move.From), sheet.ColumnToLetter(move.To))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// isManagedColumn reports whether a header holds one of the resource's fields
+func isManagedColumn(resource schema.Resource, header string) bool {
+	for _, field := range resource.Fields {
+		if field.Name == header {
+			return true
+		}
+	}
+	return false
+}
+
+// headerKey returns how header cells are compared for a resource
+func headerKey(resource schema.Resource) func(string) string {
+	if resource.HeaderMatching == "normalized" {
+		return schema.NormalizeHeader
+	}
+	return func(header string) string { return header }
+}
+
+// displayedHeader returns the header text to compare with the field's label. With normalized matching
+// a cell that only differs from the label by normalization is taken as showing the label, so it is not rewritten.
+func displayedHeader(resource schema.Resource, name, header string) string {
+	if resource.HeaderMatching != "normalized" {
+		return header
+	}
+	for _, field := range resource.Fields {
+		if field.Name == name && schema.NormalizeHeader(header) == schema.NormalizeHeader(field.HeaderText()) {
+			return field.HeaderText()
+		}
+	}
+	return header
+}
+
+// holdNearMissHeaders keeps columns whose header only differs from a field to add by case, whitespace or
+// character width. Instead of removing the column and adding the field, the plan warns about the mismatch.
+func holdNearMissHeaders(diff *SheetDiff, resource schema.Resource) {
+	adds := make(map[string]int)
+	for i, field := range diff.FieldsToAdd {
+		for _, f := range resource.Fields {
+			if f.Name == field.Name {
+				adds[schema.NormalizeHeader(f.Name)] = i
+				adds[schema.NormalizeHeader(f.HeaderText())] = i
+			}
+		}
+	}
+
+	held := make(map[int]bool)
+	removals := []FieldInfo{}
+	for _, current := range diff.FieldsToRemove {
+		i, ok := adds[schema.NormalizeHeader(current.Name)]
+		if !ok || held[i] {
+			removals = append(removals, current)
+			continue
+		}
+		held[i] = true
+		diff.Warnings = append(diff.Warnings, fmt.Sprintf(
+			"Header %q looks like field %s but does not match it exactly; the column is left as is. Fix the header or set x-header-matching: normalized",
+			current.Name, diff.FieldsToAdd[i].Name))
+	}
+	if len(held) == 0 {
+		return
+	}
+
+	additions := []FieldInfo{}
+	for i, field := range diff.FieldsToAdd {
+		if !held[i] {
+			additions = append(additions, field)
+		}
+	}
+	diff.FieldsToAdd = additions
+	diff.FieldsToRemove = removals
+}
+
+// cellStrings converts a row of cell values into strings, leaving empty cells blank
+func cellStrings(row []any) []string {
+	strs := make([]string, len(row))
+	for i, val := range row {
+		if val != nil {
+			strs[i] = fmt.Sprintf("%v", val)
+		}
+	}
+	return strs
+}
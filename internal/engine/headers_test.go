@@ -0,0 +1,231 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+)
+
+func labeledResource() schema.Resource {
+	return schema.Resource{
+		Name:      "customers",
+		HeaderRow: 1,
+		Fields: []schema.Field{
+			{Name: "id", Type: "integer"},
+			{Name: "customer_name", Type: "string", Header: "Customer Name"},
+			{Name: "region", Type: "string", Header: "地域"},
+		},
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	headers := []string{"Customer Name", "id", "region", "memo", ""}
+	want := []string{"customer_name", "id", "region", "memo", ""}
+
+	if got := FieldNames(labeledResource(), headers); !reflect.DeepEqual(got, want) {
+		t.Errorf("FieldNames() = %v, want %v", got, want)
+	}
+}
+
+func TestCompareFieldsHeaderLabel(t *testing.T) {
+	desired := convertSchemaFields(labeledResource().Fields)
+	current := append([]FieldInfo{}, desired...)
+	current[2].Header = "region"
+
+	diff := CompareFields(current, desired)
+	if len(diff.FieldsToAdd) != 0 || len(diff.FieldsToRemove) != 0 {
+		t.Fatalf("expected labels to match existing columns, got add %v remove %v", diff.FieldsToAdd, diff.FieldsToRemove)
+	}
+	if len(diff.FieldsToModify) != 1 {
+		t.Fatalf("expected 1 field to modify, got %+v", diff.FieldsToModify)
+	}
+	got := diff.FieldsToModify[0]
+	if got.Name != "region" || got.OldHeader != "region" || got.NewHeader != "地域" || got.Description != `header from "region" to "地域"` {
+		t.Errorf("unexpected diff: %+v", got)
+	}
+}
+
+func TestReadRecordsByLabel(t *testing.T) {
+	values := [][]any{
+		{"地域", "Customer Name", "id"},
+		{"north", "Alice", 1.0},
+	}
+
+	records, cellErrors, err := ReadRecords(labeledResource(), values)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cellErrors) != 0 {
+		t.Fatalf("unexpected cell errors: %v", cellErrors)
+	}
+	want := map[string]any{"id": int64(1), "customer_name": "Alice", "region": "north"}
+	if got := ExportRecord(labeledResource(), records[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExportRecord() = %v, want %v", got, want)
+	}
+}
+
+func TestFieldNamesNormalized(t *testing.T) {
+	resource := labeledResource()
+	headers := []string{" ID", "customer name", "ｒｅｇｉｏｎ", "Memo"}
+
+	if got, want := FieldNames(resource, headers), headers; !reflect.DeepEqual(got, want) {
+		t.Errorf("exact matching: FieldNames() = %v, want %v", got, want)
+	}
+
+	resource.HeaderMatching = "normalized"
+	want := []string{"id", "customer_name", "region", "Memo"}
+	if got := FieldNames(resource, headers); !reflect.DeepEqual(got, want) {
+		t.Errorf("normalized matching: FieldNames() = %v, want %v", got, want)
+	}
+	if got := displayedHeader(resource, "customer_name", "customer name"); got != "Customer Name" {
+		t.Errorf("displayedHeader() = %q, want the label", got)
+	}
+}
+
+func TestHoldNearMissHeaders(t *testing.T) {
+	resource := labeledResource()
+	current := []FieldInfo{
+		{Name: "id", Header: "id", Type: "integer"},
+		{Name: "customer name ", Header: "customer name ", Type: "string"},
+		{Name: "legacy", Header: "legacy", Type: "string"},
+	}
+	desired := convertSchemaFields(resource.Fields)
+
+	diff := CompareFields(current, desired)
+	holdNearMissHeaders(diff, resource)
+
+	if len(diff.FieldsToAdd) != 1 || diff.FieldsToAdd[0].Name != "region" {
+		t.Errorf("expected only region to be added, got %+v", diff.FieldsToAdd)
+	}
+	if len(diff.FieldsToRemove) != 1 || diff.FieldsToRemove[0].Name != "legacy" {
+		t.Errorf("expected only legacy to be removed, got %+v", diff.FieldsToRemove)
+	}
+	want := `Header "customer name " looks like field customer_name but does not match it exactly; the column is left as is. Fix the header or set x-header-matching: normalized`
+	if len(diff.Warnings) != 1 || diff.Warnings[0] != want {
+		t.Errorf("Warnings = %v, want [%s]", diff.Warnings, want)
+	}
+}
+
+func TestCheckHeaders(t *testing.T) {
+	resource := schema.Resource{Name: "orders", HeaderRow: 2, Fields: []schema.Field{{Name: "id"}, {Name: "notes"}}}
+	headers := []string{"id", "notes", "", "notes", "total", ""}
+
+	want := []string{
+		`Duplicate header "notes" in B2, D2 of orders; rename the columns or set x-duplicate-headers: suffix`,
+		"Blank header in C2, F2 of orders; name the columns or set x-blank-headers: unmanaged",
+	}
+	if got := checkHeaders(resource, headers); !reflect.DeepEqual(got, want) {
+		t.Errorf("checkHeaders() = %v, want %v", got, want)
+	}
+
+	resource.DuplicateHeaders = "suffix"
+	resource.BlankHeaders = "unmanaged"
+	names := FieldNames(resource, headers)
+	if want := []string{"id", "notes", "", "notes_2", "total", ""}; !reflect.DeepEqual(names, want) {
+		t.Errorf("FieldNames() = %v, want %v", names, want)
+	}
+	if got := checkHeaders(resource, names); len(got) != 0 {
+		t.Errorf("expected no errors once resolved, got %v", got)
+	}
+}
+
+func TestSuffixDuplicatesSkipsTakenNames(t *testing.T) {
+	names := []string{"notes", "notes_2", "notes", "notes"}
+	suffixDuplicates(names)
+	if want := []string{"notes", "notes_2", "notes_3", "notes_4"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("suffixDuplicates() = %v, want %v", names, want)
+	}
+}
+
+func TestReorderMoves(t *testing.T) {
+	resource := schema.Resource{Fields: []schema.Field{{Name: "a"}, {Name: "b"}, {Name: "c"}}}
+
+	tests := []struct {
+		name      string
+		headers   []string
+		want      []string
+		wantMoves int
+	}{
+		{name: "in order", headers: []string{"a", "b", "c"}, want: []string{"a", "b", "c"}, wantMoves: 0},
+		{name: "reversed", headers: []string{"c", "b", "a"}, want: []string{"a", "b", "c"}, wantMoves: 2},
+		{name: "last column first", headers: []string{"c", "a", "b"}, want: []string{"a", "b", "c"}, wantMoves: 1},
+		{name: "first column last", headers: []string{"b", "c", "a"}, want: []string{"a", "b", "c"}, wantMoves: 1},
+		{name: "unknown column in a run", headers: []string{"c", "x", "a", "b"}, want: []string{"a", "x", "b", "c"}, wantMoves: 2},
+		{name: "blank columns stay in place", headers: []string{"c", "", "a", "b", ""}, want: []string{"a", "", "b", "c", ""}, wantMoves: 2},
+		{name: "unknown column stays in place", headers: []string{"b", "d", "a"}, want: []string{"a", "d", "b"}, wantMoves: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := append([]string{}, tt.headers...)
+			moves := reorderMoves(resource, tt.headers)
+			for _, move := range moves {
+				if layout[move.From] != move.Field {
+					t.Fatalf("move %+v does not start at the field's column in %v", move, layout)
+				}
+				moved := layout[move.From]
+				layout = append(layout[:move.From], layout[move.From+1:]...)
+				layout = append(layout[:move.To], append([]string{moved}, layout[move.To:]...)...)
+			}
+			if !reflect.DeepEqual(layout, tt.want) {
+				t.Errorf("layout after moves = %v, want %v", layout, tt.want)
+			}
+			if got := movedLayout(tt.headers, moves); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("movedLayout() = %v, want %v", got, tt.want)
+			}
+			if len(moves) != tt.wantMoves {
+				t.Errorf("got %d move(s) %+v, want %d", len(moves), moves, tt.wantMoves)
+			}
+		})
+	}
+}
+
+func TestPlannedLayout(t *testing.T) {
+	resource := schema.Resource{Fields: []schema.Field{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}}
+	current := []FieldInfo{
+		{Name: "c", Column: 0},
+		{Name: "old", Column: 1},
+		{Name: "a", Column: 3},
+		{Name: "d", Column: 4},
+	}
+	diff := &SheetDiff{
+		FieldsToAdd:    []FieldInfo{{Name: "b"}},
+		FieldsToRemove: []FieldInfo{{Name: "old", Column: 1}},
+	}
+
+	// b goes before c, the first following field present, wherever c is
+	want := []string{"b", "c", "", "a", "d"}
+	if got := plannedLayout(resource, current, diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("plannedLayout() = %v, want %v", got, want)
+	}
+}
+
+func TestDescribeReorder(t *testing.T) {
+	diff := &SheetDiff{ExpectedOrder: []string{"a", "b", "c"}}
+	if got := describeReorder(diff); got != "Reorder fields to match schema: a, b, c" {
+		t.Errorf("describeReorder() = %q", got)
+	}
+
+	diff.Moves = []ColumnMove{{Field: "c", From: 0, To: 2}}
+	if got := describeReorder(diff); got != "Reorder fields to match schema in 1 move(s): move c from A to C" {
+		t.Errorf("describeReorder() = %q", got)
+	}
+}
+
+func TestInsertIndex(t *testing.T) {
+	resource := schema.Resource{Fields: []schema.Field{{Name: "a"}, {Name: "b"}, {Name: "c"}}}
+	headers := []string{"c", "a", "memo"}
+
+	if got := insertIndex(resource, headers, 1); got != 0 {
+		t.Errorf("insertIndex() = %d, want 0 (before c)", got)
+	}
+	if got := insertIndex(resource, headers, 2); got != 3 {
+		t.Errorf("insertIndex() = %d, want 3 (after the last column)", got)
+	}
+
+	resource.Order = "append"
+	if got := insertIndex(resource, headers, 1); got != 3 {
+		t.Errorf("insertIndex() = %d, want 3 when appending", got)
+	}
+}
@@ -0,0 +1,602 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+	"google.golang.org/api/sheets/v4"
+)
+
+// columnCellFields are the parts of a cell that are captured before a column is changed and written back on undo
+const columnCellFields = "userEnteredValue,userEnteredFormat,note,dataValidation"
+
+// JournalEntry records a change applied by a run together with what reverses it. The entry is recorded as
+// pending before the change is applied and completed once it succeeds, so a change that fails partway stays
+// pending and can still be undone.
+type JournalEntry struct {
+	Change        string            `json:"change"` // Path of the change, e.g. "orders.amount"
+	Description   string            `json:"description"`
+	SpreadsheetID string            `json:"spreadsheetId"`
+	SheetID       int64             `json:"sheetId,omitempty"`
+	HeaderRow     int               `json:"headerRow,omitempty"`
+	Inverse       []*sheets.Request `json:"inverse,omitempty"` // Requests that undo the change
+	Objects       *SheetObjects     `json:"objects,omitempty"` // Sheet objects as they were before the change, put back on undo
+	Headers       []string          `json:"headers"`           // Header row before a change that inserts or deletes a column, nil for other changes
+	Pending       bool              `json:"pending,omitempty"` // The change was not completed, so it may be partly applied
+	Undone        bool              `json:"undone,omitempty"`
+}
+
+// undoable reports whether the entry records how to undo its change
+func (e *JournalEntry) undoable() bool {
+	return len(e.Inverse) > 0 || e.Objects != nil
+}
+
+// LatestRun reads the record of the most recent run in dir
+func LatestRun(dir string) (*Run, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "runs", "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no runs found in %s", dir)
+	}
+
+	// Run IDs start with their start time, so the last name is the latest run
+	slices.Sort(files)
+	return LoadRun(dir, strings.TrimSuffix(filepath.Base(files[len(files)-1]), ".json"))
+}
+
+// deleteColumnRequests builds the request that undoes adding a column
+func deleteColumnRequests(sheetID int64, column int) []*sheets.Request {
+	return []*sheets.Request{{
+		DeleteDimension: &sheets.DeleteDimensionRequest{
+			Range: &sheets.DimensionRange{
+				SheetId:    sheetID,
+				Dimension:  "COLUMNS",
+				StartIndex: int64(column),
+				EndIndex:   int64(column + 1),
+			},
+		},
+	}}
+}
+
+// restoreColumnRequests builds the requests that write a column back as grid captured it: its cells and
+// its width and visibility. With insert, the column is first inserted again, which undoes deleting it.
+func restoreColumnRequests(sheetID int64, column int, grid *sheets.GridData, insert bool) []*sheets.Request {
+	requests := []*sheets.Request{}
+	columnRange := &sheets.DimensionRange{
+		SheetId:    sheetID,
+		Dimension:  "COLUMNS",
+		StartIndex: int64(column),
+		EndIndex:   int64(column + 1),
+	}
+	if insert {
+		requests = append(requests, &sheets.Request{
+			InsertDimension: &sheets.InsertDimensionRequest{Range: columnRange},
+		})
+	}
+	if grid == nil {
+		return requests
+	}
+
+	if len(grid.RowData) > 0 {
+		requests = append(requests, &sheets.Request{
+			UpdateCells: &sheets.UpdateCellsRequest{
+				Start: &sheets.GridCoordinate{
+					SheetId:     sheetID,
+					RowIndex:    grid.StartRow,
+					ColumnIndex: int64(column),
+				},
+				Rows:   grid.RowData,
+				Fields: columnCellFields,
+			},
+		})
+	}
+	if len(grid.ColumnMetadata) > 0 && grid.ColumnMetadata[0] != nil {
+		properties := grid.ColumnMetadata[0]
+		requests = append(requests, &sheets.Request{
+			UpdateDimensionProperties: &sheets.UpdateDimensionPropertiesRequest{
+				Range: columnRange,
+				Properties: &sheets.DimensionProperties{
+					PixelSize:       properties.PixelSize,
+					HiddenByUser:    properties.HiddenByUser,
+					ForceSendFields: []string{"HiddenByUser"},
+				},
+				Fields: "pixelSize,hiddenByUser",
+			},
+		})
+	}
+
+	// Deleting the column also deleted its ID tag, so a column inserted again is tagged again
+	if entries := columnIDEntries(grid); insert && len(entries) > 0 && entries[0] != nil {
+		requests = append(requests, columnIDRequest(sheetID, column, entries[0].MetadataValue, nil))
+	}
+	return requests
+}
+
+// capturedCells returns n cells of a column from the 0-based row first down, one per row, as grid
+// captured them. Rows that grid does not cover get an empty cell, which clears the cell when written.
+func capturedCells(grid *sheets.GridData, first, n int) []*sheets.RowData {
+	rows := make([]*sheets.RowData, n)
+	for i := range rows {
+		rows[i] = &sheets.RowData{Values: []*sheets.CellData{{}}}
+		if grid == nil {
+			continue
+		}
+		captured := first + i - int(grid.StartRow)
+		if captured >= 0 && captured < len(grid.RowData) && grid.RowData[captured] != nil && len(grid.RowData[captured].Values) > 0 {
+			rows[i] = &sheets.RowData{Values: grid.RowData[captured].Values[:1]}
+		}
+	}
+	return rows
+}
+
+// restoreFieldRequests builds the requests that undo modifying a field's column. Only what the modification
+// alters is written back: the text and note of the header cell, the number format of the data cells and the
+// column's visibility, so that values entered in the column since are kept. grid holds the whole column.
+func restoreFieldRequests(sheetID int64, column, headerRow int, grid *sheets.GridData, fieldDiff FieldDiff) []*sheets.Request {
+	requests := []*sheets.Request{}
+	if fieldDiff.OldHeader != fieldDiff.NewHeader || fieldDiff.OldNote != fieldDiff.NewNote {
+		requests = append(requests, &sheets.Request{
+			UpdateCells: &sheets.UpdateCellsRequest{
+				Start:  &sheets.GridCoordinate{SheetId: sheetID, RowIndex: int64(headerRow - 1), ColumnIndex: int64(column)},
+				Rows:   capturedCells(grid, headerRow-1, 1),
+				Fields: "userEnteredValue,note",
+			},
+		})
+	}
+
+	if fieldDiff.OldType != fieldDiff.NewType || fieldDiff.OldFormat != fieldDiff.NewFormat ||
+		fieldDiff.OldPattern != fieldDiff.NewPattern || fieldDiff.OldPatternType != fieldDiff.NewPatternType {
+		// The column is formatted from its second row down. The captured rows get their format back
+		// and the rows below them, which had none, are cleared.
+		first, n := 1, 0
+		if grid != nil {
+			n = max(int(grid.StartRow)+len(grid.RowData)-first, 0)
+		}
+		if n > 0 {
+			requests = append(requests, &sheets.Request{
+				UpdateCells: &sheets.UpdateCellsRequest{
+					Start:  &sheets.GridCoordinate{SheetId: sheetID, RowIndex: int64(first), ColumnIndex: int64(column)},
+					Rows:   capturedCells(grid, first, n),
+					Fields: "userEnteredFormat.numberFormat",
+				},
+			})
+		}
+		requests = append(requests, &sheets.Request{
+			RepeatCell: &sheets.RepeatCellRequest{
+				Range: &sheets.GridRange{
+					SheetId:          sheetID,
+					StartRowIndex:    int64(first + n),
+					StartColumnIndex: int64(column),
+					EndColumnIndex:   int64(column + 1),
+				},
+				Cell:   &sheets.CellData{},
+				Fields: "userEnteredFormat.numberFormat",
+			},
+		})
+	}
+
+	if fieldDiff.OldHidden != fieldDiff.NewHidden {
+		requests = append(requests, &sheets.Request{
+			UpdateDimensionProperties: &sheets.UpdateDimensionPropertiesRequest{
+				Range: &sheets.DimensionRange{
+					SheetId:    sheetID,
+					Dimension:  "COLUMNS",
+					StartIndex: int64(column),
+					EndIndex:   int64(column + 1),
+				},
+				Properties: &sheets.DimensionProperties{HiddenByUser: fieldDiff.OldHidden, ForceSendFields: []string{"HiddenByUser"}},
+				Fields:     "hiddenByUser",
+			},
+		})
+	}
+	return requests
+}
+
+// restoreValuesRequests builds the requests that write back the values of the first n data cells of a
+// column as grid captured it, clearing those that were empty. grid holds the whole column.
+func restoreValuesRequests(sheetID int64, column, headerRow int, grid *sheets.GridData, n int) []*sheets.Request {
+	if n == 0 {
+		return nil
+	}
+	return []*sheets.Request{{
+		UpdateCells: &sheets.UpdateCellsRequest{
+			Start:  &sheets.GridCoordinate{SheetId: sheetID, RowIndex: int64(headerRow), ColumnIndex: int64(column)},
+			Rows:   capturedCells(grid, headerRow, n),
+			Fields: "userEnteredValue",
+		},
+	}}
+}
+
+// restoreStyleRequests builds the requests that undo styling cells, writing back the formats grid captured.
+// For a field, grid holds its whole column and the cells below the header are restored, as well as the column's
+// width when the style set it. For the header row, column is -1 and grid holds the header row.
+func restoreStyleRequests(sheetID int64, column, headerRow int, grid *sheets.GridData, width bool) []*sheets.Request {
+	if column == -1 {
+		var cells []*sheets.CellData
+		if grid != nil && len(grid.RowData) > 0 && grid.RowData[0] != nil {
+			cells = grid.RowData[0].Values
+		}
+		requests := []*sheets.Request{}
+		if len(cells) > 0 {
+			requests = append(requests, &sheets.Request{
+				UpdateCells: &sheets.UpdateCellsRequest{
+					Start:  &sheets.GridCoordinate{SheetId: sheetID, RowIndex: int64(headerRow - 1)},
+					Rows:   []*sheets.RowData{{Values: cells}},
+					Fields: "userEnteredFormat",
+				},
+			})
+		}
+		// Cells to the right of the captured ones had no format
+		return append(requests, &sheets.Request{
+			RepeatCell: &sheets.RepeatCellRequest{
+				Range: &sheets.GridRange{
+					SheetId:          sheetID,
+					StartRowIndex:    int64(headerRow - 1),
+					EndRowIndex:      int64(headerRow),
+					StartColumnIndex: int64(len(cells)),
+				},
+				Cell:   &sheets.CellData{},
+				Fields: "userEnteredFormat",
+			},
+		})
+	}
+
+	first, n := headerRow, 0
+	if grid != nil {
+		n = max(int(grid.StartRow)+len(grid.RowData)-first, 0)
+	}
+	requests := []*sheets.Request{}
+	if n > 0 {
+		requests = append(requests, &sheets.Request{
+			UpdateCells: &sheets.UpdateCellsRequest{
+				Start:  &sheets.GridCoordinate{SheetId: sheetID, RowIndex: int64(first), ColumnIndex: int64(column)},
+				Rows:   capturedCells(grid, first, n),
+				Fields: "userEnteredFormat",
+			},
+		})
+	}
+	requests = append(requests, &sheets.Request{
+		RepeatCell: &sheets.RepeatCellRequest{
+			Range: &sheets.GridRange{
+				SheetId:          sheetID,
+				StartRowIndex:    int64(first + n),
+				StartColumnIndex: int64(column),
+				EndColumnIndex:   int64(column + 1),
+			},
+			Cell:   &sheets.CellData{},
+			Fields: "userEnteredFormat",
+		},
+	})
+	if width && grid != nil && len(grid.ColumnMetadata) > 0 && grid.ColumnMetadata[0] != nil {
+		requests = append(requests, &sheets.Request{
+			UpdateDimensionProperties: &sheets.UpdateDimensionPropertiesRequest{
+				Range: &sheets.DimensionRange{
+					SheetId:    sheetID,
+					Dimension:  "COLUMNS",
+					StartIndex: int64(column),
+					EndIndex:   int64(column + 1),
+				},
+				Properties: &sheets.DimensionProperties{PixelSize: grid.ColumnMetadata[0].PixelSize},
+				Fields:     "pixelSize",
+			},
+		})
+	}
+	return requests
+}
+
+// restoreColumnIDsRequests builds the requests that undo tagging the columns in names with their field IDs:
+// tags that get another ID are given their previous one back and tags that are created are deleted again.
+// grid holds the header row with the columns' metadata.
+func restoreColumnIDsRequests(resource schema.Resource, sheetID int64, names []string, grid *sheets.GridData) []*sheets.Request {
+	entries := columnIDEntries(grid)
+	_, fields := ColumnIDRequests(resource, sheetID, names, grid)
+	requests := []*sheets.Request{}
+	for _, field := range fields {
+		column := slices.Index(names, field)
+		if column < len(entries) && entries[column] != nil {
+			requests = append(requests, columnIDRequest(sheetID, column, entries[column].MetadataValue, entries[column]))
+			continue
+		}
+		requests = append(requests, &sheets.Request{
+			DeleteDeveloperMetadata: &sheets.DeleteDeveloperMetadataRequest{
+				DataFilter: &sheets.DataFilter{
+					DeveloperMetadataLookup: &sheets.DeveloperMetadataLookup{
+						MetadataKey: columnIDMetadataKey,
+						MetadataLocation: &sheets.DeveloperMetadataLocation{
+							DimensionRange: &sheets.DimensionRange{
+								SheetId:    sheetID,
+								Dimension:  "COLUMNS",
+								StartIndex: int64(column),
+								EndIndex:   int64(column + 1),
+							},
+						},
+						LocationMatchingStrategy: "EXACT_LOCATION",
+					},
+				},
+			},
+		})
+	}
+	return requests
+}
+
+// reverseMoveRequests builds the requests that undo a sequence of column moves, last move first
+func reverseMoveRequests(sheetID int64, moves []ColumnMove) []*sheets.Request {
+	requests := make([]*sheets.Request, 0, len(moves))
+	for i := len(moves) - 1; i >= 0; i-- {
+		requests = append(requests, sheet.MoveColumnRequest(sheetID, moves[i].To, moves[i].From))
+	}
+	return requests
+}
+
+// journalEntry captures what a change is about to alter and builds the entry that undoes it. Changes to
+// columns and cells are undone by requests built now, changes to the sheet's objects such as its conditional
+// formats or named ranges by putting back a snapshot of them.
+func (a *Applier) journalEntry(ctx context.Context, resource *schema.Resource, spreadsheetID string, change Change, layout *ColumnLayout) (*JournalEntry, error) {
+	sheetName := resource.Name
+	headerRow := resource.HeaderRow
+	if headerRow == 0 {
+		headerRow = 1
+	}
+
+	spreadsheet, err := a.sheetClient.GetSpreadsheet(ctx, spreadsheetID)
+	if err != nil {
+		return nil, err
+	}
+	var sheetMeta *sheets.Sheet
+	for _, tab := range spreadsheet.Sheets {
+		if tab.Properties.Title == sheetName {
+			sheetMeta = tab
+			break
+		}
+	}
+	if sheetMeta == nil {
+		return nil, fmt.Errorf("sheet %s not found", sheetName)
+	}
+	sheetID := sheetMeta.Properties.SheetId
+	entry := &JournalEntry{
+		Change:        change.Path,
+		Description:   change.Description,
+		SpreadsheetID: spreadsheetID,
+		SheetID:       sheetID,
+		HeaderRow:     headerRow,
+	}
+	capture := func(readRange string) (*sheets.GridData, error) {
+		grid, err := a.sheetClient.GetGridData(ctx, spreadsheetID, sheetName, readRange)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", readRange, err)
+		}
+		return grid, nil
+	}
+	captureColumn := func(index int) (*sheets.GridData, error) {
+		letter := sheet.ColumnToLetter(index)
+		return capture(fmt.Sprintf("%s:%s", letter, letter))
+	}
+
+	switch value := change.NewValue.(type) {
+	case SheetPropertiesDiff:
+		entry.Inverse = []*sheets.Request{inverseSheetPropertiesRequest(value, sheetMeta.Properties)}
+		return entry, nil
+	case KeyRuleDiff, ConditionalFormatsDiff:
+		entry.Objects = captureObjects(sheetMeta, spreadsheet.NamedRanges, ObjectConditionalFormats)
+		return entry, nil
+	case TableDiff:
+		entry.Objects = captureObjects(sheetMeta, spreadsheet.NamedRanges, ObjectTable)
+		return entry, nil
+	case NamedRangesDiff:
+		entry.Objects = captureObjects(sheetMeta, spreadsheet.NamedRanges, ObjectNamedRanges)
+		return entry, nil
+	case ColumnIDsDiff:
+		grid, err := capture(fmt.Sprintf("%d:%d", headerRow, headerRow))
+		if err != nil {
+			return nil, err
+		}
+		entry.Inverse = restoreColumnIDsRequests(*resource, sheetID, layout.Names, grid)
+		return entry, nil
+	case StyleDiff:
+		if value.Field == "" {
+			grid, err := capture(fmt.Sprintf("%d:%d", headerRow, headerRow))
+			if err != nil {
+				return nil, err
+			}
+			entry.Inverse = restoreStyleRequests(sheetID, -1, headerRow, grid, false)
+			return entry, nil
+		}
+		index, err := layout.column(value.Field)
+		if err != nil {
+			return nil, err
+		}
+		grid, err := captureColumn(index)
+		if err != nil {
+			return nil, err
+		}
+		entry.Inverse = restoreStyleRequests(sheetID, index, headerRow, grid, value.Style.Width != nil)
+		return entry, nil
+	case FormulaDiff:
+		// Removing a formula only removes its protection, writing one also overwrites the column's values
+		entry.Objects = captureObjects(sheetMeta, spreadsheet.NamedRanges, ObjectProtectedRanges)
+		if value.Type == ChangeTypeRemove {
+			return entry, nil
+		}
+		index, err := layout.column(value.Field)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := a.sheetClient.GetFormulas(ctx, spreadsheetID, sheetName)
+		if err != nil {
+			return nil, err
+		}
+		grid, err := captureColumn(index)
+		if err != nil {
+			return nil, err
+		}
+		n := 0
+		if len(rows) >= headerRow {
+			n = len(ExpectedFormulas(value.Formula, rows[headerRow:], index, headerRow+1))
+		}
+		entry.Inverse = restoreValuesRequests(sheetID, index, headerRow, grid, n)
+		return entry, nil
+	}
+
+	switch change.Type {
+	case ChangeTypeAdd:
+		fieldInfo, ok := change.NewValue.(FieldInfo)
+		if !ok {
+			return nil, fmt.Errorf("invalid field info in change")
+		}
+		index := slices.IndexFunc(resource.Fields, func(f schema.Field) bool { return f.Name == fieldInfo.Name })
+		if index == -1 {
+			return nil, fmt.Errorf("field %s not found in schema", fieldInfo.Name)
+		}
+		if entry.Headers, err = a.sheetClient.GetHeaders(ctx, spreadsheetID, sheetName, headerRow); err != nil {
+			return nil, err
+		}
+		entry.Inverse = deleteColumnRequests(sheetID, insertIndex(*resource, layout.Names, index))
+	case ChangeTypeRemove:
+		fieldInfo, ok := change.OldValue.(FieldInfo)
+		if !ok {
+			return nil, fmt.Errorf("invalid field info in change")
+		}
+		index, err := layout.column(fieldInfo.Name)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Headers, err = a.sheetClient.GetHeaders(ctx, spreadsheetID, sheetName, headerRow); err != nil {
+			return nil, err
+		}
+		grid, err := captureColumn(index)
+		if err != nil {
+			return nil, err
+		}
+		entry.Inverse = restoreColumnRequests(sheetID, index, grid, true)
+	case ChangeTypeModify:
+		fieldDiff, ok := change.NewValue.(FieldDiff)
+		if !ok {
+			return nil, fmt.Errorf("invalid field diff in change")
+		}
+		index, err := layout.column(fieldDiff.Name)
+		if err != nil {
+			return nil, err
+		}
+		grid, err := captureColumn(index)
+		if err != nil {
+			return nil, err
+		}
+		entry.Inverse = restoreFieldRequests(sheetID, index, headerRow, grid, fieldDiff)
+	case ChangeTypeReorder:
+		entry.Inverse = reverseMoveRequests(sheetID, reorderMoves(*resource, layout.Names))
+	}
+	return entry, nil
+}
+
+// Undo reverses the changes journaled by a run, last change first. Each entry is marked as undone
+// as soon as it is reversed, so an interrupted undo can be resumed. Changes without an inverse, journaled by
+// older versions, are skipped and reported. The lock on the spreadsheets is held meanwhile, so that the undo
+// cannot interleave with an apply.
+func (a *Applier) Undo(ctx context.Context, run *Run) (skipped []string, err error) {
+	locks, err := a.lockSpreadsheets(ctx, run.spreadsheetIDs())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = errors.Join(err, a.releaseLocks(ctx, locks))
+	}()
+
+	skipped = []string{}
+	for i := len(run.Journal) - 1; i >= 0; i-- {
+		entry := &run.Journal[i]
+		if entry.Undone {
+			continue
+		}
+		if !entry.undoable() {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", entry.Change, entry.Description))
+			continue
+		}
+		if err := a.undoEntry(ctx, entry); err != nil {
+			return skipped, fmt.Errorf("failed to undo %s: %w", entry.Change, err)
+		}
+		entry.Undone = true
+		if err := run.Save(); err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
+
+// undoEntry reverses a journaled change. A pending change that inserts or deletes a column is only reversed
+// when the header row shows that it got that far, since reversing it otherwise would delete or insert
+// another column.
+func (a *Applier) undoEntry(ctx context.Context, entry *JournalEntry) error {
+	if entry.Pending && entry.Headers != nil {
+		sheetMeta, _, err := a.sheetByID(ctx, entry.SpreadsheetID, entry.SheetID)
+		if err != nil {
+			return err
+		}
+		headers, err := a.sheetClient.GetHeaders(ctx, entry.SpreadsheetID, sheetMeta.Properties.Title, entry.HeaderRow)
+		if err != nil {
+			return err
+		}
+		if slices.Equal(headers, entry.Headers) {
+			fmt.Printf("Nothing to undo for %s: %s, which failed before changing the columns\n", entry.Change, entry.Description)
+			return nil
+		}
+	}
+
+	if err := a.sheetClient.BatchUpdate(ctx, entry.SpreadsheetID, entry.Inverse); err != nil {
+		return err
+	}
+	if entry.Objects != nil {
+		if err := a.restoreObjects(ctx, entry.SpreadsheetID, entry.SheetID, entry.Objects); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Undid %s: %s\n", entry.Change, entry.Description)
+	return nil
+}
+
+// sheetByID looks up a sheet of a spreadsheet by its ID and also returns the spreadsheet's named ranges
+func (a *Applier) sheetByID(ctx context.Context, spreadsheetID string, sheetID int64) (*sheets.Sheet, []*sheets.NamedRange, error) {
+	spreadsheet, err := a.sheetClient.GetSpreadsheet(ctx, spreadsheetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tab := range spreadsheet.Sheets {
+		if tab.Properties.SheetId == sheetID {
+			return tab, spreadsheet.NamedRanges, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("sheet %d no longer exists", sheetID)
+}
+
+// restoreObjects puts the objects of a sheet back as a snapshot holds them
+func (a *Applier) restoreObjects(ctx context.Context, spreadsheetID string, sheetID int64, objects *SheetObjects) error {
+	sheetMeta, namedRanges, err := a.sheetByID(ctx, spreadsheetID, sheetID)
+	if err != nil {
+		return err
+	}
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, restoreObjectsRequests(sheetMeta, namedRanges, objects)); err != nil {
+		return fmt.Errorf("failed to restore the objects of the sheet: %w", err)
+	}
+	return nil
+}
+
+// recordJournal appends the entry of a change that is about to be applied to the run's journal as pending
+// and saves it right away, so that the journal covers the change even when applying it fails partway
+func (a *Applier) recordJournal(entry *JournalEntry) error {
+	entry.Pending = true
+	a.run.Journal = append(a.run.Journal, *entry)
+	return a.run.Save()
+}
+
+// completeJournal marks the last entry of the run's journal as applied
+func (a *Applier) completeJournal() error {
+	a.run.Journal[len(a.run.Journal)-1].Pending = false
+	return a.run.Save()
+}
@@ -0,0 +1,297 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestDeleteColumnRequests(t *testing.T) {
+	requests := deleteColumnRequests(7, 2)
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	r := requests[0].DeleteDimension
+	if r == nil || r.Range.SheetId != 7 || r.Range.Dimension != "COLUMNS" || r.Range.StartIndex != 2 || r.Range.EndIndex != 3 {
+		t.Errorf("expected column C to be deleted, got %+v", requests[0])
+	}
+}
+
+func TestRestoreColumnRequests(t *testing.T) {
+	one := 1.0
+	grid := &sheets.GridData{
+		RowData: []*sheets.RowData{
+			{Values: []*sheets.CellData{{Note: "header"}}},
+			{Values: []*sheets.CellData{{UserEnteredValue: &sheets.ExtendedValue{NumberValue: &one}}}},
+		},
+		ColumnMetadata: []*sheets.DimensionProperties{{PixelSize: 120, HiddenByUser: true}},
+	}
+	tagged := &sheets.GridData{
+		RowData: grid.RowData,
+		ColumnMetadata: []*sheets.DimensionProperties{{
+			PixelSize:         120,
+			HiddenByUser:      true,
+			DeveloperMetadata: []*sheets.DeveloperMetadata{{MetadataId: 5, MetadataKey: columnIDMetadataKey, MetadataValue: "amount"}},
+		}},
+	}
+
+	tests := []struct {
+		name   string
+		grid   *sheets.GridData
+		insert bool
+		want   []string
+	}{
+		{name: "removed column", grid: grid, insert: true, want: []string{"insert", "cells", "properties"}},
+		{name: "removed tagged column", grid: tagged, insert: true, want: []string{"insert", "cells", "properties", "tag"}},
+		{name: "modified column", grid: tagged, insert: false, want: []string{"cells", "properties"}},
+		{name: "nothing captured", grid: nil, insert: true, want: []string{"insert"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := restoreColumnRequests(7, 3, tt.grid, tt.insert)
+			if len(requests) != len(tt.want) {
+				t.Fatalf("expected %d requests, got %d", len(tt.want), len(requests))
+			}
+			for i, kind := range tt.want {
+				r := requests[i]
+				switch kind {
+				case "insert":
+					if r.InsertDimension == nil || r.InsertDimension.Range.StartIndex != 3 || r.InsertDimension.Range.EndIndex != 4 {
+						t.Errorf("request %d: expected column D to be inserted, got %+v", i, r)
+					}
+				case "cells":
+					if r.UpdateCells == nil || r.UpdateCells.Start.SheetId != 7 || r.UpdateCells.Start.ColumnIndex != 3 ||
+						len(r.UpdateCells.Rows) != 2 || r.UpdateCells.Fields != columnCellFields {
+						t.Errorf("request %d: expected the captured cells to be written back, got %+v", i, r)
+					}
+				case "properties":
+					p := r.UpdateDimensionProperties
+					if p == nil || p.Range.StartIndex != 3 || p.Properties.PixelSize != 120 || !p.Properties.HiddenByUser {
+						t.Errorf("request %d: expected the width and visibility to be restored, got %+v", i, r)
+					}
+				case "tag":
+					c := r.CreateDeveloperMetadata
+					if c == nil || c.DeveloperMetadata.MetadataKey != columnIDMetadataKey || c.DeveloperMetadata.MetadataValue != "amount" ||
+						c.DeveloperMetadata.Location.DimensionRange.StartIndex != 3 {
+						t.Errorf("request %d: expected column D to be tagged with amount again, got %+v", i, r)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreFieldRequests(t *testing.T) {
+	text := "Amount"
+	grid := &sheets.GridData{
+		RowData: []*sheets.RowData{
+			{Values: []*sheets.CellData{{UserEnteredValue: &sheets.ExtendedValue{StringValue: &text}, Note: "old note"}}},
+			{Values: []*sheets.CellData{{UserEnteredFormat: &sheets.CellFormat{NumberFormat: &sheets.NumberFormat{Type: "NUMBER", Pattern: "0"}}}}},
+			{},
+		},
+	}
+
+	tests := []struct {
+		name string
+		diff FieldDiff
+		want []string
+	}{
+		{name: "header renamed", diff: FieldDiff{OldHeader: "Amount", NewHeader: "amount"}, want: []string{"header"}},
+		{name: "number format changed", diff: FieldDiff{OldPattern: "0", NewPattern: "0.00"}, want: []string{"formats", "clear"}},
+		{name: "column hidden", diff: FieldDiff{NewHidden: true}, want: []string{"visibility"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := restoreFieldRequests(7, 3, 1, grid, tt.diff)
+			if len(requests) != len(tt.want) {
+				t.Fatalf("expected %d requests, got %d", len(tt.want), len(requests))
+			}
+			for i, kind := range tt.want {
+				r := requests[i]
+				switch kind {
+				case "header":
+					u := r.UpdateCells
+					if u == nil || u.Start.RowIndex != 0 || u.Start.ColumnIndex != 3 || len(u.Rows) != 1 ||
+						u.Rows[0].Values[0].Note != "old note" || u.Fields != "userEnteredValue,note" {
+						t.Errorf("request %d: expected the header cell to be restored, got %+v", i, r)
+					}
+				case "formats":
+					u := r.UpdateCells
+					if u == nil || u.Start.RowIndex != 1 || len(u.Rows) != 2 || u.Fields != "userEnteredFormat.numberFormat" ||
+						u.Rows[0].Values[0].UserEnteredFormat.NumberFormat.Pattern != "0" || len(u.Rows[1].Values) != 1 {
+						t.Errorf("request %d: expected only the number formats of the data cells to be restored, got %+v", i, r)
+					}
+				case "clear":
+					c := r.RepeatCell
+					if c == nil || c.Range.StartRowIndex != 3 || c.Range.StartColumnIndex != 3 || c.Fields != "userEnteredFormat.numberFormat" {
+						t.Errorf("request %d: expected the number format below the captured rows to be cleared, got %+v", i, r)
+					}
+				case "visibility":
+					p := r.UpdateDimensionProperties
+					if p == nil || p.Properties.HiddenByUser || p.Fields != "hiddenByUser" {
+						t.Errorf("request %d: expected the column to be shown again, got %+v", i, r)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreValuesRequests(t *testing.T) {
+	one := 1.0
+	grid := &sheets.GridData{
+		RowData: []*sheets.RowData{
+			{Values: []*sheets.CellData{{Note: "header"}}},
+			{Values: []*sheets.CellData{{UserEnteredValue: &sheets.ExtendedValue{NumberValue: &one}}}},
+		},
+	}
+
+	if requests := restoreValuesRequests(7, 2, 1, grid, 0); len(requests) != 0 {
+		t.Errorf("expected no requests when nothing is written, got %+v", requests)
+	}
+
+	requests := restoreValuesRequests(7, 2, 1, grid, 3)
+	if len(requests) != 1 || requests[0].UpdateCells == nil {
+		t.Fatalf("expected 1 UpdateCells request, got %+v", requests)
+	}
+	u := requests[0].UpdateCells
+	if u.Start.RowIndex != 1 || u.Start.ColumnIndex != 2 || u.Fields != "userEnteredValue" || len(u.Rows) != 3 {
+		t.Fatalf("expected 3 data cells of column C to be written, got %+v", u)
+	}
+	if u.Rows[0].Values[0].UserEnteredValue == nil || u.Rows[1].Values[0].UserEnteredValue != nil || u.Rows[2].Values[0].UserEnteredValue != nil {
+		t.Errorf("expected the captured value followed by cleared cells, got %+v", u.Rows)
+	}
+}
+
+func TestRestoreStyleRequests(t *testing.T) {
+	grid := &sheets.GridData{
+		RowData: []*sheets.RowData{
+			{Values: []*sheets.CellData{{}}},
+			{Values: []*sheets.CellData{{UserEnteredFormat: &sheets.CellFormat{TextFormat: &sheets.TextFormat{Bold: true}}}}},
+			{},
+		},
+		ColumnMetadata: []*sheets.DimensionProperties{{PixelSize: 140}},
+	}
+
+	t.Run("field", func(t *testing.T) {
+		requests := restoreStyleRequests(7, 2, 1, grid, true)
+		if len(requests) != 3 {
+			t.Fatalf("expected 3 requests, got %d", len(requests))
+		}
+		cells := requests[0].UpdateCells
+		if cells == nil || cells.Start.RowIndex != 1 || cells.Start.ColumnIndex != 2 || len(cells.Rows) != 2 || cells.Fields != "userEnteredFormat" ||
+			!cells.Rows[0].Values[0].UserEnteredFormat.TextFormat.Bold {
+			t.Errorf("expected the formats of the data cells to be written back, got %+v", requests[0])
+		}
+		clear := requests[1].RepeatCell
+		if clear == nil || clear.Range.StartRowIndex != 3 || clear.Range.StartColumnIndex != 2 || clear.Fields != "userEnteredFormat" {
+			t.Errorf("expected the format below the captured rows to be cleared, got %+v", requests[1])
+		}
+		width := requests[2].UpdateDimensionProperties
+		if width == nil || width.Range.StartIndex != 2 || width.Properties.PixelSize != 140 || width.Fields != "pixelSize" {
+			t.Errorf("expected the width to be restored, got %+v", requests[2])
+		}
+	})
+
+	t.Run("field without width", func(t *testing.T) {
+		if requests := restoreStyleRequests(7, 2, 1, grid, false); len(requests) != 2 {
+			t.Errorf("expected 2 requests, got %d", len(requests))
+		}
+	})
+
+	t.Run("header row", func(t *testing.T) {
+		header := &sheets.GridData{RowData: []*sheets.RowData{{Values: []*sheets.CellData{{}, {}}}}}
+		requests := restoreStyleRequests(7, -1, 2, header, false)
+		if len(requests) != 2 {
+			t.Fatalf("expected 2 requests, got %d", len(requests))
+		}
+		cells := requests[0].UpdateCells
+		if cells == nil || cells.Start.RowIndex != 1 || cells.Start.ColumnIndex != 0 || len(cells.Rows[0].Values) != 2 {
+			t.Errorf("expected the header cells to be written back, got %+v", requests[0])
+		}
+		clear := requests[1].RepeatCell
+		if clear == nil || clear.Range.StartRowIndex != 1 || clear.Range.EndRowIndex != 2 || clear.Range.StartColumnIndex != 2 {
+			t.Errorf("expected the header cells to the right to be cleared, got %+v", requests[1])
+		}
+	})
+}
+
+func TestRestoreColumnIDsRequests(t *testing.T) {
+	resource := schema.Resource{Name: "orders", Fields: []schema.Field{{Name: "id", ID: "id"}, {Name: "amount", ID: "amount"}, {Name: "note", ID: "note"}}}
+	grid := &sheets.GridData{
+		ColumnMetadata: []*sheets.DimensionProperties{
+			{DeveloperMetadata: []*sheets.DeveloperMetadata{{MetadataId: 4, MetadataKey: columnIDMetadataKey, MetadataValue: "id"}}},
+			{DeveloperMetadata: []*sheets.DeveloperMetadata{{MetadataId: 5, MetadataKey: columnIDMetadataKey, MetadataValue: "total"}}},
+			{},
+		},
+	}
+
+	requests := restoreColumnIDsRequests(resource, 7, []string{"id", "amount", "note"}, grid)
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	update := requests[0].UpdateDeveloperMetadata
+	if update == nil || update.DataFilters[0].DeveloperMetadataLookup.MetadataId != 5 || update.DeveloperMetadata.MetadataValue != "total" {
+		t.Errorf("expected the tag of column B to get its previous ID back, got %+v", requests[0])
+	}
+	remove := requests[1].DeleteDeveloperMetadata
+	if remove == nil || remove.DataFilter.DeveloperMetadataLookup.MetadataKey != columnIDMetadataKey ||
+		remove.DataFilter.DeveloperMetadataLookup.MetadataLocation.DimensionRange.StartIndex != 2 {
+		t.Errorf("expected the tag created on column C to be deleted, got %+v", requests[1])
+	}
+}
+
+func TestReverseMoveRequests(t *testing.T) {
+	moves := []ColumnMove{{Field: "a", From: 0, To: 2}, {Field: "b", From: 3, To: 1}}
+	requests := reverseMoveRequests(7, moves)
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+
+	// The last move is undone first, moving b from 1 back to 3
+	first := requests[0].MoveDimension
+	if first == nil || first.Source.StartIndex != 1 {
+		t.Errorf("expected b to be moved back first, got %+v", requests[0])
+	}
+	second := requests[1].MoveDimension
+	if second == nil || second.Source.StartIndex != 2 {
+		t.Errorf("expected a to be moved back last, got %+v", requests[1])
+	}
+}
+
+func TestLatestRun(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LatestRun(dir); err == nil {
+		t.Error("expected an error when there are no runs")
+	}
+
+	older := NewRun(dir, time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC))
+	newer := NewRun(dir, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	newer.Journal = append(newer.Journal, JournalEntry{
+		Change:        "orders.amount",
+		Description:   "Add field amount",
+		SpreadsheetID: "abc",
+		Inverse:       deleteColumnRequests(7, 2),
+	})
+	for _, run := range []*Run{newer, older} {
+		if err := run.Save(); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+	}
+
+	latest, err := LatestRun(dir)
+	if err != nil {
+		t.Fatalf("LatestRun() error: %v", err)
+	}
+	if latest.ID != newer.ID {
+		t.Fatalf("LatestRun() = %s, want %s", latest.ID, newer.ID)
+	}
+	if len(latest.Journal) != 1 || latest.Journal[0].Change != "orders.amount" || latest.Journal[0].Undone ||
+		latest.Journal[0].Inverse[0].DeleteDimension.Range.StartIndex != 2 {
+		t.Errorf("expected the journal to round trip, got %+v", latest.Journal)
+	}
+}
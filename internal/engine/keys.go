@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/sheet"
+	"google.golang.org/api/sheets/v4"
+)
+
+// keyRuleMarker tags the conditional format rule that ss-migrate manages for a primary key.
+// It is embedded in the rule's formula as N("...") which always evaluates to 0, followed by
+// the key fields as a JSON list.
+const keyRuleMarker = "ss-migrate:primaryKey:"
+
+// KeyRuleDiff represents a change to the duplicate/blank highlight of a primary key
+type KeyRuleDiff struct {
+	Type      ChangeType
+	OldFields []string
+	NewFields []string
+}
+
+// KeyRule is the primary key highlight rule currently present in a sheet
+type KeyRule struct {
+	Fields  []string
+	Formula string
+}
+
+// KeyViolation describes a data row whose primary key is blank or duplicated
+type KeyViolation struct {
+	Row         int // Row number in the sheet (1-based)
+	Key         []string
+	Blank       bool
+	DuplicateOf []int // Other rows with the same key
+}
+
+// KeyRuleFormula builds the custom formula that flags blank and duplicate keys.
+// columns are the 0-based key column indexes and firstDataRow is the 1-based row below the header.
+// Keys are compared with EXACT, like FindKeyViolations does, rather than with COUNTIFS, which
+// ignores case and reads *, ? and ~ as wildcards and a leading < or > as a condition.
+func KeyRuleFormula(fields []string, columns []int, firstDataRow int) string {
+	blanks := make([]string, 0, len(columns))
+	matches := make([]string, 0, len(columns))
+	for _, col := range columns {
+		letter := sheet.ColumnToLetter(col)
+		blanks = append(blanks, fmt.Sprintf("LEN(TRIM($%s%d))=0", letter, firstDataRow))
+		matches = append(matches, fmt.Sprintf("EXACT($%s$%d:$%s,$%s%d)", letter, firstDataRow, letter, letter, firstDataRow))
+	}
+
+	encoded, _ := json.Marshal(fields)
+	escaped := strings.ReplaceAll(string(encoded), `"`, `""`)
+	return fmt.Sprintf(`=AND(N("%s%s")=0,COUNTA(%d:%d)>0,OR(%s,SUMPRODUCT(%s*1)>1))`,
+		keyRuleMarker, escaped, firstDataRow, firstDataRow,
+		strings.Join(blanks, ","), strings.Join(matches, "*"))
+}
+
+// FindKeyRule looks for the primary key rule managed by ss-migrate in the given conditional formats
+func FindKeyRule(rules []*sheets.ConditionalFormatRule) *KeyRule {
+	for _, rule := range rules {
+		formula := customFormula(rule)
+		start := strings.Index(formula, `N("`+keyRuleMarker)
+		if start == -1 {
+			continue
+		}
+
+		value, ok := formulaString(formula[start+len(`N("`+keyRuleMarker):])
+		if !ok {
+			continue
+		}
+
+		var fields []string
+		if err := json.Unmarshal([]byte(value), &fields); err != nil && value != "" {
+			// Rules written before the fields were JSON-encoded list them separated by commas
+			fields = strings.Split(value, ",")
+		}
+		return &KeyRule{
+			Fields:  fields,
+			Formula: formula,
+		}
+	}
+	return nil
+}
+
+// formulaString reads the rest of a formula string literal, up to its closing quote, undoing the
+// doubling of quotes inside it. It reports false when the literal is not closed.
+func formulaString(rest string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(rest); i++ {
+		if rest[i] != '"' {
+			b.WriteByte(rest[i])
+			continue
+		}
+		if i+1 < len(rest) && rest[i+1] == '"' {
+			b.WriteByte('"')
+			i++
+			continue
+		}
+		return b.String(), true
+	}
+	return "", false
+}
+
+// isKeyRule reports whether the conditional format rule is a primary key rule managed by ss-migrate
+func isKeyRule(rule *sheets.ConditionalFormatRule) bool {
+	return strings.Contains(customFormula(rule), `N("`+keyRuleMarker)
+}
+
+// customFormula returns the formula of a custom-formula conditional format rule
+func customFormula(rule *sheets.ConditionalFormatRule) string {
+	if rule == nil || rule.BooleanRule == nil || rule.BooleanRule.Condition == nil {
+		return ""
+	}
+	condition := rule.BooleanRule.Condition
+	if condition.Type != "CUSTOM_FORMULA" || len(condition.Values) == 0 {
+		return ""
+	}
+	return condition.Values[0].UserEnteredValue
+}
+
+// NewKeyRule builds the conditional format rule highlighting blank and duplicate keys
+func NewKeyRule(sheetID int64, fields []string, columns []int, headerRow int) *sheets.ConditionalFormatRule {
+	ranges := make([]*sheets.GridRange, 0, len(columns))
+	for _, col := range columns {
+		ranges = append(ranges, &sheets.GridRange{
+			SheetId:          sheetID,
+			StartRowIndex:    int64(headerRow),
+			StartColumnIndex: int64(col),
+			EndColumnIndex:   int64(col + 1),
+		})
+	}
+
+	return &sheets.ConditionalFormatRule{
+		Ranges: ranges,
+		BooleanRule: &sheets.BooleanRule{
+			Condition: &sheets.BooleanCondition{
+				Type: "CUSTOM_FORMULA",
+				Values: []*sheets.ConditionValue{
+					{UserEnteredValue: KeyRuleFormula(fields, columns, headerRow+1)},
+				},
+			},
+			Format: &sheets.CellFormat{
+				BackgroundColor: &sheets.Color{Red: 0.96, Green: 0.8, Blue: 0.8},
+			},
+		},
+	}
+}
+
+// FindKeyViolations scans data rows for blank and duplicate primary keys.
+// rows are the rows below the header and firstRow is the sheet row number of rows[0].
+// Rows that are entirely empty are ignored. A key made only of spaces is blank, and keys are
+// otherwise compared exactly, as the highlight rule from KeyRuleFormula compares them.
+func FindKeyViolations(headers []string, rows [][]any, keyFields []string, firstRow int) ([]KeyViolation, error) {
+	columns := make([]int, 0, len(keyFields))
+	for _, key := range keyFields {
+		col := -1
+		for i, header := range headers {
+			if header == key {
+				col = i
+				break
+			}
+		}
+		if col == -1 {
+			return nil, fmt.Errorf("key field %s not found in header row", key)
+		}
+		columns = append(columns, col)
+	}
+
+	violations := []KeyViolation{}
+	rowsByKey := make(map[string][]int)
+	keysByRow := make(map[int][]string)
+	for i, row := range rows {
+		if isEmptyRow(row) {
+			continue
+		}
+
+		rowNumber := firstRow + i
+		key := make([]string, len(columns))
+		blank := false
+		for j, col := range columns {
+			if col < len(row) && row[col] != nil {
+				key[j] = fmt.Sprintf("%v", row[col])
+			}
+			if strings.TrimSpace(key[j]) == "" {
+				blank = true
+			}
+		}
+
+		if blank {
+			violations = append(violations, KeyViolation{Row: rowNumber, Key: key, Blank: true})
+			continue
+		}
+
+		joined := strings.Join(key, "\x00")
+		rowsByKey[joined] = append(rowsByKey[joined], rowNumber)
+		keysByRow[rowNumber] = key
+	}
+
+	for _, rowNumbers := range rowsByKey {
+		if len(rowNumbers) < 2 {
+			continue
+		}
+		for _, rowNumber := range rowNumbers {
+			others := []int{}
+			for _, other := range rowNumbers {
+				if other != rowNumber {
+					others = append(others, other)
+				}
+			}
+			violations = append(violations, KeyViolation{
+				Row:         rowNumber,
+				Key:         keysByRow[rowNumber],
+				DuplicateOf: others,
+			})
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].Row < violations[j].Row
+	})
+
+	return violations, nil
+}
+
+// isEmptyRow reports whether every cell in the row is blank
+func isEmptyRow(row []any) bool {
+	for _, cell := range row {
+		if cell != nil && strings.TrimSpace(fmt.Sprintf("%v", cell)) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestKeyRuleFormula(t *testing.T) {
+	tests := []struct {
+		name         string
+		fields       []string
+		columns      []int
+		firstDataRow int
+		want         string
+	}{
+		{
+			name:         "single key",
+			fields:       []string{"id"},
+			columns:      []int{0},
+			firstDataRow: 2,
+			want:         `=AND(N("ss-migrate:primaryKey:[""id""]")=0,COUNTA(2:2)>0,OR(LEN(TRIM($A2))=0,SUMPRODUCT(EXACT($A$2:$A,$A2)*1)>1))`,
+		},
+		{
+			name:         "composite key",
+			fields:       []string{"tenant", "id"},
+			columns:      []int{2, 0},
+			firstDataRow: 4,
+			want:         `=AND(N("ss-migrate:primaryKey:[""tenant"",""id""]")=0,COUNTA(4:4)>0,OR(LEN(TRIM($C4))=0,LEN(TRIM($A4))=0,SUMPRODUCT(EXACT($C$4:$C,$C4)*EXACT($A$4:$A,$A4)*1)>1))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := KeyRuleFormula(tt.fields, tt.columns, tt.firstDataRow)
+			if got != tt.want {
+				t.Errorf("KeyRuleFormula() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindKeyRule(t *testing.T) {
+	handMade := &sheets.ConditionalFormatRule{
+		BooleanRule: &sheets.BooleanRule{
+			Condition: &sheets.BooleanCondition{
+				Type:   "CUSTOM_FORMULA",
+				Values: []*sheets.ConditionValue{{UserEnteredValue: "=$B2>100"}},
+			},
+		},
+	}
+	managed := NewKeyRule(0, []string{"tenant", "id"}, []int{0, 1}, 1)
+
+	if rule := FindKeyRule([]*sheets.ConditionalFormatRule{handMade}); rule != nil {
+		t.Errorf("expected no key rule, got %+v", rule)
+	}
+
+	rule := FindKeyRule([]*sheets.ConditionalFormatRule{handMade, managed})
+	if rule == nil {
+		t.Fatal("expected key rule to be found")
+	}
+	if !reflect.DeepEqual(rule.Fields, []string{"tenant", "id"}) {
+		t.Errorf("expected key fields [tenant id], got %v", rule.Fields)
+	}
+	if rule.Formula != KeyRuleFormula([]string{"tenant", "id"}, []int{0, 1}, 2) {
+		t.Errorf("unexpected formula %s", rule.Formula)
+	}
+
+	tests := []struct {
+		name    string
+		formula string
+		want    []string
+	}{
+		{
+			name:    "fields with commas and quotes",
+			formula: KeyRuleFormula([]string{"last, first", `say "hi")`}, []int{0, 1}, 2),
+			want:    []string{"last, first", `say "hi")`},
+		},
+		{
+			name:    "comma separated fields of older rules",
+			formula: `=AND(N("ss-migrate:primaryKey:tenant,id")=0,COUNTA(2:2)>0)`,
+			want:    []string{"tenant", "id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &sheets.ConditionalFormatRule{
+				BooleanRule: &sheets.BooleanRule{
+					Condition: &sheets.BooleanCondition{
+						Type:   "CUSTOM_FORMULA",
+						Values: []*sheets.ConditionValue{{UserEnteredValue: tt.formula}},
+					},
+				},
+			}
+			got := FindKeyRule([]*sheets.ConditionalFormatRule{rule})
+			if got == nil {
+				t.Fatal("expected key rule to be found")
+			}
+			if !reflect.DeepEqual(got.Fields, tt.want) {
+				t.Errorf("FindKeyRule() fields = %q, want %q", got.Fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareKeyRule(t *testing.T) {
+	resource := schema.Resource{
+		Name:       "users",
+		HeaderRow:  1,
+		PrimaryKey: schema.PrimaryKey{"id"},
+		Fields:     []schema.Field{{Name: "name"}, {Name: "id"}},
+	}
+	schemaFields := convertSchemaFields(resource.Fields)
+	upToDate := &KeyRule{Fields: []string{"id"}, Formula: KeyRuleFormula([]string{"id"}, []int{1}, 2)}
+	moved := &KeyRule{Fields: []string{"id"}, Formula: KeyRuleFormula([]string{"id"}, []int{0}, 2)}
+
+	if got := compareKeyRule(nil, resource, schemaFields); got == nil || got.Type != ChangeTypeAdd {
+		t.Errorf("expected ADD for missing rule, got %+v", got)
+	}
+	if got := compareKeyRule(upToDate, resource, schemaFields); got != nil {
+		t.Errorf("expected no change for up-to-date rule, got %+v", got)
+	}
+	if got := compareKeyRule(moved, resource, schemaFields); got == nil || got.Type != ChangeTypeModify {
+		t.Errorf("expected MODIFY for moved key column, got %+v", got)
+	}
+
+	resource.PrimaryKey = nil
+	if got := compareKeyRule(upToDate, resource, schemaFields); got == nil || got.Type != ChangeTypeRemove {
+		t.Errorf("expected REMOVE when primary key is dropped, got %+v", got)
+	}
+}
+
+func TestProtectKeyFields(t *testing.T) {
+	currentFields := []FieldInfo{
+		{Name: "id", Type: "integer"},
+		{Name: "name", Type: "string"},
+		{Name: "legacy", Type: "string"},
+	}
+	schemaFields := []FieldInfo{
+		{Name: "name", Type: "string", Position: 0},
+	}
+	resource := schema.Resource{Name: "users", HeaderRow: 1}
+	currentRule := &KeyRule{Fields: []string{"id"}}
+
+	t.Run("blocked without force", func(t *testing.T) {
+		diff := CompareFields(currentFields, schemaFields)
+		protectKeyFields(diff, resource, currentRule, currentFields, false)
+
+		if len(diff.FieldsToRemove) != 1 || diff.FieldsToRemove[0].Name != "legacy" {
+			t.Errorf("expected only 'legacy' to be removed, got %+v", diff.FieldsToRemove)
+		}
+		if len(diff.Errors) != 1 || !strings.Contains(diff.Errors[0], "users!A1") {
+			t.Errorf("expected one error referencing users!A1, got %v", diff.Errors)
+		}
+	})
+
+	t.Run("names the cell after a blank column", func(t *testing.T) {
+		currentFields := []FieldInfo{
+			{Name: "name", Type: "string", Column: 0},
+			{Name: "id", Type: "integer", Column: 2},
+		}
+		diff := CompareFields(currentFields, schemaFields)
+		protectKeyFields(diff, resource, currentRule, currentFields, false)
+
+		if len(diff.Errors) != 1 || !strings.Contains(diff.Errors[0], "users!C1") {
+			t.Errorf("expected one error referencing users!C1, got %v", diff.Errors)
+		}
+	})
+
+	t.Run("allowed with force", func(t *testing.T) {
+		diff := CompareFields(currentFields, schemaFields)
+		protectKeyFields(diff, resource, currentRule, currentFields, true)
+
+		if len(diff.FieldsToRemove) != 2 {
+			t.Errorf("expected 2 fields to remove, got %d", len(diff.FieldsToRemove))
+		}
+		if len(diff.Errors) != 0 {
+			t.Errorf("expected no errors, got %v", diff.Errors)
+		}
+	})
+}
+
+func TestFindKeyViolations(t *testing.T) {
+	headers := []string{"id", "name", "tenant"}
+	rows := [][]any{
+		{"1", "alice", "a"},
+		{"2", "bob", "a"},
+		{"1", "carol", "a"},
+		{},
+		{"", "dave", "b"},
+		{"1", "erin", "b"},
+	}
+
+	t.Run("single key", func(t *testing.T) {
+		violations, err := FindKeyViolations(headers, rows, []string{"id"}, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := []KeyViolation{
+			{Row: 2, Key: []string{"1"}, DuplicateOf: []int{4, 7}},
+			{Row: 4, Key: []string{"1"}, DuplicateOf: []int{2, 7}},
+			{Row: 6, Key: []string{""}, Blank: true},
+			{Row: 7, Key: []string{"1"}, DuplicateOf: []int{2, 4}},
+		}
+		if !reflect.DeepEqual(violations, want) {
+			t.Errorf("FindKeyViolations() = %+v, want %+v", violations, want)
+		}
+	})
+
+	t.Run("composite key", func(t *testing.T) {
+		violations, err := FindKeyViolations(headers, rows, []string{"tenant", "id"}, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		rowNumbers := []int{}
+		for _, v := range violations {
+			rowNumbers = append(rowNumbers, v.Row)
+		}
+		if !reflect.DeepEqual(rowNumbers, []int{2, 4, 6}) {
+			t.Errorf("expected violations in rows [2 4 6], got %v", rowNumbers)
+		}
+	})
+
+	t.Run("keys compared exactly", func(t *testing.T) {
+		rows := [][]any{
+			{"abc"},
+			{"ABC"},
+			{"a*"},
+			{"ab"},
+			{"<5"},
+			{"4"},
+			{"~?"},
+			{"  ", "blank"},
+			{"a*"},
+		}
+		violations, err := FindKeyViolations([]string{"id", "name"}, rows, []string{"id"}, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := []KeyViolation{
+			{Row: 4, Key: []string{"a*"}, DuplicateOf: []int{10}},
+			{Row: 9, Key: []string{"  "}, Blank: true},
+			{Row: 10, Key: []string{"a*"}, DuplicateOf: []int{4}},
+		}
+		if !reflect.DeepEqual(violations, want) {
+			t.Errorf("FindKeyViolations() = %+v, want %+v", violations, want)
+		}
+	})
+
+	t.Run("missing key column", func(t *testing.T) {
+		if _, err := FindKeyViolations(headers, rows, []string{"code"}, 2); err == nil {
+			t.Error("expected error for missing key column")
+		}
+	})
+}
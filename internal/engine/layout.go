@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+	"google.golang.org/api/sheets/v4"
+)
+
+// ColumnLayout is the header row of a sheet as ss-migrate sees it. The plan records the layout it was made
+// against, and the applier carries it through the apply, updating it as each change inserts, deletes,
+// moves or tags columns, so that every change works on the column it was planned for.
+type ColumnLayout struct {
+	Names []string // Field each column holds, or its header when it holds none
+	IDs   []string // Field ID each column is tagged with, "" for untagged columns
+}
+
+// newColumnLayout builds the layout of a header row from its cells and grid data
+func newColumnLayout(resource schema.Resource, headers []string, grid *sheets.GridData) *ColumnLayout {
+	tags := columnIDs(grid)
+	ids := make([]string, len(headers))
+	for i := range ids {
+		ids[i] = columnID(tags, i)
+	}
+	return &ColumnLayout{Names: columnFieldNames(resource, headers, tags), IDs: ids}
+}
+
+// clone returns a copy of the layout that can be updated independently, nil for a nil layout
+func (l *ColumnLayout) clone() *ColumnLayout {
+	if l == nil {
+		return nil
+	}
+	return &ColumnLayout{Names: slices.Clone(l.Names), IDs: slices.Clone(l.IDs)}
+}
+
+// column returns the column holding a field
+func (l *ColumnLayout) column(name string) (int, error) {
+	if i := slices.Index(l.Names, name); i != -1 {
+		return i, nil
+	}
+	return -1, fmt.Errorf("field %s not found", name)
+}
+
+func (l *ColumnLayout) insert(column int, name, id string) {
+	l.Names = slices.Insert(l.Names, column, name)
+	l.IDs = slices.Insert(l.IDs, column, id)
+}
+
+func (l *ColumnLayout) delete(column int) {
+	l.Names = slices.Delete(l.Names, column, column+1)
+	l.IDs = slices.Delete(l.IDs, column, column+1)
+}
+
+func (l *ColumnLayout) move(move ColumnMove) {
+	name, id := l.Names[move.From], l.IDs[move.From]
+	l.delete(move.From)
+	l.insert(move.To, name, id)
+}
+
+// advance updates the layout for a change that has been applied, the same way the change altered the sheet
+func (l *ColumnLayout) advance(resource schema.Resource, change Change) error {
+	if _, ok := change.NewValue.(ColumnIDsDiff); ok {
+		l.tag(resource)
+		return nil
+	}
+	if isSheetChange(change) {
+		return nil
+	}
+
+	switch change.Type {
+	case ChangeTypeAdd:
+		fieldInfo, ok := change.NewValue.(FieldInfo)
+		if !ok {
+			return fmt.Errorf("invalid field info in change")
+		}
+		index := slices.IndexFunc(resource.Fields, func(f schema.Field) bool { return f.Name == fieldInfo.Name })
+		if index == -1 {
+			return fmt.Errorf("field %s not found in schema", fieldInfo.Name)
+		}
+		l.insert(insertIndex(resource, l.Names, index), fieldInfo.Name, resource.Fields[index].ColumnID())
+	case ChangeTypeRemove:
+		fieldInfo, ok := change.OldValue.(FieldInfo)
+		if !ok {
+			return fmt.Errorf("invalid field info in change")
+		}
+		column, err := l.column(fieldInfo.Name)
+		if err != nil {
+			return err
+		}
+		l.delete(column)
+	case ChangeTypeReorder:
+		for _, move := range reorderMoves(resource, l.Names) {
+			l.move(move)
+		}
+	}
+	return nil
+}
+
+// tag records the field IDs applyColumnIDs writes, which go to the first column holding each field
+func (l *ColumnLayout) tag(resource schema.Resource) {
+	for _, field := range resource.Fields {
+		if column := slices.Index(l.Names, field.Name); column != -1 {
+			l.IDs[column] = field.ColumnID()
+		}
+	}
+}
+
+// layoutDiff lists the columns whose field or ID tag differ between the expected and the observed layout
+func layoutDiff(expected, observed *ColumnLayout) []string {
+	lines := []string{}
+	for i := range max(len(expected.Names), len(observed.Names)) {
+		letter := sheet.ColumnToLetter(i)
+		switch {
+		case i >= len(observed.Names):
+			lines = append(lines, fmt.Sprintf("column %s: expected %q, found no column", letter, expected.Names[i]))
+		case i >= len(expected.Names):
+			lines = append(lines, fmt.Sprintf("column %s: expected no column, found %q", letter, observed.Names[i]))
+		case expected.Names[i] != observed.Names[i]:
+			lines = append(lines, fmt.Sprintf("column %s: expected %q, found %q", letter, expected.Names[i], observed.Names[i]))
+		case expected.IDs[i] != observed.IDs[i]:
+			lines = append(lines, fmt.Sprintf("column %s: expected %q tagged %q, found it tagged %q",
+				letter, expected.Names[i], expected.IDs[i], observed.IDs[i]))
+		}
+	}
+	return lines
+}
+
+// readLayout reads the current header layout of a sheet
+func readLayout(ctx context.Context, client *sheet.Client, spreadsheetID, sheetName string, resource schema.Resource) (*ColumnLayout, error) {
+	headerRow := resource.HeaderRow
+	if headerRow == 0 {
+		headerRow = 1
+	}
+	headers, err := client.GetHeaders(ctx, spreadsheetID, sheetName, headerRow)
+	if err != nil {
+		return nil, err
+	}
+	grid, err := client.GetGridData(ctx, spreadsheetID, sheetName, fmt.Sprintf("%d:%d", headerRow, headerRow))
+	if err != nil {
+		return nil, err
+	}
+	return newColumnLayout(resource, headers, grid), nil
+}
+
+// verifyLayout reads the header layout of the sheet a change targets and checks that it is still the
+// layout the change was planned against. With no expected layout, the observed one is returned to be
+// carried through the rest of the apply.
+func (a *Applier) verifyLayout(ctx context.Context, spreadsheetID string, resource *schema.Resource, expected *ColumnLayout) (*ColumnLayout, error) {
+	observed, err := readLayout(ctx, a.sheetClient, spreadsheetID, resource.Name, *resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header row of %s: %w", resource.Name, err)
+	}
+	if expected == nil {
+		return observed, nil
+	}
+	if lines := layoutDiff(expected, observed); len(lines) > 0 {
+		return nil, fmt.Errorf("the columns of %s no longer match the plan, so the remaining changes were not applied; run 'ss-migrate plan' again:\n    %s",
+			resource.Name, strings.Join(lines, "\n    "))
+	}
+	return expected, nil
+}
@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewColumnLayout(t *testing.T) {
+	resource := labeledResource()
+	customer := resource.Fields[1].ColumnID()
+
+	layout := newColumnLayout(resource, []string{"id", "Client", "memo"}, taggedGrid("", customer, "", "stray"))
+	if !reflect.DeepEqual(layout.Names, []string{"id", "customer_name", "memo"}) {
+		t.Errorf("Names = %v", layout.Names)
+	}
+	if !reflect.DeepEqual(layout.IDs, []string{"", customer, ""}) {
+		t.Errorf("IDs = %v, want one per header cell", layout.IDs)
+	}
+}
+
+func TestColumnLayoutAdvance(t *testing.T) {
+	resource := labeledResource()
+	id := resource.Fields[0].ColumnID()
+	customer := resource.Fields[1].ColumnID()
+	region := resource.Fields[2].ColumnID()
+
+	tests := []struct {
+		name      string
+		layout    ColumnLayout
+		change    Change
+		wantNames []string
+		wantIDs   []string
+	}{
+		{
+			name:      "add inserts before the next field",
+			layout:    ColumnLayout{Names: []string{"id", "memo", "region"}, IDs: []string{id, "", ""}},
+			change:    Change{Type: ChangeTypeAdd, NewValue: FieldInfo{Name: "customer_name"}},
+			wantNames: []string{"id", "memo", "customer_name", "region"},
+			wantIDs:   []string{id, "", customer, ""},
+		},
+		{
+			name:      "remove deletes the column",
+			layout:    ColumnLayout{Names: []string{"id", "old", "region"}, IDs: []string{id, "x", ""}},
+			change:    Change{Type: ChangeTypeRemove, OldValue: FieldInfo{Name: "old"}},
+			wantNames: []string{"id", "region"},
+			wantIDs:   []string{id, ""},
+		},
+		{
+			name:      "reorder moves the columns with their tags",
+			layout:    ColumnLayout{Names: []string{"region", "id", "customer_name"}, IDs: []string{region, id, ""}},
+			change:    Change{Type: ChangeTypeReorder},
+			wantNames: []string{"id", "customer_name", "region"},
+			wantIDs:   []string{id, "", region},
+		},
+		{
+			name:      "column IDs tag the managed columns",
+			layout:    ColumnLayout{Names: []string{"id", "memo", "region"}, IDs: []string{"", "", "stale"}},
+			change:    Change{Type: ChangeTypeModify, NewValue: ColumnIDsDiff{Type: ChangeTypeModify, Fields: []string{"id", "region"}}},
+			wantNames: []string{"id", "memo", "region"},
+			wantIDs:   []string{id, "", region},
+		},
+		{
+			name:      "modify keeps the layout",
+			layout:    ColumnLayout{Names: []string{"id", "region"}, IDs: []string{id, region}},
+			change:    Change{Type: ChangeTypeModify, NewValue: FieldDiff{Name: "region"}},
+			wantNames: []string{"id", "region"},
+			wantIDs:   []string{id, region},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := tt.layout
+			if err := layout.advance(resource, tt.change); err != nil {
+				t.Fatalf("advance() error: %v", err)
+			}
+			if !reflect.DeepEqual(layout.Names, tt.wantNames) || !reflect.DeepEqual(layout.IDs, tt.wantIDs) {
+				t.Errorf("advance() = %v %v, want %v %v", layout.Names, layout.IDs, tt.wantNames, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestLayoutDiff(t *testing.T) {
+	expected := &ColumnLayout{Names: []string{"id", "amount", "status"}, IDs: []string{"a", "b", ""}}
+
+	tests := []struct {
+		name     string
+		observed *ColumnLayout
+		want     []string
+	}{
+		{
+			name:     "unchanged",
+			observed: &ColumnLayout{Names: []string{"id", "amount", "status"}, IDs: []string{"a", "b", ""}},
+			want:     []string{},
+		},
+		{
+			name:     "column inserted by hand",
+			observed: &ColumnLayout{Names: []string{"id", "memo", "amount", "status"}, IDs: []string{"a", "", "b", ""}},
+			want: []string{
+				`column B: expected "amount", found "memo"`,
+				`column C: expected "status", found "amount"`,
+				`column D: expected no column, found "status"`,
+			},
+		},
+		{
+			name:     "column deleted by hand",
+			observed: &ColumnLayout{Names: []string{"id", "amount"}, IDs: []string{"a", "b"}},
+			want:     []string{`column C: expected "status", found no column`},
+		},
+		{
+			name:     "column replaced under the same header",
+			observed: &ColumnLayout{Names: []string{"id", "amount", "status"}, IDs: []string{"a", "", ""}},
+			want:     []string{`column B: expected "amount" tagged "b", found it tagged ""`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := layoutDiff(expected, tt.observed); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("layoutDiff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/user"
+	"slices"
+	"time"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+	"google.golang.org/api/sheets/v4"
+)
+
+// lockMetadataKey is the developer metadata key of the advisory lock an apply holds on a spreadsheet
+const lockMetadataKey = "ss-migrate:lock"
+
+// DefaultLockTTL is how long a lock is honoured before it is considered stale, e.g. after an apply crashed
+const DefaultLockTTL = 30 * time.Minute
+
+// Lock is an advisory lock held on a spreadsheet while an apply changes it
+type Lock struct {
+	Owner      string    `json:"owner"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	TTLSeconds int64     `json:"ttlSeconds"`
+	RenewedAt  time.Time `json:"renewedAt,omitzero"` // When the holder last restarted the lifetime, zero when never
+
+	metadataID int64
+}
+
+// ExpiresAt returns when the lock becomes stale, counted from when it was taken or last renewed
+func (l Lock) ExpiresAt() time.Time {
+	start := l.AcquiredAt
+	if l.RenewedAt.After(start) {
+		start = l.RenewedAt
+	}
+	return start.Add(time.Duration(l.TTLSeconds) * time.Second)
+}
+
+// Expired reports whether the lock is stale at now
+func (l Lock) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt())
+}
+
+func (l Lock) String() string {
+	return fmt.Sprintf("held by %s since %s (expires %s)",
+		l.Owner, l.AcquiredAt.Local().Format("2006-01-02 15:04:05"), l.ExpiresAt().Local().Format("2006-01-02 15:04:05"))
+}
+
+// LockOwner names the current user and host, so that others can tell who holds a lock
+func LockOwner() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		name += "@" + host
+	}
+	return name
+}
+
+// parseLocks reads the locks recorded in developer metadata. An entry that cannot be parsed is kept
+// as a lock with no lifetime, so that it counts as stale and is cleared by the next apply.
+func parseLocks(metadata []*sheets.DeveloperMetadata) []Lock {
+	locks := []Lock{}
+	for _, entry := range metadata {
+		if entry == nil || entry.MetadataKey != lockMetadataKey {
+			continue
+		}
+		var lock Lock
+		if err := json.Unmarshal([]byte(entry.MetadataValue), &lock); err != nil {
+			lock = Lock{Owner: "unknown"}
+		}
+		lock.metadataID = entry.MetadataId
+		locks = append(locks, lock)
+	}
+	return locks
+}
+
+// liveLocks returns the locks that have not expired at now
+func liveLocks(locks []Lock, now time.Time) []Lock {
+	live := []Lock{}
+	for _, lock := range locks {
+		if !lock.Expired(now) {
+			live = append(live, lock)
+		}
+	}
+	return live
+}
+
+// deleteLockRequests builds the requests that remove locks from a spreadsheet
+func deleteLockRequests(locks []Lock) []*sheets.Request {
+	requests := make([]*sheets.Request, 0, len(locks))
+	for _, lock := range locks {
+		requests = append(requests, &sheets.Request{
+			DeleteDeveloperMetadata: &sheets.DeleteDeveloperMetadataRequest{
+				DataFilter: &sheets.DataFilter{
+					DeveloperMetadataLookup: &sheets.DeveloperMetadataLookup{MetadataId: lock.metadataID},
+				},
+			},
+		})
+	}
+	return requests
+}
+
+// readLocks returns every lock recorded on a spreadsheet, stale ones included
+func readLocks(ctx context.Context, client *sheet.Client, spreadsheetID string) ([]Lock, error) {
+	metadata, err := client.SearchSpreadsheetMetadata(ctx, spreadsheetID, lockMetadataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locks: %w", err)
+	}
+	return parseLocks(metadata), nil
+}
+
+// lockedError describes a lock held by someone else
+func lockedError(spreadsheetID string, lock Lock) error {
+	return fmt.Errorf("spreadsheet %s is locked, %s; if no apply is running, clear the lock with: ss-migrate force-unlock <schema-file-path>", spreadsheetID, lock)
+}
+
+// acquireLock takes the lock on a spreadsheet, clearing stale locks first. The lock is created and
+// then read back, and given up again when someone else took one at the same time.
+func (a *Applier) acquireLock(ctx context.Context, spreadsheetID string) (*Lock, error) {
+	now := time.Now()
+	locks, err := readLocks(ctx, a.sheetClient, spreadsheetID)
+	if err != nil {
+		return nil, err
+	}
+	if live := liveLocks(locks, now); len(live) > 0 {
+		return nil, lockedError(spreadsheetID, live[0])
+	}
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, deleteLockRequests(locks)); err != nil {
+		return nil, fmt.Errorf("failed to clear stale locks: %w", err)
+	}
+
+	lock := Lock{Owner: LockOwner(), AcquiredAt: now.UTC(), TTLSeconds: int64(DefaultLockTTL / time.Second)}
+	value, err := json.Marshal(lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock: %w", err)
+	}
+	entry, err := a.sheetClient.CreateSpreadsheetMetadata(ctx, spreadsheetID, lockMetadataKey, string(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock: %w", err)
+	}
+	lock.metadataID = entry.MetadataId
+
+	locks, err = readLocks(ctx, a.sheetClient, spreadsheetID)
+	if err != nil {
+		return nil, errors.Join(err, a.releaseLock(ctx, spreadsheetID, lock))
+	}
+	for _, other := range liveLocks(locks, now) {
+		if other.metadataID != lock.metadataID {
+			return nil, errors.Join(lockedError(spreadsheetID, other), a.releaseLock(ctx, spreadsheetID, lock))
+		}
+	}
+	return &lock, nil
+}
+
+// releaseLock removes a lock taken by acquireLock
+func (a *Applier) releaseLock(ctx context.Context, spreadsheetID string, lock Lock) error {
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, deleteLockRequests([]Lock{lock})); err != nil {
+		return fmt.Errorf("failed to release lock on %s: %w", spreadsheetID, err)
+	}
+	return nil
+}
+
+// heldLocks are the locks an Applier holds, by spreadsheet ID
+type heldLocks map[string]Lock
+
+// lockSpreadsheets takes the lock on each of the given spreadsheets. When one lock cannot be taken,
+// the locks taken so far are released again.
+func (a *Applier) lockSpreadsheets(ctx context.Context, spreadsheetIDs []string) (heldLocks, error) {
+	held := heldLocks{}
+	for _, spreadsheetID := range spreadsheetIDs {
+		lock, err := a.acquireLock(ctx, spreadsheetID)
+		if err != nil {
+			return nil, errors.Join(err, a.releaseLocks(ctx, held))
+		}
+		held[spreadsheetID] = *lock
+	}
+	return held, nil
+}
+
+// releaseLocks removes the locks taken by lockSpreadsheets
+func (a *Applier) releaseLocks(ctx context.Context, held heldLocks) error {
+	var errs []error
+	for _, spreadsheetID := range slices.Sorted(maps.Keys(held)) {
+		errs = append(errs, a.releaseLock(ctx, spreadsheetID, held[spreadsheetID]))
+	}
+	return errors.Join(errs...)
+}
+
+// renewLocks restarts the lifetime of the held locks, so that a long apply does not lose them.
+// It fails when a lock has been removed, e.g. by force-unlock, or someone else holds one.
+func (a *Applier) renewLocks(ctx context.Context, held heldLocks) error {
+	now := time.Now()
+	for _, spreadsheetID := range slices.Sorted(maps.Keys(held)) {
+		lock := held[spreadsheetID]
+		locks, err := readLocks(ctx, a.sheetClient, spreadsheetID)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(locks, func(l Lock) bool { return l.metadataID == lock.metadataID }) {
+			return fmt.Errorf("lost the lock on spreadsheet %s; it was removed while the apply was running", spreadsheetID)
+		}
+		for _, other := range liveLocks(locks, now) {
+			if other.metadataID != lock.metadataID {
+				return lockedError(spreadsheetID, other)
+			}
+		}
+
+		lock.RenewedAt = now.UTC()
+		request, err := renewLockRequest(lock)
+		if err != nil {
+			return err
+		}
+		if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, []*sheets.Request{request}); err != nil {
+			return fmt.Errorf("failed to renew lock on %s: %w", spreadsheetID, err)
+		}
+		held[spreadsheetID] = lock
+	}
+	return nil
+}
+
+// renewLockRequest builds the request that writes a renewed lock over its metadata entry
+func renewLockRequest(lock Lock) (*sheets.Request, error) {
+	value, err := json.Marshal(lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock: %w", err)
+	}
+	return &sheets.Request{
+		UpdateDeveloperMetadata: &sheets.UpdateDeveloperMetadataRequest{
+			DataFilters: []*sheets.DataFilter{{
+				DeveloperMetadataLookup: &sheets.DeveloperMetadataLookup{MetadataId: lock.metadataID},
+			}},
+			DeveloperMetadata: &sheets.DeveloperMetadata{MetadataValue: string(value)},
+			Fields:            "metadataValue",
+		},
+	}, nil
+}
+
+// Locks returns the locks recorded on a spreadsheet, stale ones included
+func (a *Applier) Locks(ctx context.Context, spreadsheetID string) ([]Lock, error) {
+	return readLocks(ctx, a.sheetClient, spreadsheetID)
+}
+
+// ForceUnlock removes the given locks from a spreadsheet, whoever holds them
+func (a *Applier) ForceUnlock(ctx context.Context, spreadsheetID string, locks []Lock) error {
+	if err := a.sheetClient.BatchUpdate(ctx, spreadsheetID, deleteLockRequests(locks)); err != nil {
+		return fmt.Errorf("failed to remove locks from %s: %w", spreadsheetID, err)
+	}
+	return nil
+}
+
+// lockWarning warns when another apply holds the lock on a spreadsheet
+func (p *Planner) lockWarning(ctx context.Context, spreadsheetID string) string {
+	locks, err := readLocks(ctx, p.sheetClient, spreadsheetID)
+	if err != nil {
+		return fmt.Sprintf("Could not check whether the spreadsheet is locked: %v", err)
+	}
+	live := liveLocks(locks, time.Now())
+	if len(live) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Spreadsheet is locked, %s; another apply may be running and this plan may change", live[0])
+}
+
+// SchemaSpreadsheetIDs returns the spreadsheets a schema's resources point to, each once and in sorted order
+func SchemaSpreadsheetIDs(schemaConfig *schema.Schema) ([]string, error) {
+	ids := []string{}
+	for _, resource := range schemaConfig.Resources {
+		spreadsheetID, err := sheet.ExtractSpreadsheetID(resource.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract spreadsheet ID for resource %s: %w", resource.Name, err)
+		}
+		if !slices.Contains(ids, spreadsheetID) {
+			ids = append(ids, spreadsheetID)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
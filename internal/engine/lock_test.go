@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestParseLocks(t *testing.T) {
+	metadata := []*sheets.DeveloperMetadata{
+		{MetadataId: 1, MetadataKey: lockMetadataKey, MetadataValue: `{"owner":"alice@laptop","acquiredAt":"2026-10-18T09:00:00Z","ttlSeconds":1800}`},
+		{MetadataId: 2, MetadataKey: columnIDMetadataKey, MetadataValue: "abc"},
+		{MetadataId: 3, MetadataKey: lockMetadataKey, MetadataValue: "not json"},
+	}
+
+	locks := parseLocks(metadata)
+	if len(locks) != 2 {
+		t.Fatalf("expected 2 locks, got %d", len(locks))
+	}
+	if locks[0].Owner != "alice@laptop" || locks[0].metadataID != 1 ||
+		!locks[0].ExpiresAt().Equal(time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected lock: %+v", locks[0])
+	}
+	if locks[1].metadataID != 3 || !locks[1].Expired(time.Now()) {
+		t.Errorf("expected an unreadable lock to count as stale, got %+v", locks[1])
+	}
+}
+
+func TestLiveLocks(t *testing.T) {
+	acquired := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	locks := []Lock{
+		{Owner: "alice", AcquiredAt: acquired, TTLSeconds: 1800, metadataID: 1},
+		{Owner: "bob", AcquiredAt: acquired, TTLSeconds: 60, metadataID: 2},
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want []string
+	}{
+		{name: "both held", now: acquired.Add(30 * time.Second), want: []string{"alice", "bob"}},
+		{name: "one expired", now: acquired.Add(time.Minute), want: []string{"alice"}},
+		{name: "all expired", now: acquired.Add(time.Hour), want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owners := []string{}
+			for _, lock := range liveLocks(locks, tt.now) {
+				owners = append(owners, lock.Owner)
+			}
+			if !reflect.DeepEqual(owners, tt.want) {
+				t.Errorf("liveLocks() = %v, want %v", owners, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteLockRequests(t *testing.T) {
+	requests := deleteLockRequests([]Lock{{metadataID: 4}, {metadataID: 9}})
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	for i, want := range []int64{4, 9} {
+		r := requests[i].DeleteDeveloperMetadata
+		if r == nil || r.DataFilter.DeveloperMetadataLookup.MetadataId != want {
+			t.Errorf("request %d: expected lock %d to be deleted, got %+v", i, want, requests[i])
+		}
+	}
+}
+
+func TestSchemaSpreadsheetIDs(t *testing.T) {
+	schemaConfig := &schema.Schema{
+		Resources: []schema.Resource{
+			{Name: "orders", Path: "https://docs.google.com/spreadsheets/d/xyz/edit"},
+			{Name: "users", Path: "https://docs.google.com/spreadsheets/d/abc/edit"},
+			{Name: "items", Path: "https://docs.google.com/spreadsheets/d/xyz/edit#gid=1"},
+		},
+	}
+
+	ids, err := SchemaSpreadsheetIDs(schemaConfig)
+	if err != nil {
+		t.Fatalf("SchemaSpreadsheetIDs() error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"abc", "xyz"}) {
+		t.Errorf("SchemaSpreadsheetIDs() = %v, want [abc xyz]", ids)
+	}
+}
+
+func TestLockRenewal(t *testing.T) {
+	acquired := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	lock := Lock{Owner: "alice", AcquiredAt: acquired, TTLSeconds: 1800, metadataID: 4}
+	if !lock.Expired(acquired.Add(45 * time.Minute)) {
+		t.Fatal("expected the lock to expire 30 minutes after it was taken")
+	}
+
+	lock.RenewedAt = acquired.Add(20 * time.Minute)
+	if lock.Expired(acquired.Add(45 * time.Minute)) {
+		t.Error("expected the renewal to restart the lifetime of the lock")
+	}
+
+	request, err := renewLockRequest(lock)
+	if err != nil {
+		t.Fatalf("renewLockRequest() error: %v", err)
+	}
+	update := request.UpdateDeveloperMetadata
+	if update == nil || update.DataFilters[0].DeveloperMetadataLookup.MetadataId != 4 || update.Fields != "metadataValue" {
+		t.Fatalf("unexpected request: %+v", request)
+	}
+	renewed := parseLocks([]*sheets.DeveloperMetadata{{MetadataId: 4, MetadataKey: lockMetadataKey, MetadataValue: update.DeveloperMetadata.MetadataValue}})
+	if len(renewed) != 1 || !renewed[0].RenewedAt.Equal(lock.RenewedAt) || !renewed[0].AcquiredAt.Equal(acquired) {
+		t.Errorf("expected the renewed lock to round trip, got %+v", renewed)
+	}
+}
+
+func TestRunSpreadsheetIDs(t *testing.T) {
+	run := &Run{
+		Backups: []Backup{{SpreadsheetID: "xyz"}},
+		Journal: []JournalEntry{{SpreadsheetID: "abc"}, {SpreadsheetID: "xyz"}},
+	}
+	if got := run.spreadsheetIDs(); !reflect.DeepEqual(got, []string{"abc", "xyz"}) {
+		t.Errorf("spreadsheetIDs() = %v, want [abc xyz]", got)
+	}
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"encoding/json"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// metadataList reads a list of strings stored as JSON under a developer metadata key.
+// It also returns the metadata entry, which is nil when the key is not present.
+func metadataList(metadata []*sheets.DeveloperMetadata, key string) ([]string, *sheets.DeveloperMetadata) {
+	for _, entry := range metadata {
+		if entry.MetadataKey != key {
+			continue
+		}
+		var values []string
+		if err := json.Unmarshal([]byte(entry.MetadataValue), &values); err != nil {
+			return nil, entry
+		}
+		return values, entry
+	}
+	return nil, nil
+}
+
+// metadataListRequest builds the request that stores a list of strings under a developer metadata key
+// of a sheet, creating, updating or deleting the entry as needed. It returns nil when nothing is to be done.
+func metadataListRequest(sheetID int64, key string, entry *sheets.DeveloperMetadata, values []string) *sheets.Request {
+	if len(values) == 0 {
+		if entry == nil {
+			return nil
+		}
+		return &sheets.Request{
+			DeleteDeveloperMetadata: &sheets.DeleteDeveloperMetadataRequest{
+				DataFilter: metadataIDFilter(entry.MetadataId),
+			},
+		}
+	}
+
+	value, _ := json.Marshal(values)
+	if entry != nil {
+		return &sheets.Request{
+			UpdateDeveloperMetadata: &sheets.UpdateDeveloperMetadataRequest{
+				DataFilters:       []*sheets.DataFilter{metadataIDFilter(entry.MetadataId)},
+				DeveloperMetadata: &sheets.DeveloperMetadata{MetadataValue: string(value)},
+				Fields:            "metadataValue",
+			},
+		}
+	}
+	return &sheets.Request{
+		CreateDeveloperMetadata: &sheets.CreateDeveloperMetadataRequest{
+			DeveloperMetadata: &sheets.DeveloperMetadata{
+				MetadataKey:   key,
+				MetadataValue: string(value),
+				Location:      &sheets.DeveloperMetadataLocation{SheetId: sheetID},
+				Visibility:    "DOCUMENT",
+			},
+		},
+	}
+}
+
+// metadataIDFilter selects a developer metadata entry by its ID
+func metadataIDFilter(id int64) *sheets.DataFilter {
+	return &sheets.DataFilter{
+		DeveloperMetadataLookup: &sheets.DeveloperMetadataLookup{MetadataId: id},
+	}
+}
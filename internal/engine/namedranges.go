@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+// namedRangesMetadataKey is the developer metadata key under which ss-migrate records
+// the names of the named ranges it manages for a sheet
+const namedRangesMetadataKey = "ss-migrate:named-ranges"
+
+// NamedRangesDiff represents changes to the named ranges managed by ss-migrate for a sheet
+type NamedRangesDiff struct {
+	Type    ChangeType
+	Changes []string // Descriptions of the changes, e.g. "add orders_table A1:C"
+}
+
+// desiredNamedRange is a named range declared by the schema, placed on the columns it must cover
+type desiredNamedRange struct {
+	Name  string
+	Range *sheets.GridRange
+}
+
+// desiredNamedRanges builds the table's named range and those of its fields. Fields are looked up in
+// headers; fields missing from them, or all fields when headers is nil, are placed at their schema position.
+func desiredNamedRanges(resource schema.Resource, headers []string, sheetID int64) []desiredNamedRange {
+	ranges := []desiredNamedRange{{
+		Name:  resource.TableRangeName(),
+		Range: TableRange(sheetID, resource, headers, resource.HeaderRow),
+	}}
+	for i, field := range resource.Fields {
+		if field.NamedRange == "" {
+			continue
+		}
+		column := i
+		for j, header := range headers {
+			if header == field.Name {
+				column = j
+				break
+			}
+		}
+		ranges = append(ranges, desiredNamedRange{
+			Name: field.NamedRange,
+			Range: &sheets.GridRange{
+				SheetId:          sheetID,
+				StartRowIndex:    int64(resource.HeaderRow),
+				StartColumnIndex: int64(column),
+				EndColumnIndex:   int64(column + 1),
+			},
+		})
+	}
+	return ranges
+}
+
+// findNamedRange looks up a named range by name, ignoring case as Sheets does
+func findNamedRange(namedRanges []*sheets.NamedRange, name string) *sheets.NamedRange {
+	for _, namedRange := range namedRanges {
+		if strings.EqualFold(namedRange.Name, name) {
+			return namedRange
+		}
+	}
+	return nil
+}
+
+// namedRangeChange is a named range to add, point elsewhere, adopt or delete
+type namedRangeChange struct {
+	Description string
+	Request     *sheets.Request // nil when only ownership changes
+}
+
+// namedRangeChanges compares the named ranges of a spreadsheet with those declared by the resource.
+// Ranges of the sheet that already carry a declared name are adopted, and ranges that ss-migrate created for
+// the sheet are deleted once they are no longer declared. Other named ranges are left alone.
+// It also returns the names to record as managed, and the declared names that another sheet already uses
+// for a range ss-migrate does not manage, since pointing those elsewhere would break their references.
+func namedRangeChanges(resource schema.Resource, headers []string, namedRanges []*sheets.NamedRange, sheetMeta *sheets.Sheet) ([]namedRangeChange, []string, []string) {
+	var sheetID int64
+	var metadata []*sheets.DeveloperMetadata
+	if sheetMeta != nil {
+		sheetID = sheetMeta.Properties.SheetId
+		metadata = sheetMeta.DeveloperMetadata
+	}
+	owned, _ := metadataList(metadata, namedRangesMetadataKey)
+	isOwned := func(name string) bool {
+		for _, o := range owned {
+			if strings.EqualFold(o, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	changes := []namedRangeChange{}
+	names := []string{}
+	conflicts := []string{}
+	declared := make(map[string]bool)
+	for _, desired := range desiredNamedRanges(resource, headers, sheetID) {
+		names = append(names, desired.Name)
+		declared[strings.ToLower(desired.Name)] = true
+
+		current := findNamedRange(namedRanges, desired.Name)
+		onSheet := sheetMeta != nil && current != nil && current.Range != nil && current.Range.SheetId == sheetID
+		switch {
+		case current != nil && !onSheet && !isOwned(desired.Name):
+			conflicts = append(conflicts, fmt.Sprintf("Named range '%s' of %s already names a range of another sheet that ss-migrate does not manage; rename one of them",
+				desired.Name, resource.Name))
+		case current == nil:
+			changes = append(changes, namedRangeChange{
+				Description: fmt.Sprintf("add %s %s", desired.Name, gridRangeA1(desired.Range)),
+				Request: &sheets.Request{
+					AddNamedRange: &sheets.AddNamedRangeRequest{
+						NamedRange: &sheets.NamedRange{Name: desired.Name, Range: desired.Range},
+					},
+				},
+			})
+		case !onSheet || !sameTableRange(current.Range, desired.Range):
+			changes = append(changes, namedRangeChange{
+				Description: fmt.Sprintf("%s: %s → %s", desired.Name, gridRangeA1(current.Range), gridRangeA1(desired.Range)),
+				Request: &sheets.Request{
+					UpdateNamedRange: &sheets.UpdateNamedRangeRequest{
+						NamedRange: &sheets.NamedRange{NamedRangeId: current.NamedRangeId, Name: current.Name, Range: desired.Range},
+						Fields:     "range",
+					},
+				},
+			})
+		case !isOwned(desired.Name):
+			changes = append(changes, namedRangeChange{Description: fmt.Sprintf("adopt %s", desired.Name)})
+		}
+	}
+
+	for _, name := range owned {
+		if declared[strings.ToLower(name)] {
+			continue
+		}
+		current := findNamedRange(namedRanges, name)
+		if current == nil {
+			continue
+		}
+		changes = append(changes, namedRangeChange{
+			Description: fmt.Sprintf("remove %s", current.Name),
+			Request: &sheets.Request{
+				DeleteNamedRange: &sheets.DeleteNamedRangeRequest{NamedRangeId: current.NamedRangeId},
+			},
+		})
+	}
+
+	return changes, names, conflicts
+}
+
+// compareNamedRanges compares the named ranges of a spreadsheet with those the resource declares
+// at the columns the fields will occupy after the migration, given by layout or by the schema order when
+// layout is nil. sheetMeta is nil when the sheet does not exist yet. It also returns the declared names
+// that are taken by ranges of other sheets, which block the migration.
+func compareNamedRanges(resource schema.Resource, layout []string, namedRanges []*sheets.NamedRange, sheetMeta *sheets.Sheet) (*NamedRangesDiff, []string) {
+	changes, _, conflicts := namedRangeChanges(resource, layout, namedRanges, sheetMeta)
+	if len(changes) == 0 {
+		return nil, conflicts
+	}
+
+	diff := &NamedRangesDiff{Type: ChangeTypeModify}
+	adds, removes := 0, 0
+	for _, change := range changes {
+		diff.Changes = append(diff.Changes, change.Description)
+		switch {
+		case change.Request != nil && change.Request.AddNamedRange != nil:
+			adds++
+		case change.Request != nil && change.Request.DeleteNamedRange != nil:
+			removes++
+		}
+	}
+	switch len(changes) {
+	case adds:
+		diff.Type = ChangeTypeAdd
+	case removes:
+		diff.Type = ChangeTypeRemove
+	}
+	return diff, conflicts
+}
+
+// NamedRangesRequests builds the requests that point the declared named ranges at the fields' current columns,
+// remove the ones no longer declared and record the managed names in the sheet's developer metadata
+func NamedRangesRequests(resource schema.Resource, headers []string, namedRanges []*sheets.NamedRange, sheetMeta *sheets.Sheet) ([]*sheets.Request, []string, error) {
+	changes, names, conflicts := namedRangeChanges(resource, headers, namedRanges, sheetMeta)
+	if len(conflicts) > 0 {
+		return nil, nil, fmt.Errorf("%s", strings.Join(conflicts, "; "))
+	}
+	if len(changes) == 0 {
+		return nil, nil, nil
+	}
+
+	requests := []*sheets.Request{}
+	descriptions := []string{}
+	for _, change := range changes {
+		if change.Request != nil {
+			requests = append(requests, change.Request)
+		}
+		descriptions = append(descriptions, change.Description)
+	}
+	_, entry := metadataList(sheetMeta.DeveloperMetadata, namedRangesMetadataKey)
+	if request := metadataListRequest(sheetMeta.Properties.SheetId, namedRangesMetadataKey, entry, names); request != nil {
+		requests = append(requests, request)
+	}
+	return requests, descriptions, nil
+}
+
+func describeNamedRangesDiff(d *NamedRangesDiff) string {
+	return fmt.Sprintf("Update named ranges (%s)", strings.Join(d.Changes, ", "))
+}
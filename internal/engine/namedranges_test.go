@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+func namedRangesResource() schema.Resource {
+	return schema.Resource{
+		Name:      "orders",
+		HeaderRow: 1,
+		Fields:    []schema.Field{{Name: "id", NamedRange: "order_ids"}, {Name: "status"}, {Name: "total", NamedRange: "order_totals"}},
+	}
+}
+
+func TestCompareNamedRanges(t *testing.T) {
+	sheet := &sheets.Sheet{Properties: &sheets.SheetProperties{SheetId: 7}}
+	owned := &sheets.Sheet{
+		Properties: &sheets.SheetProperties{SheetId: 7},
+		DeveloperMetadata: []*sheets.DeveloperMetadata{{
+			MetadataKey:   namedRangesMetadataKey,
+			MetadataValue: `["orders_table","order_ids","order_totals","old_range"]`,
+		}},
+	}
+	column := func(index int64) *sheets.GridRange {
+		return &sheets.GridRange{SheetId: 7, StartRowIndex: 1, StartColumnIndex: index, EndColumnIndex: index + 1}
+	}
+	upToDate := []*sheets.NamedRange{
+		{NamedRangeId: "t", Name: "orders_table", Range: &sheets.GridRange{SheetId: 7, EndColumnIndex: 3}},
+		{NamedRangeId: "i", Name: "order_ids", Range: column(0)},
+		{NamedRangeId: "o", Name: "order_totals", Range: column(2)},
+	}
+
+	tests := []struct {
+		name        string
+		sheet       *sheets.Sheet
+		namedRanges []*sheets.NamedRange
+		wantType    ChangeType
+		want        []string
+		conflicts   []string
+	}{
+		{
+			name:     "new sheet",
+			sheet:    nil,
+			wantType: ChangeTypeAdd,
+			want:     []string{"add orders_table A1:C", "add order_ids A2:A", "add order_totals C2:C"},
+		},
+		{
+			name:        "up to date",
+			sheet:       owned,
+			namedRanges: upToDate,
+			want:        nil,
+		},
+		{
+			name:  "range left behind by a moved column",
+			sheet: owned,
+			namedRanges: []*sheets.NamedRange{
+				upToDate[0], upToDate[1],
+				{NamedRangeId: "o", Name: "order_totals", Range: column(4)},
+			},
+			wantType: ChangeTypeModify,
+			want:     []string{"order_totals: E2:E → C2:C"},
+		},
+		{
+			name:        "hand-made ranges are adopted",
+			sheet:       sheet,
+			namedRanges: upToDate,
+			wantType:    ChangeTypeModify,
+			want:        []string{"adopt orders_table", "adopt order_ids", "adopt order_totals"},
+		},
+		{
+			name:  "undeclared range owned by ss-migrate is removed",
+			sheet: owned,
+			namedRanges: append(append([]*sheets.NamedRange{}, upToDate...),
+				&sheets.NamedRange{NamedRangeId: "x", Name: "old_range", Range: column(1)},
+				&sheets.NamedRange{NamedRangeId: "y", Name: "someone_elses", Range: column(1)}),
+			wantType: ChangeTypeRemove,
+			want:     []string{"remove old_range"},
+		},
+		{
+			name:  "name used by another sheet is not taken over",
+			sheet: sheet,
+			namedRanges: []*sheets.NamedRange{
+				upToDate[0], upToDate[2],
+				{NamedRangeId: "i", Name: "Order_IDs", Range: &sheets.GridRange{SheetId: 9, StartColumnIndex: 3, EndColumnIndex: 4}},
+			},
+			wantType:  ChangeTypeModify,
+			want:      []string{"adopt orders_table", "adopt order_totals"},
+			conflicts: []string{"order_ids"},
+		},
+		{
+			name:  "new sheet whose table name is used elsewhere",
+			sheet: nil,
+			namedRanges: []*sheets.NamedRange{
+				{NamedRangeId: "t", Name: "orders_table", Range: &sheets.GridRange{SheetId: 9, EndColumnIndex: 2}},
+			},
+			wantType:  ChangeTypeAdd,
+			want:      []string{"add order_ids A2:A", "add order_totals C2:C"},
+			conflicts: []string{"orders_table"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, conflicts := compareNamedRanges(namedRangesResource(), nil, tt.namedRanges, tt.sheet)
+			if len(conflicts) != len(tt.conflicts) {
+				t.Errorf("conflicts = %v, want conflicts for %v", conflicts, tt.conflicts)
+			}
+			for i := range min(len(conflicts), len(tt.conflicts)) {
+				if !strings.Contains(conflicts[i], "'"+tt.conflicts[i]+"'") {
+					t.Errorf("conflict %q does not name %s", conflicts[i], tt.conflicts[i])
+				}
+			}
+			if tt.want == nil {
+				if diff != nil {
+					t.Errorf("expected no changes, got %+v", diff)
+				}
+				return
+			}
+			if diff == nil {
+				t.Fatalf("expected changes %v, got none", tt.want)
+			}
+			if diff.Type != tt.wantType {
+				t.Errorf("Type = %s, want %s", diff.Type, tt.wantType)
+			}
+			if !reflect.DeepEqual(diff.Changes, tt.want) {
+				t.Errorf("Changes = %v, want %v", diff.Changes, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamedRangesRequests(t *testing.T) {
+	sheet := &sheets.Sheet{Properties: &sheets.SheetProperties{SheetId: 7}}
+	namedRanges := []*sheets.NamedRange{
+		{NamedRangeId: "o", Name: "order_totals", Range: &sheets.GridRange{SheetId: 7, StartRowIndex: 1, StartColumnIndex: 1, EndColumnIndex: 2}},
+	}
+
+	// total is found in its current column even though the schema lists it third
+	requests, changes, err := NamedRangesRequests(namedRangesResource(), []string{"id", "total", "status"}, namedRanges, sheet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantChanges := []string{"add orders_table A1:C", "add order_ids A2:A", "adopt order_totals"}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("changes = %v, want %v", changes, wantChanges)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(requests))
+	}
+	if requests[0].AddNamedRange == nil || requests[0].AddNamedRange.NamedRange.Name != "orders_table" {
+		t.Errorf("expected the table range to be added first, got %+v", requests[0])
+	}
+	if requests[1].AddNamedRange == nil || requests[1].AddNamedRange.NamedRange.Range.StartColumnIndex != 0 {
+		t.Errorf("expected order_ids to be added on column A, got %+v", requests[1])
+	}
+	create := requests[2].CreateDeveloperMetadata
+	if create == nil || create.DeveloperMetadata.MetadataValue != `["orders_table","order_ids","order_totals"]` {
+		t.Errorf("expected the managed names to be recorded, got %+v", requests[2])
+	}
+}
+
+func TestNamedRangesRequestsConflict(t *testing.T) {
+	sheet := &sheets.Sheet{Properties: &sheets.SheetProperties{SheetId: 7}}
+	namedRanges := []*sheets.NamedRange{
+		{NamedRangeId: "o", Name: "order_totals", Range: &sheets.GridRange{SheetId: 9, StartColumnIndex: 1, EndColumnIndex: 2}},
+	}
+
+	requests, _, err := NamedRangesRequests(namedRangesResource(), []string{"id", "status", "total"}, namedRanges, sheet)
+	if err == nil || !strings.Contains(err.Error(), "order_totals") {
+		t.Errorf("expected an error naming order_totals, got %v", err)
+	}
+	if requests != nil {
+		t.Errorf("expected no requests, got %+v", requests)
+	}
+}
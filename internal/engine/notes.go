@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+// headerNotesMetadataKey is the developer metadata key under which ss-migrate records
+// the fields whose header note it wrote, so that notes added by hand are never cleared
+const headerNotesMetadataKey = "ss-migrate:header-notes"
+
+// HeaderNote builds the note published on a field's header cell from its title and description,
+// followed by its type and constraints. It returns "" when the field has neither a title nor a description.
+func HeaderNote(resource schema.Resource, field schema.Field) string {
+	if field.Title == "" && field.Description == "" {
+		return ""
+	}
+
+	lines := []string{}
+	if field.Title != "" {
+		lines = append(lines, field.Title)
+	}
+	if field.Description != "" {
+		lines = append(lines, field.Description)
+	}
+	lines = append(lines, "")
+
+	if field.Type == "array" && field.ItemType != "" {
+		lines = append(lines, fmt.Sprintf("Type: array of %s", field.ItemType))
+	} else {
+		lines = append(lines, fmt.Sprintf("Type: %s", formatFieldType(field.Type, field.Format)))
+	}
+	if resource.IsKeyField(field.Name) {
+		lines = append(lines, "Primary key: unique and non-blank")
+	}
+	if field.Formula != "" {
+		lines = append(lines, fmt.Sprintf("Computed: %s", field.Formula))
+	}
+	if field.Default != nil {
+		lines = append(lines, fmt.Sprintf("Default: %v", field.Default))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cellNote returns the note of a cell in grid data, or "" when it has none
+func cellNote(grid *sheets.GridData, row, column int) string {
+	if grid == nil || row >= len(grid.RowData) || grid.RowData[row] == nil || column >= len(grid.RowData[row].Values) {
+		return ""
+	}
+	return grid.RowData[row].Values[column].Note
+}
+
+// clearStaleHeaderNotes plans the removal of notes that ss-migrate wrote on fields which no longer
+// have a title or description. Notes on other fields are left alone.
+func clearStaleHeaderNotes(diff *SheetDiff, currentFields, schemaFields []FieldInfo, sheetMeta *sheets.Sheet) {
+	if sheetMeta == nil {
+		return
+	}
+	owned, _ := metadataList(sheetMeta.DeveloperMetadata, headerNotesMetadataKey)
+	isOwned := make(map[string]bool, len(owned))
+	for _, name := range owned {
+		isOwned[name] = true
+	}
+
+	current := make(map[string]FieldInfo)
+	for _, field := range currentFields {
+		current[field.Name] = field
+	}
+
+	for _, schemaField := range schemaFields {
+		currentField, exists := current[schemaField.Name]
+		if !exists || schemaField.Note != "" || currentField.Note == "" || !isOwned[schemaField.Name] {
+			continue
+		}
+
+		found := false
+		for i := range diff.FieldsToModify {
+			fieldDiff := &diff.FieldsToModify[i]
+			if fieldDiff.Name == schemaField.Name {
+				fieldDiff.OldNote = currentField.Note
+				fieldDiff.NewNote = ""
+				fieldDiff.Description += ", remove header note"
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff.FieldsToModify = append(diff.FieldsToModify, FieldDiff{
+				Name:           schemaField.Name,
+				Type:           ChangeTypeModify,
+				OldType:        schemaField.Type,
+				NewType:        schemaField.Type,
+				OldFormat:      schemaField.Format,
+				NewFormat:      schemaField.Format,
+				OldHidden:      schemaField.Hidden,
+				NewHidden:      schemaField.Hidden,
+				OldPattern:     schemaField.Pattern,
+				NewPattern:     schemaField.Pattern,
+				OldPatternType: schemaField.PatternType,
+				NewPatternType: schemaField.PatternType,
+				OldNote:        currentField.Note,
+				Description:    "remove header note",
+			})
+		}
+	}
+}
+
+// HeaderNoteRequests builds the requests that write a note on a header cell and record in the sheet's
+// developer metadata whether ss-migrate owns the note of the field. An empty note clears the cell's note.
+func HeaderNoteRequests(sheetMeta *sheets.Sheet, headerRow, column int, field, note string) []*sheets.Request {
+	sheetID := sheetMeta.Properties.SheetId
+	requests := []*sheets.Request{{
+		UpdateCells: &sheets.UpdateCellsRequest{
+			Range: &sheets.GridRange{
+				SheetId:          sheetID,
+				StartRowIndex:    int64(headerRow - 1),
+				EndRowIndex:      int64(headerRow),
+				StartColumnIndex: int64(column),
+				EndColumnIndex:   int64(column + 1),
+			},
+			Rows:   []*sheets.RowData{{Values: []*sheets.CellData{{Note: note}}}},
+			Fields: "note",
+		},
+	}}
+
+	owned, entry := metadataList(sheetMeta.DeveloperMetadata, headerNotesMetadataKey)
+	names := []string{}
+	for _, name := range owned {
+		if name != field {
+			names = append(names, name)
+		}
+	}
+	if note != "" {
+		names = append(names, field)
+	}
+	if request := metadataListRequest(sheetID, headerNotesMetadataKey, entry, names); request != nil {
+		requests = append(requests, request)
+	}
+	return requests
+}
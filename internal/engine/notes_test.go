@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestHeaderNote(t *testing.T) {
+	resource := schema.Resource{PrimaryKey: []string{"id"}}
+
+	tests := []struct {
+		name  string
+		field schema.Field
+		want  string
+	}{
+		{
+			name:  "no title or description",
+			field: schema.Field{Name: "id", Type: "integer"},
+			want:  "",
+		},
+		{
+			name:  "key field",
+			field: schema.Field{Name: "id", Type: "integer", Title: "Order ID", Description: "Assigned by the shop"},
+			want:  "Order ID\nAssigned by the shop\n\nType: integer\nPrimary key: unique and non-blank",
+		},
+		{
+			name:  "computed field",
+			field: schema.Field{Name: "total", Type: "number", Description: "Price times quantity", Formula: "=A{row}*B{row}"},
+			want:  "Price times quantity\n\nType: number\nComputed: =A{row}*B{row}",
+		},
+		{
+			name:  "array with default",
+			field: schema.Field{Name: "tags", Type: "array", ItemType: "string", Title: "Tags", Default: "none"},
+			want:  "Tags\n\nType: array of string\nDefault: none",
+		},
+		{
+			name:  "date format",
+			field: schema.Field{Name: "created_at", Type: "date", Format: "%Y/%m/%d", Title: "Created"},
+			want:  "Created\n\nType: date(%Y/%m/%d)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HeaderNote(resource, tt.field); got != tt.want {
+				t.Errorf("HeaderNote() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareFieldsHeaderNote(t *testing.T) {
+	current := []FieldInfo{
+		{Name: "id", Type: "integer", Note: "Order ID"},
+		{Name: "status", Type: "string"},
+		{Name: "memo", Type: "string", Note: "written by hand"},
+	}
+	desired := []FieldInfo{
+		{Name: "id", Type: "integer", Note: "Order ID\nAssigned by the shop"},
+		{Name: "status", Type: "string", Note: "Status"},
+		{Name: "memo", Type: "string"},
+	}
+
+	diff := CompareFields(current, desired)
+	if len(diff.FieldsToModify) != 2 {
+		t.Fatalf("expected 2 fields to modify, got %+v", diff.FieldsToModify)
+	}
+	if got := diff.FieldsToModify[0]; got.Name != "id" || got.Description != "update header note" || got.NewNote != desired[0].Note {
+		t.Errorf("unexpected diff for id: %+v", got)
+	}
+	if got := diff.FieldsToModify[1]; got.Name != "status" || got.Description != "add header note" {
+		t.Errorf("unexpected diff for status: %+v", got)
+	}
+}
+
+func TestClearStaleHeaderNotes(t *testing.T) {
+	sheetMeta := &sheets.Sheet{
+		DeveloperMetadata: []*sheets.DeveloperMetadata{{MetadataKey: headerNotesMetadataKey, MetadataValue: `["id","status"]`}},
+	}
+	current := []FieldInfo{
+		{Name: "id", Type: "integer", Note: "Order ID"},
+		{Name: "status", Type: "string", Note: "Status"},
+		{Name: "memo", Type: "string", Note: "written by hand"},
+	}
+	desired := []FieldInfo{
+		{Name: "id", Type: "string"},
+		{Name: "status", Type: "string"},
+		{Name: "memo", Type: "string"},
+	}
+
+	diff := CompareFields(current, desired)
+	clearStaleHeaderNotes(diff, current, desired, sheetMeta)
+
+	if len(diff.FieldsToModify) != 2 {
+		t.Fatalf("expected 2 fields to modify, got %+v", diff.FieldsToModify)
+	}
+	if got := diff.FieldsToModify[0]; got.Description != "type from integer to string, remove header note" || got.OldNote != "Order ID" || got.NewNote != "" {
+		t.Errorf("unexpected diff for id: %+v", got)
+	}
+	if got := diff.FieldsToModify[1]; got.Name != "status" || got.Description != "remove header note" || got.OldType != got.NewType {
+		t.Errorf("unexpected diff for status: %+v", got)
+	}
+}
+
+func TestHeaderNoteRequests(t *testing.T) {
+	sheetMeta := &sheets.Sheet{
+		Properties: &sheets.SheetProperties{SheetId: 3},
+		DeveloperMetadata: []*sheets.DeveloperMetadata{
+			{MetadataId: 9, MetadataKey: headerNotesMetadataKey, MetadataValue: `["id","status"]`},
+		},
+	}
+
+	requests := HeaderNoteRequests(sheetMeta, 2, 1, "status", "")
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	update := requests[0].UpdateCells
+	if update == nil || update.Range.StartRowIndex != 1 || update.Range.StartColumnIndex != 1 || update.Fields != "note" {
+		t.Errorf("unexpected note request: %+v", requests[0])
+	}
+	metadata := requests[1].UpdateDeveloperMetadata
+	if metadata == nil || metadata.DeveloperMetadata.MetadataValue != `["id"]` {
+		t.Errorf("expected status to be released, got %+v", requests[1])
+	}
+}
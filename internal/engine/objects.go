@@ -0,0 +1,247 @@
+package engine
+
+import (
+	"reflect"
+	"slices"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// ObjectKind names a kind of sheet object that a snapshot captures
+type ObjectKind string
+
+const (
+	ObjectConditionalFormats ObjectKind = "conditionalFormats"
+	ObjectProtectedRanges    ObjectKind = "protectedRanges"
+	ObjectTable              ObjectKind = "table" // Banding and basic filter
+	ObjectNamedRanges        ObjectKind = "namedRanges"
+	ObjectHeaderNotes        ObjectKind = "headerNotes" // Only the record of the notes ss-migrate wrote, the notes are cells
+)
+
+// objectMetadataKeys are the developer metadata keys on the sheet that ss-migrate keeps along with each kind of object
+var objectMetadataKeys = map[ObjectKind]string{
+	ObjectConditionalFormats: conditionalFormatsMetadataKey,
+	ObjectNamedRanges:        namedRangesMetadataKey,
+	ObjectHeaderNotes:        headerNotesMetadataKey,
+}
+
+// allObjectKinds are the kinds captured when a whole sheet is backed up
+var allObjectKinds = []ObjectKind{ObjectConditionalFormats, ObjectProtectedRanges, ObjectTable, ObjectNamedRanges, ObjectHeaderNotes}
+
+// SheetObjects is a snapshot of the objects of a sheet besides its cells, so that they can be put back as they
+// were: its conditional format rules, protected ranges, banding and basic filter, the named ranges on it, and
+// the developer metadata ss-migrate keeps on it about them. Only the kinds listed in Kinds are captured.
+type SheetObjects struct {
+	Kinds              []ObjectKind                    `json:"kinds"`
+	ConditionalFormats []*sheets.ConditionalFormatRule `json:"conditionalFormats,omitempty"`
+	ProtectedRanges    []*sheets.ProtectedRange        `json:"protectedRanges,omitempty"`
+	BandedRanges       []*sheets.BandedRange           `json:"bandedRanges,omitempty"`
+	BasicFilter        *sheets.BasicFilter             `json:"basicFilter,omitempty"`
+	NamedRanges        []*sheets.NamedRange            `json:"namedRanges,omitempty"`
+	Metadata           []*sheets.DeveloperMetadata     `json:"metadata,omitempty"`
+}
+
+// captureObjects takes a snapshot of the given kinds of objects of a sheet. namedRanges are those of the
+// whole spreadsheet, of which the ones on the sheet are kept.
+func captureObjects(sheetMeta *sheets.Sheet, namedRanges []*sheets.NamedRange, kinds ...ObjectKind) *SheetObjects {
+	sheetID := sheetMeta.Properties.SheetId
+	objects := &SheetObjects{Kinds: kinds}
+	for _, kind := range kinds {
+		switch kind {
+		case ObjectConditionalFormats:
+			objects.ConditionalFormats = sheetMeta.ConditionalFormats
+		case ObjectProtectedRanges:
+			objects.ProtectedRanges = sheetMeta.ProtectedRanges
+		case ObjectTable:
+			objects.BandedRanges = sheetMeta.BandedRanges
+			objects.BasicFilter = sheetMeta.BasicFilter
+		case ObjectNamedRanges:
+			for _, namedRange := range namedRanges {
+				if namedRange.Range != nil && namedRange.Range.SheetId == sheetID {
+					objects.NamedRanges = append(objects.NamedRanges, namedRange)
+				}
+			}
+		}
+		if key, ok := objectMetadataKeys[kind]; ok {
+			if _, entry := metadataList(sheetMeta.DeveloperMetadata, key); entry != nil {
+				objects.Metadata = append(objects.Metadata, entry)
+			}
+		}
+	}
+	return objects
+}
+
+// restoreObjectsRequests builds the requests that put the captured kinds of objects of a sheet back as the
+// snapshot holds them. sheetMeta and namedRanges describe the spreadsheet as it is now. Objects that still
+// exist are updated in place, so that they keep their IDs; missing ones are added again and new ones deleted.
+func restoreObjectsRequests(sheetMeta *sheets.Sheet, namedRanges []*sheets.NamedRange, objects *SheetObjects) []*sheets.Request {
+	sheetID := sheetMeta.Properties.SheetId
+	requests := []*sheets.Request{}
+	for _, kind := range objects.Kinds {
+		switch kind {
+		case ObjectConditionalFormats:
+			requests = append(requests, restoreConditionalFormatsRequests(sheetID, sheetMeta.ConditionalFormats, objects.ConditionalFormats)...)
+		case ObjectProtectedRanges:
+			requests = append(requests, restoreProtectedRangesRequests(sheetID, sheetMeta.ProtectedRanges, objects.ProtectedRanges)...)
+		case ObjectTable:
+			requests = append(requests, restoreBandingRequests(sheetID, sheetMeta.BandedRanges, objects.BandedRanges)...)
+			requests = append(requests, restoreFilterRequests(sheetID, sheetMeta.BasicFilter, objects.BasicFilter)...)
+		case ObjectNamedRanges:
+			requests = append(requests, restoreNamedRangesRequests(sheetID, namedRanges, objects.NamedRanges)...)
+		}
+
+		key, ok := objectMetadataKeys[kind]
+		if !ok {
+			continue
+		}
+		values, _ := metadataList(objects.Metadata, key)
+		_, entry := metadataList(sheetMeta.DeveloperMetadata, key)
+		if request := metadataListRequest(sheetID, key, entry, values); request != nil {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// restoreConditionalFormatsRequests replaces the conditional format rules of a sheet with the captured ones,
+// in their captured order, unless they are already the same
+func restoreConditionalFormatsRequests(sheetID int64, current, captured []*sheets.ConditionalFormatRule) []*sheets.Request {
+	if reflect.DeepEqual(current, captured) || len(current)+len(captured) == 0 {
+		return nil
+	}
+
+	// Delete from the highest index so that the remaining indexes stay valid
+	requests := []*sheets.Request{}
+	for i := len(current) - 1; i >= 0; i-- {
+		requests = append(requests, &sheets.Request{
+			DeleteConditionalFormatRule: &sheets.DeleteConditionalFormatRuleRequest{SheetId: sheetID, Index: int64(i)},
+		})
+	}
+	for i, rule := range captured {
+		requests = append(requests, &sheets.Request{
+			AddConditionalFormatRule: &sheets.AddConditionalFormatRuleRequest{Rule: rule, Index: int64(i)},
+		})
+	}
+	return requests
+}
+
+// restoreProtectedRangesRequests puts back the captured protected ranges of a sheet and deletes the others.
+// Editors are not restored, since only the owner of a protection may change them.
+func restoreProtectedRangesRequests(sheetID int64, current, captured []*sheets.ProtectedRange) []*sheets.Request {
+	requests := []*sheets.Request{}
+	for _, protectedRange := range current {
+		if !slices.ContainsFunc(captured, func(p *sheets.ProtectedRange) bool { return p.ProtectedRangeId == protectedRange.ProtectedRangeId }) {
+			requests = append(requests, &sheets.Request{
+				DeleteProtectedRange: &sheets.DeleteProtectedRangeRequest{ProtectedRangeId: protectedRange.ProtectedRangeId},
+			})
+		}
+	}
+	for _, protectedRange := range captured {
+		restored := &sheets.ProtectedRange{
+			ProtectedRangeId:  protectedRange.ProtectedRangeId,
+			Range:             protectedRange.Range,
+			NamedRangeId:      protectedRange.NamedRangeId,
+			Description:       protectedRange.Description,
+			WarningOnly:       protectedRange.WarningOnly,
+			UnprotectedRanges: protectedRange.UnprotectedRanges,
+		}
+		index := slices.IndexFunc(current, func(p *sheets.ProtectedRange) bool { return p.ProtectedRangeId == protectedRange.ProtectedRangeId })
+		switch {
+		case index == -1:
+			restored.ProtectedRangeId = 0
+			requests = append(requests, &sheets.Request{
+				AddProtectedRange: &sheets.AddProtectedRangeRequest{ProtectedRange: restored},
+			})
+		case !sameProtection(current[index], protectedRange):
+			restored.ForceSendFields = []string{"WarningOnly"}
+			requests = append(requests, &sheets.Request{
+				UpdateProtectedRange: &sheets.UpdateProtectedRangeRequest{
+					ProtectedRange: restored,
+					Fields:         "range,description,warningOnly,unprotectedRanges",
+				},
+			})
+		}
+	}
+	return requests
+}
+
+// sameProtection reports whether two protected ranges cover the same cells in the same way
+func sameProtection(a, b *sheets.ProtectedRange) bool {
+	return reflect.DeepEqual(a.Range, b.Range) && a.Description == b.Description &&
+		a.WarningOnly == b.WarningOnly && reflect.DeepEqual(a.UnprotectedRanges, b.UnprotectedRanges)
+}
+
+// restoreBandingRequests puts back the captured banded ranges of a sheet and deletes the others
+func restoreBandingRequests(sheetID int64, current, captured []*sheets.BandedRange) []*sheets.Request {
+	requests := []*sheets.Request{}
+	for _, banding := range current {
+		if !slices.ContainsFunc(captured, func(b *sheets.BandedRange) bool { return b.BandedRangeId == banding.BandedRangeId }) {
+			requests = append(requests, &sheets.Request{
+				DeleteBanding: &sheets.DeleteBandingRequest{BandedRangeId: banding.BandedRangeId},
+			})
+		}
+	}
+	for _, banding := range captured {
+		index := slices.IndexFunc(current, func(b *sheets.BandedRange) bool { return b.BandedRangeId == banding.BandedRangeId })
+		switch {
+		case index == -1:
+			added := *banding
+			added.BandedRangeId = 0
+			requests = append(requests, &sheets.Request{
+				AddBanding: &sheets.AddBandingRequest{BandedRange: &added},
+			})
+		case !reflect.DeepEqual(current[index], banding):
+			requests = append(requests, &sheets.Request{
+				UpdateBanding: &sheets.UpdateBandingRequest{
+					BandedRange: banding,
+					Fields:      "range,rowProperties,columnProperties",
+				},
+			})
+		}
+	}
+	return requests
+}
+
+// restoreFilterRequests puts back the captured basic filter of a sheet, or clears the filter when there was none
+func restoreFilterRequests(sheetID int64, current, captured *sheets.BasicFilter) []*sheets.Request {
+	switch {
+	case reflect.DeepEqual(current, captured):
+		return nil
+	case captured == nil:
+		return []*sheets.Request{{ClearBasicFilter: &sheets.ClearBasicFilterRequest{SheetId: sheetID}}}
+	default:
+		return []*sheets.Request{{SetBasicFilter: &sheets.SetBasicFilterRequest{Filter: captured}}}
+	}
+}
+
+// restoreNamedRangesRequests puts back the captured named ranges of a sheet, wherever they point now,
+// and deletes the other named ranges on the sheet
+func restoreNamedRangesRequests(sheetID int64, current, captured []*sheets.NamedRange) []*sheets.Request {
+	requests := []*sheets.Request{}
+	for _, namedRange := range current {
+		if namedRange.Range == nil || namedRange.Range.SheetId != sheetID {
+			continue
+		}
+		if !slices.ContainsFunc(captured, func(n *sheets.NamedRange) bool { return n.NamedRangeId == namedRange.NamedRangeId }) {
+			requests = append(requests, &sheets.Request{
+				DeleteNamedRange: &sheets.DeleteNamedRangeRequest{NamedRangeId: namedRange.NamedRangeId},
+			})
+		}
+	}
+	for _, namedRange := range captured {
+		index := slices.IndexFunc(current, func(n *sheets.NamedRange) bool { return n.NamedRangeId == namedRange.NamedRangeId })
+		switch {
+		case index == -1:
+			requests = append(requests, &sheets.Request{
+				AddNamedRange: &sheets.AddNamedRangeRequest{
+					NamedRange: &sheets.NamedRange{Name: namedRange.Name, Range: namedRange.Range},
+				},
+			})
+		case current[index].Name != namedRange.Name || !reflect.DeepEqual(current[index].Range, namedRange.Range):
+			requests = append(requests, &sheets.Request{
+				UpdateNamedRange: &sheets.UpdateNamedRangeRequest{NamedRange: namedRange, Fields: "name,range"},
+			})
+		}
+	}
+	return requests
+}
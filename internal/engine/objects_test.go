@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"strconv"
+	"testing"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestCaptureObjects(t *testing.T) {
+	sheetMeta := &sheets.Sheet{
+		Properties:         &sheets.SheetProperties{SheetId: 7},
+		ConditionalFormats: []*sheets.ConditionalFormatRule{{Ranges: []*sheets.GridRange{{SheetId: 7}}}},
+		ProtectedRanges:    []*sheets.ProtectedRange{{ProtectedRangeId: 1}},
+		BasicFilter:        &sheets.BasicFilter{Range: &sheets.GridRange{SheetId: 7}},
+		DeveloperMetadata: []*sheets.DeveloperMetadata{
+			{MetadataId: 3, MetadataKey: conditionalFormatsMetadataKey, MetadataValue: `["a"]`},
+			{MetadataId: 4, MetadataKey: namedRangesMetadataKey, MetadataValue: `["b"]`},
+			{MetadataId: 5, MetadataKey: headerNotesMetadataKey, MetadataValue: `["id"]`},
+		},
+	}
+	namedRanges := []*sheets.NamedRange{
+		{NamedRangeId: "a", Name: "Orders", Range: &sheets.GridRange{SheetId: 7}},
+		{NamedRangeId: "b", Name: "Users", Range: &sheets.GridRange{SheetId: 8}},
+	}
+
+	t.Run("conditional formats", func(t *testing.T) {
+		objects := captureObjects(sheetMeta, namedRanges, ObjectConditionalFormats)
+		if len(objects.ConditionalFormats) != 1 || objects.ProtectedRanges != nil || objects.BasicFilter != nil || objects.NamedRanges != nil {
+			t.Errorf("expected only the conditional formats to be captured, got %+v", objects)
+		}
+		if len(objects.Metadata) != 1 || objects.Metadata[0].MetadataKey != conditionalFormatsMetadataKey {
+			t.Errorf("expected the conditional formats metadata to be captured, got %+v", objects.Metadata)
+		}
+	})
+
+	t.Run("all objects", func(t *testing.T) {
+		objects := captureObjects(sheetMeta, namedRanges, allObjectKinds...)
+		if len(objects.ConditionalFormats) != 1 || len(objects.ProtectedRanges) != 1 || objects.BasicFilter == nil || len(objects.Metadata) != 3 {
+			t.Errorf("expected every kind to be captured, got %+v", objects)
+		}
+		if len(objects.NamedRanges) != 1 || objects.NamedRanges[0].Name != "Orders" {
+			t.Errorf("expected only the named range on the sheet, got %+v", objects.NamedRanges)
+		}
+	})
+}
+
+func TestRestoreObjectsRequests(t *testing.T) {
+	rule := func(column int64) *sheets.ConditionalFormatRule {
+		return &sheets.ConditionalFormatRule{Ranges: []*sheets.GridRange{{SheetId: 7, StartColumnIndex: column, EndColumnIndex: column + 1}}}
+	}
+	protection := func(id, column int64) *sheets.ProtectedRange {
+		return &sheets.ProtectedRange{ProtectedRangeId: id, Range: &sheets.GridRange{SheetId: 7, StartColumnIndex: column, EndColumnIndex: column + 1}}
+	}
+	banding := func(id, column int64) *sheets.BandedRange {
+		return &sheets.BandedRange{BandedRangeId: id, Range: &sheets.GridRange{SheetId: 7, EndColumnIndex: column}}
+	}
+	namedRange := func(id, name string, sheetID int64) *sheets.NamedRange {
+		return &sheets.NamedRange{NamedRangeId: id, Name: name, Range: &sheets.GridRange{SheetId: sheetID}}
+	}
+	filter := &sheets.BasicFilter{Range: &sheets.GridRange{SheetId: 7}}
+
+	tests := []struct {
+		name        string
+		current     *sheets.Sheet
+		namedRanges []*sheets.NamedRange
+		objects     *SheetObjects
+		want        []string
+	}{
+		{
+			name:    "unchanged conditional formats",
+			current: &sheets.Sheet{ConditionalFormats: []*sheets.ConditionalFormatRule{rule(0), rule(1)}},
+			objects: &SheetObjects{Kinds: []ObjectKind{ObjectConditionalFormats}, ConditionalFormats: []*sheets.ConditionalFormatRule{rule(0), rule(1)}},
+			want:    []string{},
+		},
+		{
+			name:    "duplicated conditional formats",
+			current: &sheets.Sheet{ConditionalFormats: []*sheets.ConditionalFormatRule{rule(0), rule(0)}},
+			objects: &SheetObjects{Kinds: []ObjectKind{ObjectConditionalFormats}, ConditionalFormats: []*sheets.ConditionalFormatRule{rule(0)}},
+			want:    []string{"deleteRule 1", "deleteRule 0", "addRule 0"},
+		},
+		{
+			name: "conditional formats metadata",
+			current: &sheets.Sheet{DeveloperMetadata: []*sheets.DeveloperMetadata{
+				{MetadataId: 3, MetadataKey: conditionalFormatsMetadataKey, MetadataValue: `["a","b"]`},
+			}},
+			objects: &SheetObjects{
+				Kinds:    []ObjectKind{ObjectConditionalFormats},
+				Metadata: []*sheets.DeveloperMetadata{{MetadataKey: conditionalFormatsMetadataKey, MetadataValue: `["a"]`}},
+			},
+			want: []string{"updateMetadata"},
+		},
+		{
+			name:    "protected ranges",
+			current: &sheets.Sheet{ProtectedRanges: []*sheets.ProtectedRange{protection(1, 0), protection(2, 3), protection(4, 5)}},
+			objects: &SheetObjects{Kinds: []ObjectKind{ObjectProtectedRanges}, ProtectedRanges: []*sheets.ProtectedRange{protection(1, 0), protection(2, 2), protection(3, 4)}},
+			want:    []string{"deleteProtection 4", "updateProtection 2", "addProtection"},
+		},
+		{
+			name:    "banding and filter removed",
+			current: &sheets.Sheet{BandedRanges: []*sheets.BandedRange{banding(1, 4)}},
+			objects: &SheetObjects{Kinds: []ObjectKind{ObjectTable}, BandedRanges: []*sheets.BandedRange{banding(1, 3), banding(2, 3)}, BasicFilter: filter},
+			want:    []string{"updateBanding 1", "addBanding", "setFilter"},
+		},
+		{
+			name:    "banding and filter added",
+			current: &sheets.Sheet{BandedRanges: []*sheets.BandedRange{banding(1, 4)}, BasicFilter: filter},
+			objects: &SheetObjects{Kinds: []ObjectKind{ObjectTable}},
+			want:    []string{"deleteBanding 1", "clearFilter"},
+		},
+		{
+			name:        "named ranges",
+			current:     &sheets.Sheet{},
+			namedRanges: []*sheets.NamedRange{namedRange("a", "Orders2", 7), namedRange("b", "Extra", 7), namedRange("c", "Users", 8)},
+			objects:     &SheetObjects{Kinds: []ObjectKind{ObjectNamedRanges}, NamedRanges: []*sheets.NamedRange{namedRange("a", "Orders", 7), namedRange("d", "Totals", 7)}},
+			want:        []string{"deleteNamedRange b", "updateNamedRange Orders", "addNamedRange Totals"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.current.Properties = &sheets.SheetProperties{SheetId: 7}
+			requests := restoreObjectsRequests(tt.current, tt.namedRanges, tt.objects)
+			got := make([]string, len(requests))
+			for i, r := range requests {
+				got[i] = describeObjectRequest(r)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got requests %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("request %d = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// describeObjectRequest summarizes a request built by restoreObjectsRequests for comparison
+func describeObjectRequest(r *sheets.Request) string {
+	switch {
+	case r.DeleteConditionalFormatRule != nil:
+		return "deleteRule " + itoa(r.DeleteConditionalFormatRule.Index)
+	case r.AddConditionalFormatRule != nil:
+		return "addRule " + itoa(r.AddConditionalFormatRule.Index)
+	case r.DeleteProtectedRange != nil:
+		return "deleteProtection " + itoa(r.DeleteProtectedRange.ProtectedRangeId)
+	case r.UpdateProtectedRange != nil:
+		return "updateProtection " + itoa(r.UpdateProtectedRange.ProtectedRange.ProtectedRangeId)
+	case r.AddProtectedRange != nil:
+		if r.AddProtectedRange.ProtectedRange.ProtectedRangeId != 0 {
+			return "addProtection with an ID"
+		}
+		return "addProtection"
+	case r.DeleteBanding != nil:
+		return "deleteBanding " + itoa(r.DeleteBanding.BandedRangeId)
+	case r.UpdateBanding != nil:
+		return "updateBanding " + itoa(r.UpdateBanding.BandedRange.BandedRangeId)
+	case r.AddBanding != nil:
+		if r.AddBanding.BandedRange.BandedRangeId != 0 {
+			return "addBanding with an ID"
+		}
+		return "addBanding"
+	case r.SetBasicFilter != nil:
+		return "setFilter"
+	case r.ClearBasicFilter != nil:
+		return "clearFilter"
+	case r.DeleteNamedRange != nil:
+		return "deleteNamedRange " + r.DeleteNamedRange.NamedRangeId
+	case r.UpdateNamedRange != nil:
+		return "updateNamedRange " + r.UpdateNamedRange.NamedRange.Name
+	case r.AddNamedRange != nil:
+		return "addNamedRange " + r.AddNamedRange.NamedRange.Name
+	case r.UpdateDeveloperMetadata != nil:
+		return "updateMetadata"
+	case r.CreateDeveloperMetadata != nil:
+		return "createMetadata"
+	case r.DeleteDeveloperMetadata != nil:
+		return "deleteMetadata"
+	}
+	return "unknown"
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,49 @@
+package engine
+
+// Option configures a Planner or an Applier
+type Option func(*options)
+
+type options struct {
+	force       bool
+	run         *Run
+	skipBackups bool
+
+	skipLockCheck bool
+}
+
+// WithForce allows changes that are blocked by default, such as removing primary key fields
+func WithForce(force bool) Option {
+	return func(o *options) {
+		o.force = force
+	}
+}
+
+// WithRun makes an Applier back up each existing sheet into a hidden sheet before changing it and
+// journal each change with its inverse, recording both in run so that they can be rolled back or undone
+func WithRun(run *Run) Option {
+	return func(o *options) {
+		o.run = run
+	}
+}
+
+// WithoutBackups keeps the journal of a run but skips the backup sheets
+func WithoutBackups() Option {
+	return func(o *options) {
+		o.skipBackups = true
+	}
+}
+
+// withoutLockCheck stops a Planner from warning about locks, for the plan an apply makes under its own lock
+func withoutLockCheck() Option {
+	return func(o *options) {
+		o.skipLockCheck = true
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -3,21 +3,27 @@ package engine
 import (
 	"context"
 	"fmt"
-	"strings"
+	"slices"
 
 	"github.com/ucpr/ss-migrate/internal/schema"
 	"github.com/ucpr/ss-migrate/internal/sheet"
+	"google.golang.org/api/sheets/v4"
 )
 
 // Planner handles planning migrations between sheet and schema
 type Planner struct {
 	sheetClient *sheet.Client
+	force       bool
+	checkLocks  bool
 }
 
 // NewPlanner creates a new planner instance
-func NewPlanner(sheetClient *sheet.Client) *Planner {
+func NewPlanner(sheetClient *sheet.Client, opts ...Option) *Planner {
+	o := newOptions(opts)
 	return &Planner{
 		sheetClient: sheetClient,
+		force:       o.force,
+		checkLocks:  !o.skipLockCheck,
 	}
 }
 
@@ -37,26 +43,352 @@ func (p *Planner) Plan(ctx context.Context, schemaConfig *schema.Schema) (*DiffR
 	}
 
 	// Get current sheet structure
-	currentFields, err := p.analyzeSheet(ctx, spreadsheetID, resource.Name, resource.HeaderRow)
+	currentFields, err := p.analyzeSheet(ctx, spreadsheetID, resource)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze sheet: %w", err)
 	}
 
+	return p.planResource(ctx, schemaConfig, spreadsheetID, resource, currentFields), nil
+}
+
+// planResource compares the analyzed sheet with a resource and builds its migration plan
+func (p *Planner) planResource(ctx context.Context, schemaConfig *schema.Schema, spreadsheetID string, resource schema.Resource, currentFields []FieldInfo) *DiffResult {
 	// Convert schema fields to FieldInfo
 	schemaFields := convertSchemaFields(resource.Fields)
+	for i, field := range resource.Fields {
+		schemaFields[i].Note = HeaderNote(resource, field)
+	}
 
 	// Compare fields
-	diff := CompareFields(currentFields, schemaFields)
+	diff := CompareFieldsWithOrder(currentFields, schemaFields, resource.Order)
 	diff.SheetName = resource.Name
 
+	// Planning as if the sheet did not exist would add objects it may already have, so the sheet-level
+	// changes are not planned when the spreadsheet cannot be read
+	spreadsheet, err := p.sheetClient.GetSpreadsheet(ctx, spreadsheetID)
+	if err != nil {
+		diff.Errors = append(diff.Errors, fmt.Sprintf("Could not read the spreadsheet of %s: %v", resource.Name, err))
+		return ConvertDiffToResultWithOrder(diff, resource.Name, schemaFields)
+	}
+
+	// Sheet metadata is only available when the sheet already exists
+	var sheetMeta *sheets.Sheet
+	for _, tab := range spreadsheet.Sheets {
+		if tab.Properties.Title == resource.Name {
+			sheetMeta = tab
+			break
+		}
+	}
+	namedRanges := spreadsheet.NamedRanges
+	tabIndex := -1
+	if index, ok := TabIndexes(schemaConfig, spreadsheetID, spreadsheet.Sheets)[resource.Name]; ok {
+		tabIndex = index
+	}
+
+	if sheetMeta != nil {
+		p.planHeaderChecks(ctx, spreadsheetID, resource, diff)
+	}
+	holdNearMissHeaders(diff, resource)
+	p.planRenames(ctx, spreadsheetID, resource, diff)
+	clearStaleHeaderNotes(diff, currentFields, schemaFields, sheetMeta)
+	diff.ColumnIDs = compareColumnIDs(resource, currentFields)
+
+	var currentKeyRule *KeyRule
+	if sheetMeta != nil {
+		currentKeyRule = FindKeyRule(sheetMeta.ConditionalFormats)
+	}
+	protectKeyFields(diff, resource, currentKeyRule, currentFields, p.force)
+
+	if diff.FieldsToReorder {
+		diff.Moves = reorderMoves(resource, plannedLayout(resource, currentFields, diff))
+	}
+
+	// Fields end up where the migrated layout puts them, next to any unmanaged columns that are kept
+	finalLayout := movedLayout(plannedLayout(resource, currentFields, diff), diff.Moves)
+	for i := range schemaFields {
+		if column := slices.Index(finalLayout, schemaFields[i].Name); column != -1 {
+			schemaFields[i].Position = column
+		}
+	}
+
+	diff.KeyRule = compareKeyRule(currentKeyRule, resource, schemaFields)
+	p.planFormulas(ctx, spreadsheetID, resource, sheetMeta, finalLayout, diff)
+	p.planDefaults(ctx, spreadsheetID, resource, sheetMeta, diff)
+	p.planStyles(ctx, spreadsheetID, resource, sheetMeta, diff)
+	p.planConditionalFormats(ctx, spreadsheetID, resource, sheetMeta, diff)
+
+	// The table objects and named ranges must cover the columns as they are once the fields are migrated
+	var tableLayout []string
+	if sheetMeta != nil {
+		tableLayout = finalLayout
+	}
+	diff.Table = planTable(resource, tableLayout, sheetMeta, diff)
+	var conflicts []string
+	diff.NamedRanges, conflicts = compareNamedRanges(resource, tableLayout, namedRanges, sheetMeta)
+	diff.Errors = append(diff.Errors, conflicts...)
+
+	var properties *sheets.SheetProperties
+	if sheetMeta != nil {
+		properties = sheetMeta.Properties
+	}
+	diff.Properties = compareSheetProperties(resource, properties, tabIndex)
+
+	if p.checkLocks {
+		if warning := p.lockWarning(ctx, spreadsheetID); warning != "" {
+			diff.Warnings = append(diff.Warnings, warning)
+		}
+	}
+
 	// Convert to result with schema field order
 	result := ConvertDiffToResultWithOrder(diff, resource.Name, schemaFields)
 
-	return result, nil
+	// Record the columns the plan was made against, so that the applier can tell when they change under it
+	if sheetMeta != nil {
+		if layout, err := readLayout(ctx, p.sheetClient, spreadsheetID, resource.Name, resource); err == nil {
+			result.Layout = layout
+		}
+	}
+	return result
+}
+
+// planHeaderChecks blocks the migration when header cells are duplicated or blank, since columns
+// could then not be told apart
+func (p *Planner) planHeaderChecks(ctx context.Context, spreadsheetID string, resource schema.Resource, diff *SheetDiff) {
+	headers, err := p.fieldHeaders(ctx, spreadsheetID, resource)
+	if err != nil {
+		diff.Warnings = append(diff.Warnings, fmt.Sprintf("Could not check the headers of %s: %v", resource.Name, err))
+		return
+	}
+	diff.Errors = append(diff.Errors, checkHeaders(resource, headers)...)
+}
+
+// planRenames suggests declaring a label for removed columns that look like an added field under
+// another header, so that typo fixes and renames do not wipe a column
+func (p *Planner) planRenames(ctx context.Context, spreadsheetID string, resource schema.Resource, diff *SheetDiff) {
+	if len(diff.FieldsToRemove) == 0 || len(diff.FieldsToAdd) == 0 {
+		return
+	}
+
+	samples := make(map[string][]any)
+	for _, field := range diff.FieldsToRemove {
+		values, err := p.sheetClient.GetColumnData(ctx, spreadsheetID, resource.Name, sheet.ColumnToLetter(field.Column), resource.HeaderRow+1)
+		if err == nil {
+			samples[field.Name] = values
+		}
+	}
+
+	for _, rename := range findRenames(diff, resource, samples) {
+		diff.Suggestions = append(diff.Suggestions, describeRename(rename))
+	}
+}
+
+// planFormulas compares computed columns with their formulas and read-only protections
+func (p *Planner) planFormulas(ctx context.Context, spreadsheetID string, resource schema.Resource, sheetMeta *sheets.Sheet, layout []string, diff *SheetDiff) {
+	var protections map[string]*sheets.ProtectedRange
+	if sheetMeta != nil {
+		protections = FindFormulaProtections(sheetMeta.ProtectedRanges)
+	}
+
+	hasFormulas := len(protections) > 0
+	for _, field := range resource.Fields {
+		if field.Formula != "" {
+			hasFormulas = true
+		}
+	}
+	if !hasFormulas {
+		return
+	}
+
+	var rows [][]any
+	if sheetMeta != nil {
+		formulas, err := p.sheetClient.GetFormulas(ctx, spreadsheetID, resource.Name)
+		if err != nil {
+			diff.Warnings = append(diff.Warnings, fmt.Sprintf("Could not read formulas of %s: %v", resource.Name, err))
+		}
+		rows = formulas
+	}
+
+	formulaDiffs, warnings := compareFormulas(resource, layout, rows, protections)
+	diff.Formulas = formulaDiffs
+	diff.Warnings = append(diff.Warnings, warnings...)
+}
+
+// planDefaults counts the existing rows that receive the default of each field to add
+// and rejects literal defaults that do not match the field type
+func (p *Planner) planDefaults(ctx context.Context, spreadsheetID string, resource schema.Resource, sheetMeta *sheets.Sheet, diff *SheetDiff) {
+	var rows [][]any
+	loaded := sheetMeta == nil
+	for i, field := range diff.FieldsToAdd {
+		if field.Default == nil {
+			continue
+		}
+		if err := validateDefault(resource, field); err != nil {
+			diff.Errors = append(diff.Errors, fmt.Sprintf("Default value of field '%s' (%s) is invalid: %v", field.Name, resource.Name, err))
+			continue
+		}
+
+		if !loaded {
+			values, err := p.sheetClient.GetValues(ctx, spreadsheetID, resource.Name)
+			if err != nil {
+				diff.Warnings = append(diff.Warnings, fmt.Sprintf("Could not count rows of %s: %v", resource.Name, err))
+			}
+			if len(values) > resource.HeaderRow {
+				rows = values[resource.HeaderRow:]
+			}
+			loaded = true
+		}
+		diff.FieldsToAdd[i].Backfill = countDataRows(rows)
+	}
+}
+
+// planStyles compares the header row and column styles with the cell formats and widths in the sheet
+func (p *Planner) planStyles(ctx context.Context, spreadsheetID string, resource schema.Resource, sheetMeta *sheets.Sheet, diff *SheetDiff) {
+	hasStyles := resource.HeaderStyle != nil
+	for _, field := range resource.Fields {
+		if field.Style != nil {
+			hasStyles = true
+		}
+	}
+	if !hasStyles {
+		return
+	}
+
+	var headers []string
+	var grid *sheets.GridData
+	if sheetMeta != nil {
+		var err error
+		headers, err = p.fieldHeaders(ctx, spreadsheetID, resource)
+		if err == nil {
+			grid, err = p.sheetClient.GetGridData(ctx, spreadsheetID, resource.Name,
+				fmt.Sprintf("%d:%d", resource.HeaderRow, resource.HeaderRow+1))
+		}
+		if err != nil {
+			diff.Warnings = append(diff.Warnings, fmt.Sprintf("Could not read styles of %s: %v", resource.Name, err))
+		}
+	}
+
+	diff.Styles = compareStyles(resource, headers, grid)
+}
+
+// planConditionalFormats compares the conditional format rules owned by ss-migrate with the schema
+func (p *Planner) planConditionalFormats(ctx context.Context, spreadsheetID string, resource schema.Resource, sheetMeta *sheets.Sheet, diff *SheetDiff) {
+	hasRules := false
+	for _, field := range resource.Fields {
+		if len(field.ConditionalFormats) > 0 {
+			hasRules = true
+		}
+	}
+	if sheetMeta != nil {
+		if _, entry := ownedRuleFingerprints(sheetMeta.DeveloperMetadata); entry != nil {
+			hasRules = true
+		}
+	}
+	if !hasRules {
+		return
+	}
+
+	var headers []string
+	if sheetMeta != nil {
+		var err error
+		headers, err = p.fieldHeaders(ctx, spreadsheetID, resource)
+		if err != nil {
+			diff.Warnings = append(diff.Warnings, fmt.Sprintf("Could not read conditional formats of %s: %v", resource.Name, err))
+		}
+	}
+
+	diff.Conditional = compareConditionalFormats(resource, headers, sheetMeta)
+}
+
+// planTable compares the banding and basic filter with the table. When columns are added, removed
+// or moved they are always checked again after the field changes, since Sheets only stretches them
+// for columns inserted inside their range.
+func planTable(resource schema.Resource, names []string, sheetMeta *sheets.Sheet, diff *SheetDiff) *TableDiff {
+	if tableDiff := compareTable(resource, names, sheetMeta); tableDiff != nil {
+		return tableDiff
+	}
+
+	hasTableObjects := resource.Banding != nil || (resource.Filter != nil && *resource.Filter)
+	columnsChange := len(diff.FieldsToAdd) > 0 || len(diff.FieldsToRemove) > 0 || diff.FieldsToReorder
+	if sheetMeta == nil || !hasTableObjects || !columnsChange {
+		return nil
+	}
+	return &TableDiff{
+		Type:    ChangeTypeModify,
+		Changes: []string{"keep banding and filter on the table's columns"},
+	}
+}
+
+// protectKeyFields keeps primary key columns from being removed unless forced.
+// Key fields are taken from the schema and from the key rule currently in the sheet,
+// so that dropping a field from both fields and primaryKey is still caught.
+func protectKeyFields(diff *SheetDiff, resource schema.Resource, currentKeyRule *KeyRule, currentFields []FieldInfo, force bool) {
+	keyFields := make(map[string]bool)
+	for _, key := range resource.PrimaryKey {
+		keyFields[key] = true
+	}
+	if currentKeyRule != nil {
+		for _, key := range currentKeyRule.Fields {
+			keyFields[key] = true
+		}
+	}
+	if len(keyFields) == 0 || force {
+		return
+	}
+
+	remaining := []FieldInfo{}
+	for _, field := range diff.FieldsToRemove {
+		if !keyFields[field.Name] {
+			remaining = append(remaining, field)
+			continue
+		}
+
+		cell := field.Name
+		for _, current := range currentFields {
+			if current.Name == field.Name {
+				cell = fmt.Sprintf("%s%d", sheet.ColumnToLetter(current.Column), resource.HeaderRow)
+				break
+			}
+		}
+		diff.Errors = append(diff.Errors,
+			fmt.Sprintf("Refusing to remove primary key field '%s' (%s!%s); use --force to remove it", field.Name, resource.Name, cell))
+	}
+	diff.FieldsToRemove = remaining
+}
+
+// compareKeyRule determines whether the primary key highlight must be added, updated or removed
+func compareKeyRule(current *KeyRule, resource schema.Resource, schemaFields []FieldInfo) *KeyRuleDiff {
+	if len(resource.PrimaryKey) == 0 {
+		if current == nil {
+			return nil
+		}
+		return &KeyRuleDiff{Type: ChangeTypeRemove, OldFields: current.Fields}
+	}
+
+	// The rule covers the columns the key fields have once the fields are migrated
+	columns := make([]int, 0, len(resource.PrimaryKey))
+	for _, key := range resource.PrimaryKey {
+		for _, field := range schemaFields {
+			if field.Name == key {
+				columns = append(columns, field.Position)
+				break
+			}
+		}
+	}
+	desired := KeyRuleFormula(resource.PrimaryKey, columns, resource.HeaderRow+1)
+
+	if current == nil {
+		return &KeyRuleDiff{Type: ChangeTypeAdd, NewFields: resource.PrimaryKey}
+	}
+	if current.Formula != desired {
+		return &KeyRuleDiff{Type: ChangeTypeModify, OldFields: current.Fields, NewFields: resource.PrimaryKey}
+	}
+	return nil
 }
 
 // analyzeSheet analyzes the current structure of a sheet
-func (p *Planner) analyzeSheet(ctx context.Context, spreadsheetID, sheetName string, headerRow int) ([]FieldInfo, error) {
+func (p *Planner) analyzeSheet(ctx context.Context, spreadsheetID string, resource schema.Resource) ([]FieldInfo, error) {
+	sheetName := resource.Name
+	headerRow := resource.HeaderRow
 	if headerRow == 0 {
 		headerRow = 1
 	}
@@ -67,6 +399,17 @@ func (p *Planner) analyzeSheet(ctx context.Context, spreadsheetID, sheetName str
 		return nil, fmt.Errorf("failed to get headers: %w", err)
 	}
 
+	// Header notes and column IDs are read in one request for the whole row
+	var headerGrid *sheets.GridData
+	if len(headers) > 0 {
+		headerGrid, err = p.sheetClient.GetGridData(ctx, spreadsheetID, sheetName, fmt.Sprintf("%d:%d", headerRow, headerRow))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get header notes: %w", err)
+		}
+	}
+	ids := columnIDs(headerGrid)
+	names := columnFieldNames(resource, headers, ids)
+
 	// For each header, analyze the column data to infer type
 	fields := []FieldInfo{}
 	for i, header := range headers {
@@ -75,7 +418,12 @@ func (p *Planner) analyzeSheet(ctx context.Context, spreadsheetID, sheetName str
 		}
 
 		// First, try to get the column format to infer type
-		columnFormat, _ := p.sheetClient.GetColumnFormat(ctx, spreadsheetID, sheetName, i)
+		var columnFormat, columnFormatType string
+		numberFormat, _ := p.sheetClient.GetColumnNumberFormat(ctx, spreadsheetID, sheetName, i, headerRow+1)
+		if numberFormat != nil {
+			columnFormat = numberFormat.Pattern
+			columnFormatType = numberFormat.Type
+		}
 		inferredType := sheet.InferTypeFromFormat(columnFormat)
 		
 		// If we couldn't infer from format, fall back to data analysis
@@ -92,40 +440,67 @@ func (p *Planner) analyzeSheet(ctx context.Context, spreadsheetID, sheetName str
 			}
 		}
 
-		// Determine format from type if it's datetime
-		var format string
-		if inferredType == "datetime" && columnFormat != "" {
-			// Try to determine if it's date-only or datetime
-			lowerPattern := strings.ToLower(columnFormat)
-			if !strings.Contains(lowerPattern, "hh") && !strings.Contains(lowerPattern, "ss") {
-				format = "date"
-			} else if strings.Contains(lowerPattern, "hh") && !strings.Contains(lowerPattern, "yyyy") {
-				format = "time"
-			} else {
-				format = "default"
-			}
-		}
+		// Non-default date and time patterns are reported as strftime formats
+		format := sheet.FormatFromPattern(inferredType, columnFormat)
 
 		fields = append(fields, FieldInfo{
-			Name:   header,
-			Type:   inferredType,
-			Format: format,
+			Name:        names[i],
+			Header:      displayedHeader(resource, names[i], header),
+			Type:        inferredType,
+			Format:      format,
+			Pattern:     columnFormat,
+			PatternType: columnFormatType,
+			Note:        cellNote(headerGrid, 0, i),
+			Column:      i,
+			ID:          columnID(ids, i),
 		})
 	}
 
 	return fields, nil
 }
 
+// fieldHeaders reads the header row of a resource's sheet with each cell mapped to the name of the field it holds
+func (p *Planner) fieldHeaders(ctx context.Context, spreadsheetID string, resource schema.Resource) ([]string, error) {
+	headers, err := p.sheetClient.GetHeaders(ctx, spreadsheetID, resource.Name, resource.HeaderRow)
+	if err != nil {
+		return nil, err
+	}
+	headerRow := resource.HeaderRow
+	if headerRow == 0 {
+		headerRow = 1
+	}
+	grid, err := p.sheetClient.GetGridData(ctx, spreadsheetID, resource.Name, fmt.Sprintf("%d:%d", headerRow, headerRow))
+	if err != nil {
+		return nil, err
+	}
+	return columnFieldNames(resource, headers, columnIDs(grid)), nil
+}
+
 // convertSchemaFields converts schema fields to FieldInfo with position information
 func convertSchemaFields(fields []schema.Field) []FieldInfo {
 	result := []FieldInfo{}
 	for i, field := range fields {
+		fieldType, format := sheet.NormalizeType(field.Type, field.Format)
 		info := FieldInfo{
 			Name:     field.Name,
-			Type:     field.Type,
-			Format:   field.Format,
+			Header:   field.HeaderText(),
+			Type:     fieldType,
+			Format:   format,
 			Hidden:   field.Hidden,
 			Position: i, // Store the position in the schema
+			Default:  field.Default,
+		}
+		// Only fields that ask for a number format plan a pattern, the others keep the type's default
+		if field.Format != "" || field.NumberFormat != "" || field.DecimalPlaces != nil || field.ThousandsSeparator {
+			numberFormat := sheet.NumberFormatWithOptions(field.Type, field.Format, sheet.FormatOptions{
+				Pattern:            field.NumberFormat,
+				DecimalPlaces:      field.DecimalPlaces,
+				ThousandsSeparator: field.ThousandsSeparator,
+			})
+			if numberFormat != nil {
+				info.Pattern = numberFormat.Pattern
+				info.PatternType = numberFormat.Type
+			}
 		}
 		result = append(result, info)
 	}
@@ -137,30 +512,30 @@ func (p *Planner) PlanAll(ctx context.Context, schemaConfig *schema.Schema) ([]*
 	results := []*DiffResult{}
 
 	for _, resource := range schemaConfig.Resources {
-		// Extract spreadsheet ID from URL
-		spreadsheetID, err := sheet.ExtractSpreadsheetID(resource.Path)
-		if err != nil {
-			return nil, fmt.Errorf("failed to extract spreadsheet ID for resource %s: %w", resource.Name, err)
-		}
-
-		// Get current sheet structure
-		currentFields, err := p.analyzeSheet(ctx, spreadsheetID, resource.Name, resource.HeaderRow)
+		result, err := p.planSheet(ctx, schemaConfig, resource)
 		if err != nil {
-			// If sheet doesn't exist, treat as all fields need to be added
-			currentFields = []FieldInfo{}
+			return nil, err
 		}
+		results = append(results, result)
+	}
 
-		// Convert schema fields to FieldInfo
-		schemaFields := convertSchemaFields(resource.Fields)
+	return results, nil
+}
 
-		// Compare fields
-		diff := CompareFields(currentFields, schemaFields)
-		diff.SheetName = resource.Name
+// planSheet generates the migration plan of a single resource of the schema
+func (p *Planner) planSheet(ctx context.Context, schemaConfig *schema.Schema, resource schema.Resource) (*DiffResult, error) {
+	// Extract spreadsheet ID from URL
+	spreadsheetID, err := sheet.ExtractSpreadsheetID(resource.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract spreadsheet ID for resource %s: %w", resource.Name, err)
+	}
 
-		// Convert to result with schema field order
-		result := ConvertDiffToResultWithOrder(diff, resource.Name, schemaFields)
-		results = append(results, result)
+	// Get current sheet structure
+	currentFields, err := p.analyzeSheet(ctx, spreadsheetID, resource)
+	if err != nil {
+		// If sheet doesn't exist, treat as all fields need to be added
+		currentFields = []FieldInfo{}
 	}
 
-	return results, nil
+	return p.planResource(ctx, schemaConfig, spreadsheetID, resource, currentFields), nil
 }
\ No newline at end of file
@@ -0,0 +1,213 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+	"google.golang.org/api/sheets/v4"
+)
+
+// SheetPropertiesDiff represents a change to the properties of a sheet.
+// Properties that are nil or empty are left unchanged.
+type SheetPropertiesDiff struct {
+	Type          ChangeType
+	FrozenRows    *int
+	FrozenColumns *int
+	Index         *int   // 0-based position of the tab
+	TabColor      string // #RRGGBB
+	Hidden        *bool
+	RightToLeft   *bool
+	Changes       []string // Properties that differ, e.g. "frozen rows: 0 → 1"
+}
+
+// compareSheetProperties compares the properties of a sheet with those declared by the resource.
+// current is nil when the sheet does not exist yet, and tabIndex is the position the tab should
+// have or -1 when it is not known.
+func compareSheetProperties(resource schema.Resource, current *sheets.SheetProperties, tabIndex int) *SheetPropertiesDiff {
+	if current == nil {
+		current = &sheets.SheetProperties{}
+	}
+	grid := current.GridProperties
+	if grid == nil {
+		grid = &sheets.GridProperties{}
+	}
+
+	diff := &SheetPropertiesDiff{Type: ChangeTypeModify}
+	if rows := resource.FrozenRowCount(); int64(rows) != grid.FrozenRowCount {
+		diff.FrozenRows = &rows
+		diff.Changes = append(diff.Changes, fmt.Sprintf("frozen rows: %d → %d", grid.FrozenRowCount, rows))
+	}
+	if resource.FrozenColumns != nil && int64(*resource.FrozenColumns) != grid.FrozenColumnCount {
+		columns := *resource.FrozenColumns
+		diff.FrozenColumns = &columns
+		diff.Changes = append(diff.Changes, fmt.Sprintf("frozen columns: %d → %d", grid.FrozenColumnCount, columns))
+	}
+	if tabIndex >= 0 && int64(tabIndex) != current.Index {
+		diff.Index = &tabIndex
+		diff.Changes = append(diff.Changes, fmt.Sprintf("tab position: %d → %d", current.Index+1, tabIndex+1))
+	}
+	if resource.TabColor != "" {
+		tabColor := current.TabColor
+		if current.TabColorStyle != nil && current.TabColorStyle.RgbColor != nil {
+			tabColor = current.TabColorStyle.RgbColor
+		}
+		if currentColor := colorHex(tabColor, "none"); !strings.EqualFold(currentColor, resource.TabColor) {
+			diff.TabColor = resource.TabColor
+			diff.Changes = append(diff.Changes, fmt.Sprintf("tab color: %s → %s", currentColor, resource.TabColor))
+		}
+	}
+	if resource.HiddenSheet != nil && *resource.HiddenSheet != current.Hidden {
+		hidden := *resource.HiddenSheet
+		diff.Hidden = &hidden
+		diff.Changes = append(diff.Changes, fmt.Sprintf("hidden: %t → %t", current.Hidden, hidden))
+	}
+	if resource.RightToLeft != nil && *resource.RightToLeft != current.RightToLeft {
+		rightToLeft := *resource.RightToLeft
+		diff.RightToLeft = &rightToLeft
+		diff.Changes = append(diff.Changes, fmt.Sprintf("right-to-left: %t → %t", current.RightToLeft, rightToLeft))
+	}
+
+	if len(diff.Changes) == 0 {
+		return nil
+	}
+	return diff
+}
+
+// TabIndexes returns the position each resource's tab should have in a spreadsheet, keyed by sheet name.
+// The managed tabs are sorted by their position in the schema and take the positions they currently
+// occupy between them, so unmanaged tabs stay where they are. Resources whose tab does not exist are skipped.
+func TabIndexes(schemaConfig *schema.Schema, spreadsheetID string, tabs []*sheets.Sheet) map[string]int {
+	current := make(map[string]int64)
+	for _, tab := range tabs {
+		current[tab.Properties.Title] = tab.Properties.Index
+	}
+
+	names := []string{}
+	slots := []int{}
+	for _, resource := range schemaConfig.Resources {
+		id, err := sheet.ExtractSpreadsheetID(resource.Path)
+		if err != nil || id != spreadsheetID {
+			continue
+		}
+		index, ok := current[resource.Name]
+		if !ok {
+			continue
+		}
+		names = append(names, resource.Name)
+		slots = append(slots, int(index))
+	}
+	sort.Ints(slots)
+
+	indexes := make(map[string]int, len(names))
+	for i, name := range names {
+		indexes[name] = slots[i]
+	}
+	return indexes
+}
+
+// SheetPropertiesRequest builds the updateSheetProperties request for the changed properties
+func SheetPropertiesRequest(sheetID int64, diff SheetPropertiesDiff) *sheets.Request {
+	properties := &sheets.SheetProperties{
+		SheetId:        sheetID,
+		GridProperties: &sheets.GridProperties{},
+	}
+	fields := []string{}
+
+	if diff.FrozenRows != nil {
+		properties.GridProperties.FrozenRowCount = int64(*diff.FrozenRows)
+		properties.GridProperties.ForceSendFields = append(properties.GridProperties.ForceSendFields, "FrozenRowCount")
+		fields = append(fields, "gridProperties.frozenRowCount")
+	}
+	if diff.FrozenColumns != nil {
+		properties.GridProperties.FrozenColumnCount = int64(*diff.FrozenColumns)
+		properties.GridProperties.ForceSendFields = append(properties.GridProperties.ForceSendFields, "FrozenColumnCount")
+		fields = append(fields, "gridProperties.frozenColumnCount")
+	}
+	if diff.Index != nil {
+		properties.Index = int64(*diff.Index)
+		properties.ForceSendFields = append(properties.ForceSendFields, "Index")
+		fields = append(fields, "index")
+	}
+	if diff.TabColor != "" {
+		properties.TabColorStyle = &sheets.ColorStyle{RgbColor: parseColor(diff.TabColor)}
+		fields = append(fields, "tabColorStyle")
+	}
+	if diff.Hidden != nil {
+		properties.Hidden = *diff.Hidden
+		properties.ForceSendFields = append(properties.ForceSendFields, "Hidden")
+		fields = append(fields, "hidden")
+	}
+	if diff.RightToLeft != nil {
+		properties.RightToLeft = *diff.RightToLeft
+		properties.ForceSendFields = append(properties.ForceSendFields, "RightToLeft")
+		fields = append(fields, "rightToLeft")
+	}
+
+	return &sheets.Request{
+		UpdateSheetProperties: &sheets.UpdateSheetPropertiesRequest{
+			Properties: properties,
+			Fields:     strings.Join(fields, ","),
+		},
+	}
+}
+
+// inverseSheetPropertiesRequest builds the request that puts back the properties a diff changes as they
+// are in current, moving the tab back from the position the diff gives it
+func inverseSheetPropertiesRequest(diff SheetPropertiesDiff, current *sheets.SheetProperties) *sheets.Request {
+	grid := current.GridProperties
+	if grid == nil {
+		grid = &sheets.GridProperties{}
+	}
+
+	inverse := SheetPropertiesDiff{}
+	if diff.FrozenRows != nil {
+		rows := int(grid.FrozenRowCount)
+		inverse.FrozenRows = &rows
+	}
+	if diff.FrozenColumns != nil {
+		columns := int(grid.FrozenColumnCount)
+		inverse.FrozenColumns = &columns
+	}
+	if diff.Index != nil {
+		index := moveIndex(*diff.Index, int(current.Index))
+		inverse.Index = &index
+	}
+	if diff.TabColor != "" {
+		inverse.TabColor = styledColorHex(current.TabColorStyle, current.TabColor, "")
+	}
+	if diff.Hidden != nil {
+		hidden := current.Hidden
+		inverse.Hidden = &hidden
+	}
+	if diff.RightToLeft != nil {
+		rightToLeft := current.RightToLeft
+		inverse.RightToLeft = &rightToLeft
+	}
+
+	request := SheetPropertiesRequest(current.SheetId, inverse)
+	// A tab that had no color gets its color cleared again
+	if diff.TabColor != "" && inverse.TabColor == "" {
+		update := request.UpdateSheetProperties
+		if update.Fields != "" {
+			update.Fields += ","
+		}
+		update.Fields += "tabColorStyle"
+	}
+	return request
+}
+
+// moveIndex converts a target tab position into the index Sheets expects, which counts
+// positions as they are before the tab is moved
+func moveIndex(current, target int) int {
+	if target > current {
+		return target + 1
+	}
+	return target
+}
+
+func describeSheetPropertiesDiff(d *SheetPropertiesDiff) string {
+	return fmt.Sprintf("Update sheet properties (%s)", strings.Join(d.Changes, ", "))
+}
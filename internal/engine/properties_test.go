@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestCompareSheetProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource schema.Resource
+		current  *sheets.SheetProperties
+		want     []string
+	}{
+		{
+			name:     "header row frozen by default",
+			resource: schema.Resource{HeaderRow: 2},
+			current:  &sheets.SheetProperties{GridProperties: &sheets.GridProperties{FrozenColumnCount: 3}},
+			want:     []string{"frozen rows: 0 → 2"},
+		},
+		{
+			name:     "up to date",
+			resource: schema.Resource{HeaderRow: 1, FrozenColumns: intPtr(1)},
+			current:  &sheets.SheetProperties{GridProperties: &sheets.GridProperties{FrozenRowCount: 1, FrozenColumnCount: 1}},
+			want:     nil,
+		},
+		{
+			name:     "unfreeze",
+			resource: schema.Resource{HeaderRow: 1, FrozenRows: intPtr(0), FrozenColumns: intPtr(0)},
+			current:  &sheets.SheetProperties{GridProperties: &sheets.GridProperties{FrozenRowCount: 1, FrozenColumnCount: 2}},
+			want:     []string{"frozen rows: 1 → 0", "frozen columns: 2 → 0"},
+		},
+		{
+			name:     "new sheet",
+			resource: schema.Resource{HeaderRow: 1, FrozenColumns: intPtr(1)},
+			current:  nil,
+			want:     []string{"frozen rows: 0 → 1", "frozen columns: 0 → 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := compareSheetProperties(tt.resource, tt.current, -1)
+			if tt.want == nil {
+				if diff != nil {
+					t.Errorf("expected no changes, got %+v", diff)
+				}
+				return
+			}
+			if diff == nil || !reflect.DeepEqual(diff.Changes, tt.want) {
+				t.Errorf("compareSheetProperties() = %+v, want changes %v", diff, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareTabProperties(t *testing.T) {
+	hidden, rightToLeft := true, false
+	resource := schema.Resource{
+		HeaderRow:   1,
+		TabColor:    "#FF0000",
+		HiddenSheet: &hidden,
+		RightToLeft: &rightToLeft,
+	}
+	current := &sheets.SheetProperties{
+		Index:          2,
+		GridProperties: &sheets.GridProperties{FrozenRowCount: 1},
+		TabColorStyle:  &sheets.ColorStyle{RgbColor: &sheets.Color{Green: 1}},
+		RightToLeft:    true,
+	}
+
+	diff := compareSheetProperties(resource, current, 0)
+	want := []string{
+		"tab position: 3 → 1",
+		"tab color: #00ff00 → #FF0000",
+		"hidden: false → true",
+		"right-to-left: true → false",
+	}
+	if diff == nil || !reflect.DeepEqual(diff.Changes, want) {
+		t.Fatalf("compareSheetProperties() = %+v, want changes %v", diff, want)
+	}
+
+	current = &sheets.SheetProperties{
+		Index:          0,
+		GridProperties: &sheets.GridProperties{FrozenRowCount: 1},
+		TabColor:       &sheets.Color{Red: 1},
+		Hidden:         true,
+	}
+	if diff := compareSheetProperties(resource, current, 0); diff != nil {
+		t.Errorf("expected no changes, got %+v", diff)
+	}
+}
+
+func TestTabIndexes(t *testing.T) {
+	path := "https://docs.google.com/spreadsheets/d/abc/edit"
+	schemaConfig := &schema.Schema{
+		Resources: []schema.Resource{
+			{Name: "orders", Path: path},
+			{Name: "users", Path: path},
+			{Name: "other", Path: "https://docs.google.com/spreadsheets/d/xyz/edit"},
+			{Name: "missing", Path: path},
+		},
+	}
+	tabs := []*sheets.Sheet{
+		{Properties: &sheets.SheetProperties{Title: "users", Index: 0}},
+		{Properties: &sheets.SheetProperties{Title: "notes", Index: 1}},
+		{Properties: &sheets.SheetProperties{Title: "orders", Index: 2}},
+		{Properties: &sheets.SheetProperties{Title: "other", Index: 3}},
+	}
+
+	got := TabIndexes(schemaConfig, "abc", tabs)
+	want := map[string]int{"orders": 0, "users": 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TabIndexes() = %v, want %v", got, want)
+	}
+}
+
+func TestMoveIndex(t *testing.T) {
+	if got := moveIndex(0, 2); got != 3 {
+		t.Errorf("moving right: got %d, want 3", got)
+	}
+	if got := moveIndex(2, 0); got != 0 {
+		t.Errorf("moving left: got %d, want 0", got)
+	}
+}
+
+func TestSheetPropertiesRequest(t *testing.T) {
+	request := SheetPropertiesRequest(7, SheetPropertiesDiff{FrozenRows: intPtr(0)})
+
+	update := request.UpdateSheetProperties
+	if update.Fields != "gridProperties.frozenRowCount" {
+		t.Errorf("unexpected field mask %q", update.Fields)
+	}
+	if update.Properties.SheetId != 7 || !reflect.DeepEqual(update.Properties.GridProperties.ForceSendFields, []string{"FrozenRowCount"}) {
+		t.Errorf("expected zero frozen row count to be sent, got %+v", update.Properties)
+	}
+}
+
+func TestInverseSheetPropertiesRequest(t *testing.T) {
+	current := &sheets.SheetProperties{
+		SheetId:        7,
+		Index:          1,
+		GridProperties: &sheets.GridProperties{FrozenRowCount: 2},
+	}
+	hidden := true
+
+	tests := []struct {
+		name       string
+		diff       SheetPropertiesDiff
+		wantFields string
+		check      func(*sheets.SheetProperties) bool
+	}{
+		{
+			name:       "frozen rows and visibility",
+			diff:       SheetPropertiesDiff{FrozenRows: intPtr(1), Hidden: &hidden},
+			wantFields: "gridProperties.frozenRowCount,hidden",
+			check: func(p *sheets.SheetProperties) bool {
+				return p.GridProperties.FrozenRowCount == 2 && !p.Hidden
+			},
+		},
+		{
+			name:       "tab moved to the end",
+			diff:       SheetPropertiesDiff{Index: intPtr(3)},
+			wantFields: "index",
+			check: func(p *sheets.SheetProperties) bool {
+				return p.Index == 1
+			},
+		},
+		{
+			name:       "tab color added",
+			diff:       SheetPropertiesDiff{TabColor: "#ff0000"},
+			wantFields: "tabColorStyle",
+			check: func(p *sheets.SheetProperties) bool {
+				return p.TabColorStyle == nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := inverseSheetPropertiesRequest(tt.diff, current).UpdateSheetProperties
+			if update.Fields != tt.wantFields {
+				t.Errorf("field mask = %q, want %q", update.Fields, tt.wantFields)
+			}
+			if update.Properties.SheetId != 7 || !tt.check(update.Properties) {
+				t.Errorf("unexpected properties %+v", update.Properties)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+)
+
+// CellError describes a cell whose value does not conform to its field definition
+type CellError struct {
+	Row     int // Row number in the sheet (1-based)
+	Column  int // Column index in the sheet (0-based)
+	Field   string
+	Message string
+}
+
+// Cell returns the A1 reference of the cell
+func (e CellError) Cell() string {
+	return fmt.Sprintf("%s%d", sheet.ColumnToLetter(e.Column), e.Row)
+}
+
+// Record is a data row converted to typed values, keyed by field name
+type Record struct {
+	Row    int // Row number in the sheet (1-based)
+	Values map[string]any
+}
+
+// ReadRecords converts the values of a sheet into records of the resource's fields.
+// values must start at the first row of the sheet, as returned when reading the whole sheet.
+// Cells that fail to convert are reported as CellErrors and left out of their record.
+func ReadRecords(resource schema.Resource, values [][]any) ([]Record, []CellError, error) {
+	headerRow := resource.HeaderRow
+	if headerRow == 0 {
+		headerRow = 1
+	}
+	if len(values) < headerRow {
+		return nil, nil, fmt.Errorf("header row %d of sheet %s is empty", headerRow, resource.Name)
+	}
+
+	headers := FieldNames(resource, cellStrings(values[headerRow-1]))
+
+	columns := make([]int, len(resource.Fields))
+	missing := []string{}
+	for i, field := range resource.Fields {
+		columns[i] = -1
+		for j, header := range headers {
+			if header == field.Name {
+				columns[i] = j
+				break
+			}
+		}
+		if columns[i] == -1 {
+			missing = append(missing, field.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("sheet %s has no column for field(s) %s; run 'ss-migrate plan' to compare it with the schema",
+			resource.Name, strings.Join(missing, ", "))
+	}
+
+	records := []Record{}
+	cellErrors := []CellError{}
+	for i, row := range values[headerRow:] {
+		if isEmptyRow(row) {
+			continue
+		}
+
+		record := Record{
+			Row:    headerRow + 1 + i,
+			Values: make(map[string]any),
+		}
+		for j, field := range resource.Fields {
+			var raw any
+			if columns[j] < len(row) {
+				raw = row[columns[j]]
+			}
+
+			value, err := sheet.ConvertValueWithOptions(field.Type, field.Format, valueOptions(field), raw)
+			if err != nil {
+				cellErrors = append(cellErrors, CellError{
+					Row:     record.Row,
+					Column:  columns[j],
+					Field:   field.Name,
+					Message: err.Error(),
+				})
+				continue
+			}
+			record.Values[field.Name] = value
+		}
+		records = append(records, record)
+	}
+
+	return records, cellErrors, nil
+}
+
+// ExportRecord converts a record into JSON-friendly values, with arrays and objects kept as such
+func ExportRecord(resource schema.Resource, record Record) map[string]any {
+	exported := make(map[string]any, len(record.Values))
+	for _, field := range resource.Fields {
+		if value, ok := record.Values[field.Name]; ok {
+			exported[field.Name] = sheet.ExportValue(field.Type, field.Format, valueOptions(field), value)
+		}
+	}
+	return exported
+}
+
+func valueOptions(field schema.Field) sheet.ValueOptions {
+	return sheet.ValueOptions{
+		Delimiter: field.Delimiter,
+		ItemType:  field.ItemType,
+	}
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+)
+
+func TestReadRecords(t *testing.T) {
+	resource := schema.Resource{
+		Name:      "orders",
+		HeaderRow: 1,
+		Fields: []schema.Field{
+			{Name: "id", Type: "integer"},
+			{Name: "tags", Type: "array", Delimiter: "|"},
+			{Name: "sizes", Type: "array", ItemType: "integer"},
+			{Name: "meta", Type: "object"},
+		},
+	}
+
+	values := [][]any{
+		{"meta", "id", "tags", "sizes"},
+		{`{"gift": true}`, 1.0, "red|blue", "1,2"},
+		{},
+		{`{"gift": false}`, 2.0, "green", "3,x"},
+		{"[1]", 3.0, "", "4"},
+	}
+
+	records, cellErrors, err := ReadRecords(resource, values)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	exported := ExportRecord(resource, records[0])
+	want := map[string]any{
+		"id":    int64(1),
+		"tags":  []any{"red", "blue"},
+		"sizes": []any{int64(1), int64(2)},
+		"meta":  map[string]any{"gift": true},
+	}
+	if !reflect.DeepEqual(exported, want) {
+		t.Errorf("ExportRecord() = %#v, want %#v", exported, want)
+	}
+	if records[1].Row != 4 {
+		t.Errorf("expected second record on row 4, got %d", records[1].Row)
+	}
+
+	if len(cellErrors) != 2 {
+		t.Fatalf("expected 2 cell errors, got %+v", cellErrors)
+	}
+	if cellErrors[0].Cell() != "D4" || cellErrors[0].Field != "sizes" {
+		t.Errorf("expected error in D4 (sizes), got %s (%s)", cellErrors[0].Cell(), cellErrors[0].Field)
+	}
+	if cellErrors[1].Cell() != "A5" || cellErrors[1].Field != "meta" {
+		t.Errorf("expected error in A5 (meta), got %s (%s)", cellErrors[1].Cell(), cellErrors[1].Field)
+	}
+}
+
+func TestReadRecordsMissingColumn(t *testing.T) {
+	resource := schema.Resource{
+		Name:      "orders",
+		HeaderRow: 1,
+		Fields:    []schema.Field{{Name: "id", Type: "integer"}, {Name: "tags", Type: "array"}},
+	}
+
+	_, _, err := ReadRecords(resource, [][]any{{"id"}, {1.0}})
+	if err == nil {
+		t.Fatal("expected error for missing column")
+	}
+}
@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+)
+
+// renameThreshold is the score from which a removed column and an added field are suggested as a rename
+const renameThreshold = 0.6
+
+// maxRenameSamples is the number of non-blank values of a removed column checked against the added field
+const maxRenameSamples = 20
+
+// renameCandidate is a removed column that may hold an added field under another header
+type renameCandidate struct {
+	From    FieldInfo
+	To      schema.Field
+	Score   float64
+	Reasons []string
+}
+
+// scoreRename rates how likely a removed column is the added field under another header.
+// The header similarity weighs most, followed by the column keeping its position, the inferred type
+// matching the field, and the share of sample values that are valid for the field.
+func scoreRename(from FieldInfo, to FieldInfo, field schema.Field, samples []any) (float64, []string) {
+	score := 0.0
+	reasons := []string{}
+
+	similarity := headerSimilarity(from.Header, to.Header)
+	if similarity < headerSimilarity(from.Header, to.Name) {
+		similarity = headerSimilarity(from.Header, to.Name)
+	}
+	score += 0.5 * similarity
+	if similarity >= 0.5 {
+		reasons = append(reasons, "similar header")
+	}
+
+	if from.Column == to.Position {
+		score += 0.2
+		reasons = append(reasons, "same position")
+	}
+	if from.Type == to.Type {
+		score += 0.15
+		reasons = append(reasons, "same type")
+	}
+
+	checked, valid := 0, 0
+	for _, value := range samples {
+		if isBlankCell(value) {
+			continue
+		}
+		checked++
+		if _, err := sheet.ConvertValueWithOptions(to.Type, to.Format, valueOptions(field), value); err == nil {
+			valid++
+		}
+		if checked == maxRenameSamples {
+			break
+		}
+	}
+	if checked > 0 {
+		score += 0.15 * float64(valid) / float64(checked)
+		if valid == checked {
+			reasons = append(reasons, "values fit the field")
+		}
+	}
+
+	return score, reasons
+}
+
+// headerSimilarity compares two headers after normalization, from 0 (nothing in common) to 1 (equal)
+func headerSimilarity(a, b string) float64 {
+	ra, rb := []rune(schema.NormalizeHeader(a)), []rune(schema.NormalizeHeader(b))
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// findRenames pairs removed columns with added fields whose score reaches the threshold,
+// best matches first, using each column and field at most once.
+// samples holds the first data values of the removed columns by name.
+func findRenames(diff *SheetDiff, resource schema.Resource, samples map[string][]any) []renameCandidate {
+	candidates := []renameCandidate{}
+	for _, from := range diff.FieldsToRemove {
+		for _, to := range diff.FieldsToAdd {
+			var field schema.Field
+			for _, f := range resource.Fields {
+				if f.Name == to.Name {
+					field = f
+					break
+				}
+			}
+			score, reasons := scoreRename(from, to, field, samples[from.Name])
+			if score >= renameThreshold {
+				candidates = append(candidates, renameCandidate{From: from, To: field, Score: score, Reasons: reasons})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	usedFrom := make(map[string]bool)
+	usedTo := make(map[string]bool)
+	renames := []renameCandidate{}
+	for _, candidate := range candidates {
+		if usedFrom[candidate.From.Name] || usedTo[candidate.To.Name] {
+			continue
+		}
+		usedFrom[candidate.From.Name] = true
+		usedTo[candidate.To.Name] = true
+		renames = append(renames, candidate)
+	}
+	return renames
+}
+
+// describeRename suggests the schema changes that rename the column rather than replace it. Declaring its
+// current header as the field's label makes it match the existing column, and the apply then tags the column
+// with the field's ID. Once the label is restored, the column is matched by that tag and only its header
+// cell is rewritten.
+func describeRename(rename renameCandidate) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Column %s %q looks like field %s (%.0f%%: %s). To keep the column and its data, rename it in two applies:\n",
+		sheet.ColumnToLetter(rename.From.Column), rename.From.Header, rename.To.Name, rename.Score*100, strings.Join(rename.Reasons, ", ")))
+	if rename.To.Header == "" {
+		sb.WriteString(fmt.Sprintf("      1. Add x-header: %q to field %s and apply, which keeps the column and tags it with the field's ID\n", rename.From.Header, rename.To.Name))
+		sb.WriteString(fmt.Sprintf("      2. Remove that x-header and apply again, which rewrites the header cell to %q", rename.To.HeaderText()))
+	} else {
+		sb.WriteString(fmt.Sprintf("      1. Set x-header: %q on field %s and apply, which keeps the column and tags it with the field's ID\n", rename.From.Header, rename.To.Name))
+		sb.WriteString(fmt.Sprintf("      2. Set x-header back to %q and apply again, which rewrites the header cell", rename.To.Header))
+	}
+	return sb.String()
+}
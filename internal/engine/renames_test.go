@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"name", "name", 0},
+		{"custmer", "customer", 1},
+		{"kitten", "sitting", 3},
+		{"注文", "注文日", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFindRenames(t *testing.T) {
+	resource := schema.Resource{
+		Name: "customers",
+		Fields: []schema.Field{
+			{Name: "id", Type: "integer"},
+			{Name: "customer_name", Type: "string"},
+			{Name: "signup_date", Type: "datetime", Format: "date"},
+			{Name: "score", Type: "number"},
+		},
+	}
+	schemaFields := convertSchemaFields(resource.Fields)
+	diff := &SheetDiff{
+		FieldsToRemove: []FieldInfo{
+			{Name: "custmer_name", Header: "custmer_name", Type: "string", Column: 1},
+			{Name: "legacy_flag", Header: "legacy_flag", Type: "boolean", Column: 4},
+		},
+		FieldsToAdd: []FieldInfo{schemaFields[1], schemaFields[3]},
+	}
+	samples := map[string][]any{
+		"custmer_name": {"Alice", "Bob"},
+		"legacy_flag":  {true, false},
+	}
+
+	renames := findRenames(diff, resource, samples)
+	if len(renames) != 1 {
+		t.Fatalf("expected 1 rename, got %+v", renames)
+	}
+	rename := renames[0]
+	if rename.From.Name != "custmer_name" || rename.To.Name != "customer_name" {
+		t.Errorf("unexpected rename %s → %s", rename.From.Name, rename.To.Name)
+	}
+
+	suggestion := describeRename(rename)
+	for _, want := range []string{
+		`Column B "custmer_name" looks like field customer_name`,
+		"similar header, same position, same type, values fit the field",
+		"      1. Add x-header: \"custmer_name\" to field customer_name and apply",
+		"      2. Remove that x-header and apply again, which rewrites the header cell to \"customer_name\"",
+	} {
+		if !strings.Contains(suggestion, want) {
+			t.Errorf("suggestion %q does not contain %q", suggestion, want)
+		}
+	}
+}
+
+func TestDescribeRenameOfLabelledField(t *testing.T) {
+	rename := renameCandidate{
+		From:    FieldInfo{Name: "Custmer", Header: "Custmer", Column: 0},
+		To:      schema.Field{Name: "customer_name", Header: "Customer"},
+		Score:   0.8,
+		Reasons: []string{"similar header"},
+	}
+
+	suggestion := describeRename(rename)
+	for _, want := range []string{
+		"      1. Set x-header: \"Custmer\" on field customer_name and apply",
+		"      2. Set x-header back to \"Customer\" and apply again",
+	} {
+		if !strings.Contains(suggestion, want) {
+			t.Errorf("suggestion %q does not contain %q", suggestion, want)
+		}
+	}
+}
+
+func TestFindRenamesUsesEachFieldOnce(t *testing.T) {
+	resource := schema.Resource{Fields: []schema.Field{{Name: "email", Type: "string"}}}
+	diff := &SheetDiff{
+		FieldsToRemove: []FieldInfo{
+			{Name: "e-mail", Header: "e-mail", Type: "string", Column: 3},
+			{Name: "emial", Header: "emial", Type: "string", Column: 0},
+		},
+		FieldsToAdd: convertSchemaFields(resource.Fields),
+	}
+
+	renames := findRenames(diff, resource, nil)
+	if len(renames) != 1 || renames[0].From.Name != "emial" {
+		t.Errorf("expected only the best match emial, got %+v", renames)
+	}
+}
@@ -0,0 +1,280 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+// defaultColumnWidth is the width in pixels of a column that has never been resized
+const defaultColumnWidth = 100
+
+// StyleDiff represents a change to the appearance of a column or of the header row
+type StyleDiff struct {
+	Type    ChangeType
+	Field   string // Empty for the header row
+	Style   schema.Style
+	Changes []string // Properties that differ, e.g. "bold: false → true"
+}
+
+// wrapStrategies maps schema wrap values to Sheets wrap strategies
+var wrapStrategies = map[string]string{
+	"overflow": "OVERFLOW_CELL",
+	"clip":     "CLIP",
+	"wrap":     "WRAP",
+}
+
+// CompareStyle lists the properties of a style that differ from a cell format and a column width.
+// Properties that are not set in the style are ignored, as is the width when pixelSize is negative
+// or the style asks for automatic sizing.
+func CompareStyle(style schema.Style, format *sheets.CellFormat, pixelSize int64) []string {
+	if format == nil {
+		format = &sheets.CellFormat{}
+	}
+	textFormat := format.TextFormat
+	if textFormat == nil {
+		textFormat = &sheets.TextFormat{}
+	}
+
+	changes := []string{}
+	compare := func(name, current, desired string) {
+		if desired != "" && !strings.EqualFold(current, desired) {
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", name, current, desired))
+		}
+	}
+
+	if style.Width != nil && !style.Width.Auto && pixelSize >= 0 {
+		if pixelSize == 0 {
+			pixelSize = defaultColumnWidth
+		}
+		compare("width", fmt.Sprintf("%dpx", pixelSize), style.Width.String())
+	}
+	compare("horizontal-alignment", valueOrNone(strings.ToLower(format.HorizontalAlignment)), style.HorizontalAlignment)
+	compare("vertical-alignment", valueOrNone(strings.ToLower(format.VerticalAlignment)), style.VerticalAlignment)
+	compare("wrap", currentWrap(format.WrapStrategy), style.Wrap)
+	if style.Bold != nil {
+		compare("bold", strconv.FormatBool(textFormat.Bold), strconv.FormatBool(*style.Bold))
+	}
+	fontColor := textFormat.ForegroundColor
+	if textFormat.ForegroundColorStyle != nil && textFormat.ForegroundColorStyle.RgbColor != nil {
+		fontColor = textFormat.ForegroundColorStyle.RgbColor
+	}
+	compare("font-color", colorHex(fontColor, "#000000"), style.FontColor)
+	backgroundColor := format.BackgroundColor
+	if format.BackgroundColorStyle != nil && format.BackgroundColorStyle.RgbColor != nil {
+		backgroundColor = format.BackgroundColorStyle.RgbColor
+	}
+	compare("background-color", colorHex(backgroundColor, "#ffffff"), style.BackgroundColor)
+
+	return changes
+}
+
+// DescribeStyle lists the properties set in a style, e.g. "bold: true"
+func DescribeStyle(style schema.Style) []string {
+	properties := []string{}
+	add := func(name, value string) {
+		if value != "" {
+			properties = append(properties, fmt.Sprintf("%s: %s", name, value))
+		}
+	}
+
+	if style.Width != nil {
+		add("width", style.Width.String())
+	}
+	add("horizontal-alignment", style.HorizontalAlignment)
+	add("vertical-alignment", style.VerticalAlignment)
+	add("wrap", style.Wrap)
+	if style.Bold != nil {
+		add("bold", strconv.FormatBool(*style.Bold))
+	}
+	add("font-color", style.FontColor)
+	add("background-color", style.BackgroundColor)
+	return properties
+}
+
+// StyleCellFormat builds the cell format for a style together with the field mask
+// that limits a repeatCell request to the properties set in the style
+func StyleCellFormat(style schema.Style) (*sheets.CellFormat, string) {
+	format := &sheets.CellFormat{}
+	fields := []string{}
+
+	if style.HorizontalAlignment != "" {
+		format.HorizontalAlignment = strings.ToUpper(style.HorizontalAlignment)
+		fields = append(fields, "userEnteredFormat.horizontalAlignment")
+	}
+	if style.VerticalAlignment != "" {
+		format.VerticalAlignment = strings.ToUpper(style.VerticalAlignment)
+		fields = append(fields, "userEnteredFormat.verticalAlignment")
+	}
+	if style.Wrap != "" {
+		format.WrapStrategy = wrapStrategies[style.Wrap]
+		fields = append(fields, "userEnteredFormat.wrapStrategy")
+	}
+	if style.Bold != nil || style.FontColor != "" {
+		format.TextFormat = &sheets.TextFormat{}
+	}
+	if style.Bold != nil {
+		format.TextFormat.Bold = *style.Bold
+		format.TextFormat.ForceSendFields = []string{"Bold"}
+		fields = append(fields, "userEnteredFormat.textFormat.bold")
+	}
+	if style.FontColor != "" {
+		format.TextFormat.ForegroundColorStyle = &sheets.ColorStyle{RgbColor: parseColor(style.FontColor)}
+		fields = append(fields, "userEnteredFormat.textFormat.foregroundColorStyle")
+	}
+	if style.BackgroundColor != "" {
+		format.BackgroundColorStyle = &sheets.ColorStyle{RgbColor: parseColor(style.BackgroundColor)}
+		fields = append(fields, "userEnteredFormat.backgroundColorStyle")
+	}
+
+	return format, strings.Join(fields, ",")
+}
+
+// currentWrap converts a Sheets wrap strategy into the schema value, overflow being the default
+func currentWrap(strategy string) string {
+	switch strategy {
+	case "CLIP":
+		return "clip"
+	case "WRAP", "LEGACY_WRAP":
+		return "wrap"
+	}
+	return "overflow"
+}
+
+// valueOrNone returns the value, or "none" when it is not set
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// parseColor converts a #RRGGBB color into a Sheets color
+func parseColor(hex string) *sheets.Color {
+	rgb, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil {
+		return &sheets.Color{}
+	}
+	return &sheets.Color{
+		Red:             float64(rgb>>16&0xff) / 255,
+		Green:           float64(rgb>>8&0xff) / 255,
+		Blue:            float64(rgb&0xff) / 255,
+		ForceSendFields: []string{"Red", "Green", "Blue"},
+	}
+}
+
+// colorHex converts a Sheets color into #rrggbb, returning the fallback when the color is not set
+func colorHex(color *sheets.Color, fallback string) string {
+	if color == nil {
+		return fallback
+	}
+	channel := func(v float64) int64 {
+		return int64(math.Round(v * 255))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", channel(color.Red), channel(color.Green), channel(color.Blue))
+}
+
+// cellFormat returns the user-entered format of a cell in grid data, or nil when it has none
+func cellFormat(grid *sheets.GridData, row, column int) *sheets.CellFormat {
+	if grid == nil || row >= len(grid.RowData) || grid.RowData[row] == nil || column >= len(grid.RowData[row].Values) {
+		return nil
+	}
+	return grid.RowData[row].Values[column].UserEnteredFormat
+}
+
+// columnWidth returns the pixel size of a column in grid data
+func columnWidth(grid *sheets.GridData, column int) int64 {
+	if grid == nil || column >= len(grid.ColumnMetadata) || grid.ColumnMetadata[column] == nil {
+		return defaultColumnWidth
+	}
+	return grid.ColumnMetadata[column].PixelSize
+}
+
+// compareStyles determines which columns and whether the header row must be restyled.
+// grid holds the header row followed by the first data row and is nil when the sheet does not exist yet.
+func compareStyles(resource schema.Resource, headers []string, grid *sheets.GridData) []StyleDiff {
+	columns := make(map[string]int)
+	for i, header := range headers {
+		if _, ok := columns[header]; !ok && header != "" {
+			columns[header] = i
+		}
+	}
+
+	diffs := []StyleDiff{}
+	if resource.HeaderStyle != nil {
+		changes := []string{}
+		seen := make(map[string]bool)
+		for _, field := range resource.Fields {
+			column, ok := columns[field.Name]
+			if !ok {
+				continue
+			}
+			for _, change := range CompareStyle(*resource.HeaderStyle, cellFormat(grid, 0, column), -1) {
+				if !seen[change] {
+					seen[change] = true
+					changes = append(changes, change)
+				}
+			}
+		}
+		if len(columns) == 0 {
+			diffs = append(diffs, StyleDiff{Type: ChangeTypeAdd, Style: *resource.HeaderStyle, Changes: DescribeStyle(*resource.HeaderStyle)})
+		} else if len(changes) > 0 {
+			diffs = append(diffs, StyleDiff{Type: ChangeTypeModify, Style: *resource.HeaderStyle, Changes: changes})
+		}
+	}
+
+	for _, field := range resource.Fields {
+		if field.Style == nil {
+			continue
+		}
+
+		column, ok := columns[field.Name]
+		if !ok {
+			diffs = append(diffs, StyleDiff{Type: ChangeTypeAdd, Field: field.Name, Style: *field.Style, Changes: DescribeStyle(*field.Style)})
+			continue
+		}
+
+		changes := CompareStyle(*field.Style, cellFormat(grid, 1, column), columnWidth(grid, column))
+		if len(changes) > 0 {
+			diffs = append(diffs, StyleDiff{Type: ChangeTypeModify, Field: field.Name, Style: *field.Style, Changes: changes})
+		}
+	}
+
+	return diffs
+}
+
+// headerStyleRanges returns the header cells the header style covers: that of each field's column,
+// wherever the field sits among the columns named by names
+func headerStyleRanges(resource schema.Resource, names []string, sheetID int64, headerRow int) []*sheets.GridRange {
+	ranges := []*sheets.GridRange{}
+	for _, field := range resource.Fields {
+		column := slices.Index(names, field.Name)
+		if column == -1 {
+			continue
+		}
+		ranges = append(ranges, &sheets.GridRange{
+			SheetId:          sheetID,
+			StartRowIndex:    int64(headerRow - 1),
+			EndRowIndex:      int64(headerRow),
+			StartColumnIndex: int64(column),
+			EndColumnIndex:   int64(column + 1),
+		})
+	}
+	return ranges
+}
+
+func describeStyleDiff(d *StyleDiff) string {
+	target := "style"
+	if d.Field == "" {
+		target = "header style"
+	}
+	if d.Type == ChangeTypeAdd {
+		return fmt.Sprintf("Apply %s (%s)", target, strings.Join(d.Changes, ", "))
+	}
+	return fmt.Sprintf("Update %s (%s)", target, strings.Join(d.Changes, ", "))
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestCompareStyle(t *testing.T) {
+	style := schema.Style{
+		Width:               &schema.Width{Pixels: 120},
+		HorizontalAlignment: "center",
+		Wrap:                "wrap",
+		Bold:                boolPtr(true),
+		BackgroundColor:     "#FFF2CC",
+	}
+
+	tests := []struct {
+		name      string
+		format    *sheets.CellFormat
+		pixelSize int64
+		want      []string
+	}{
+		{
+			name:      "unformatted cell",
+			format:    nil,
+			pixelSize: 0,
+			want: []string{
+				"width: 100px → 120px",
+				"horizontal-alignment: none → center",
+				"wrap: overflow → wrap",
+				"bold: false → true",
+				"background-color: #ffffff → #FFF2CC",
+			},
+		},
+		{
+			name: "matching cell",
+			format: &sheets.CellFormat{
+				HorizontalAlignment: "CENTER",
+				WrapStrategy:        "WRAP",
+				TextFormat:          &sheets.TextFormat{Bold: true},
+				BackgroundColorStyle: &sheets.ColorStyle{
+					RgbColor: &sheets.Color{Red: 1, Green: 0.949, Blue: 0.8},
+				},
+			},
+			pixelSize: 120,
+			want:      []string{},
+		},
+		{
+			name:      "width ignored for header",
+			format:    &sheets.CellFormat{HorizontalAlignment: "CENTER", WrapStrategy: "WRAP", TextFormat: &sheets.TextFormat{Bold: true}, BackgroundColor: parseColor("#fff2cc")},
+			pixelSize: -1,
+			want:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareStyle(style, tt.format, tt.pixelSize)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareStyle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStyleCellFormat(t *testing.T) {
+	format, fields := StyleCellFormat(schema.Style{
+		Width:     &schema.Width{Auto: true},
+		Bold:      boolPtr(false),
+		FontColor: "#ff0000",
+	})
+
+	if fields != "userEnteredFormat.textFormat.bold,userEnteredFormat.textFormat.foregroundColorStyle" {
+		t.Errorf("unexpected field mask %q", fields)
+	}
+	if format.TextFormat.Bold || format.TextFormat.ForegroundColorStyle.RgbColor.Red != 1 {
+		t.Errorf("unexpected format %+v", format.TextFormat)
+	}
+
+	if _, fields := StyleCellFormat(schema.Style{Width: &schema.Width{Pixels: 80}}); fields != "" {
+		t.Errorf("expected empty mask for a width-only style, got %q", fields)
+	}
+}
+
+func TestCompareStyles(t *testing.T) {
+	resource := schema.Resource{
+		Name:        "users",
+		HeaderStyle: &schema.Style{Bold: boolPtr(true)},
+		Fields: []schema.Field{
+			{Name: "id", Type: "integer", Style: &schema.Style{HorizontalAlignment: "right"}},
+			{Name: "name", Type: "string"},
+			{Name: "email", Type: "string", Style: &schema.Style{Width: &schema.Width{Auto: true}}},
+		},
+	}
+	grid := &sheets.GridData{
+		RowData: []*sheets.RowData{
+			{Values: []*sheets.CellData{
+				{UserEnteredFormat: &sheets.CellFormat{TextFormat: &sheets.TextFormat{Bold: true}}},
+				{},
+			}},
+			{Values: []*sheets.CellData{
+				{UserEnteredFormat: &sheets.CellFormat{HorizontalAlignment: "RIGHT"}},
+			}},
+		},
+	}
+
+	diffs := compareStyles(resource, []string{"id", "name"}, grid)
+	if len(diffs) != 2 {
+		t.Fatalf("expected 2 style diffs, got %+v", diffs)
+	}
+	if diffs[0].Field != "" || diffs[0].Type != ChangeTypeModify || !reflect.DeepEqual(diffs[0].Changes, []string{"bold: false → true"}) {
+		t.Errorf("expected header style update for the name column, got %+v", diffs[0])
+	}
+	if diffs[1].Field != "email" || diffs[1].Type != ChangeTypeAdd {
+		t.Errorf("expected style to be applied to the new email column, got %+v", diffs[1])
+	}
+}
+
+func TestHeaderStyleRanges(t *testing.T) {
+	resource := schema.Resource{
+		Name:   "users",
+		Fields: []schema.Field{{Name: "id"}, {Name: "name"}, {Name: "email"}},
+	}
+
+	ranges := headerStyleRanges(resource, []string{"memo", "name", "", "id"}, 7, 2)
+	columns := []int64{}
+	for _, r := range ranges {
+		if r.SheetId != 7 || r.StartRowIndex != 1 || r.EndRowIndex != 2 || r.EndColumnIndex != r.StartColumnIndex+1 {
+			t.Errorf("unexpected range %+v", r)
+		}
+		columns = append(columns, r.StartColumnIndex)
+	}
+	if want := []int64{3, 1}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("headerStyleRanges() columns = %v, want %v", columns, want)
+	}
+}
@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"github.com/ucpr/ss-migrate/internal/sheet"
+	"google.golang.org/api/sheets/v4"
+)
+
+// TableDiff represents a change to the objects that cover the whole table, such as banding and the basic filter
+type TableDiff struct {
+	Type    ChangeType
+	Banding bool     // Whether the banding must be added or updated
+	Filter  *bool    // Whether the basic filter must be set (true) or cleared (false), nil when unchanged
+	Changes []string // Descriptions of the changes, e.g. "filter: A1:C → A1:E"
+}
+
+// TableRange returns the range of the managed table from the given row down. It spans the columns from
+// the first to the last one holding a field in names, which map the columns to field names, or the
+// schema's columns when names is nil. The range is open-ended so that it covers rows added later.
+func TableRange(sheetID int64, resource schema.Resource, names []string, startRow int) *sheets.GridRange {
+	start, end := tableColumns(resource, names)
+	return &sheets.GridRange{
+		SheetId:          sheetID,
+		StartRowIndex:    int64(startRow - 1),
+		StartColumnIndex: int64(start),
+		EndColumnIndex:   int64(end),
+	}
+}
+
+// tableColumns returns the first column and the column past the last one holding a field
+func tableColumns(resource schema.Resource, names []string) (int, int) {
+	start, end := -1, 0
+	for i, name := range names {
+		if isManagedColumn(resource, name) {
+			if start == -1 {
+				start = i
+			}
+			end = i + 1
+		}
+	}
+	if start == -1 {
+		return 0, len(resource.Fields)
+	}
+	return start, end
+}
+
+// bandingStartRow returns the 1-based row where the banding starts, which is the header row when it has its own color
+func bandingStartRow(resource schema.Resource) int {
+	if resource.Banding != nil && resource.Banding.HeaderColor == "" {
+		return resource.HeaderRow + 1
+	}
+	return resource.HeaderRow
+}
+
+// NewBandedRange builds the banding of the table over the columns given by names, as for TableRange
+func NewBandedRange(sheetID int64, resource schema.Resource, names []string) *sheets.BandedRange {
+	properties := &sheets.BandingProperties{
+		FirstBandColorStyle:  &sheets.ColorStyle{RgbColor: parseColor(resource.Banding.FirstBandColor)},
+		SecondBandColorStyle: &sheets.ColorStyle{RgbColor: parseColor(resource.Banding.SecondBandColor)},
+	}
+	if resource.Banding.HeaderColor != "" {
+		properties.HeaderColorStyle = &sheets.ColorStyle{RgbColor: parseColor(resource.Banding.HeaderColor)}
+	}
+	return &sheets.BandedRange{
+		Range:         TableRange(sheetID, resource, names, bandingStartRow(resource)),
+		RowProperties: properties,
+	}
+}
+
+// tableBanding returns the banded range that belongs to the table, i.e. the first one overlapping
+// the table's columns and starting at or above its first data row
+func tableBanding(bandedRanges []*sheets.BandedRange, resource schema.Resource, names []string) *sheets.BandedRange {
+	start, end := tableColumns(resource, names)
+	for _, banded := range bandedRanges {
+		r := banded.Range
+		if r != nil && r.StartColumnIndex < int64(end) && r.EndColumnIndex > int64(start) && r.StartRowIndex <= int64(resource.HeaderRow) {
+			return banded
+		}
+	}
+	return nil
+}
+
+// sameTableRange reports whether a range starts at the given cell and spans the table's columns.
+// The end row is not compared as Sheets may report open-ended ranges with the sheet's row count.
+func sameTableRange(current, desired *sheets.GridRange) bool {
+	return current != nil && current.StartRowIndex == desired.StartRowIndex &&
+		current.StartColumnIndex == desired.StartColumnIndex && current.EndColumnIndex == desired.EndColumnIndex
+}
+
+// gridRangeA1 formats a range in A1 notation, leaving out the end row of open-ended ranges
+func gridRangeA1(r *sheets.GridRange) string {
+	if r == nil {
+		return "none"
+	}
+	end := sheet.ColumnToLetter(int(r.EndColumnIndex) - 1)
+	if r.EndRowIndex > 0 {
+		end += fmt.Sprintf("%d", r.EndRowIndex)
+	}
+	return fmt.Sprintf("%s%d:%s", sheet.ColumnToLetter(int(r.StartColumnIndex)), r.StartRowIndex+1, end)
+}
+
+// compareTable compares the banding and basic filter of a sheet with the table extent declared by the resource,
+// on the columns given by names as for TableRange. sheetMeta is nil when the sheet does not exist yet.
+func compareTable(resource schema.Resource, names []string, sheetMeta *sheets.Sheet) *TableDiff {
+	if sheetMeta == nil {
+		sheetMeta = &sheets.Sheet{}
+	}
+	diff := &TableDiff{Type: ChangeTypeModify}
+
+	if resource.Banding != nil {
+		desired := NewBandedRange(0, resource, names)
+		current := tableBanding(sheetMeta.BandedRanges, resource, names)
+		switch {
+		case current == nil:
+			diff.Banding = true
+			diff.Changes = append(diff.Changes, fmt.Sprintf("banding: add %s", gridRangeA1(desired.Range)))
+		case !sameTableRange(current.Range, desired.Range):
+			diff.Banding = true
+			diff.Changes = append(diff.Changes, fmt.Sprintf("banding: %s → %s", gridRangeA1(current.Range), gridRangeA1(desired.Range)))
+		case bandingColors(current.RowProperties) != bandingColors(desired.RowProperties):
+			diff.Banding = true
+			diff.Changes = append(diff.Changes, fmt.Sprintf("banding colors: %s → %s",
+				bandingColors(current.RowProperties), bandingColors(desired.RowProperties)))
+		}
+	}
+
+	if resource.Filter != nil {
+		var current *sheets.GridRange
+		if sheetMeta.BasicFilter != nil {
+			current = sheetMeta.BasicFilter.Range
+		}
+		desired := TableRange(0, resource, names, resource.HeaderRow)
+		switch {
+		case *resource.Filter && current == nil:
+			diff.Filter = resource.Filter
+			diff.Changes = append(diff.Changes, fmt.Sprintf("filter: add %s", gridRangeA1(desired)))
+		case *resource.Filter && !sameTableRange(current, desired):
+			diff.Filter = resource.Filter
+			diff.Changes = append(diff.Changes, fmt.Sprintf("filter: %s → %s", gridRangeA1(current), gridRangeA1(desired)))
+		case !*resource.Filter && current != nil:
+			diff.Filter = resource.Filter
+			diff.Changes = append(diff.Changes, fmt.Sprintf("filter: remove %s", gridRangeA1(current)))
+		}
+	}
+
+	if len(diff.Changes) == 0 {
+		return nil
+	}
+	return diff
+}
+
+// bandingColors summarizes the colors of banding properties as header/first/second
+func bandingColors(properties *sheets.BandingProperties) string {
+	if properties == nil {
+		return "none"
+	}
+	return strings.Join([]string{
+		styledColorHex(properties.HeaderColorStyle, properties.HeaderColor, "none"),
+		styledColorHex(properties.FirstBandColorStyle, properties.FirstBandColor, "none"),
+		styledColorHex(properties.SecondBandColorStyle, properties.SecondBandColor, "none"),
+	}, "/")
+}
+
+// TableRequests builds the requests that make the banding and basic filter cover the table's columns given by names
+func TableRequests(sheetMeta *sheets.Sheet, resource schema.Resource, names []string, tableDiff TableDiff) []*sheets.Request {
+	sheetID := sheetMeta.Properties.SheetId
+	requests := []*sheets.Request{}
+
+	if tableDiff.Banding && resource.Banding != nil {
+		banded := NewBandedRange(sheetID, resource, names)
+		if current := tableBanding(sheetMeta.BandedRanges, resource, names); current != nil {
+			banded.BandedRangeId = current.BandedRangeId
+			requests = append(requests, &sheets.Request{
+				UpdateBanding: &sheets.UpdateBandingRequest{
+					BandedRange: banded,
+					Fields:      "range,rowProperties",
+				},
+			})
+		} else {
+			requests = append(requests, &sheets.Request{
+				AddBanding: &sheets.AddBandingRequest{BandedRange: banded},
+			})
+		}
+	}
+
+	if tableDiff.Filter != nil {
+		if *tableDiff.Filter {
+			requests = append(requests, &sheets.Request{
+				SetBasicFilter: &sheets.SetBasicFilterRequest{
+					Filter: &sheets.BasicFilter{Range: TableRange(sheetID, resource, names, resource.HeaderRow)},
+				},
+			})
+		} else {
+			requests = append(requests, &sheets.Request{
+				ClearBasicFilter: &sheets.ClearBasicFilterRequest{SheetId: sheetID},
+			})
+		}
+	}
+
+	return requests
+}
+
+func describeTableDiff(d *TableDiff) string {
+	return fmt.Sprintf("Update table (%s)", strings.Join(d.Changes, ", "))
+}
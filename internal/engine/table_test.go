@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+	"google.golang.org/api/sheets/v4"
+)
+
+func tableResource(filter *bool, banding *schema.Banding) schema.Resource {
+	return schema.Resource{
+		Name:      "orders",
+		HeaderRow: 1,
+		Fields:    []schema.Field{{Name: "id"}, {Name: "status"}, {Name: "total"}},
+		Banding:   banding,
+		Filter:    filter,
+	}
+}
+
+func TestCompareTable(t *testing.T) {
+	on, off := true, false
+	banding := &schema.Banding{HeaderColor: "#5b95f9", FirstBandColor: "#ffffff", SecondBandColor: "#e8f0fe"}
+
+	tests := []struct {
+		name     string
+		resource schema.Resource
+		names    []string
+		sheet    *sheets.Sheet
+		want     []string
+	}{
+		{
+			name:     "new banding and filter",
+			resource: tableResource(&on, banding),
+			sheet:    &sheets.Sheet{},
+			want:     []string{"banding: add A1:C", "filter: add A1:C"},
+		},
+		{
+			name:     "filter left behind by removed columns",
+			resource: tableResource(&on, nil),
+			sheet: &sheets.Sheet{
+				BasicFilter: &sheets.BasicFilter{Range: &sheets.GridRange{StartColumnIndex: 0, EndColumnIndex: 5, EndRowIndex: 1000}},
+			},
+			want: []string{"filter: A1:E1000 → A1:C"},
+		},
+		{
+			name:     "banding without header color starts below the header",
+			resource: tableResource(nil, &schema.Banding{FirstBandColor: "#ffffff", SecondBandColor: "#e8f0fe"}),
+			sheet: &sheets.Sheet{
+				BandedRanges: []*sheets.BandedRange{{
+					Range:         &sheets.GridRange{StartRowIndex: 0, EndColumnIndex: 3},
+					RowProperties: NewBandedRange(0, tableResource(nil, banding), nil).RowProperties,
+				}},
+			},
+			want: []string{"banding: A1:C → A2:C"},
+		},
+		{
+			name:     "up to date",
+			resource: tableResource(&off, banding),
+			sheet: &sheets.Sheet{
+				BandedRanges: []*sheets.BandedRange{NewBandedRange(0, tableResource(nil, banding), nil)},
+			},
+			want: nil,
+		},
+		{
+			name:     "filter follows the managed columns",
+			resource: tableResource(&on, nil),
+			names:    []string{"memo", "id", "", "total", "status"},
+			sheet:    &sheets.Sheet{BasicFilter: &sheets.BasicFilter{Range: &sheets.GridRange{EndColumnIndex: 3}}},
+			want:     []string{"filter: A1:C → B1:E"},
+		},
+		{
+			name:     "remove filter",
+			resource: tableResource(&off, nil),
+			sheet:    &sheets.Sheet{BasicFilter: &sheets.BasicFilter{Range: &sheets.GridRange{EndColumnIndex: 3}}},
+			want:     []string{"filter: remove A1:C"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := compareTable(tt.resource, tt.names, tt.sheet)
+			if tt.want == nil {
+				if diff != nil {
+					t.Errorf("expected no changes, got %+v", diff)
+				}
+				return
+			}
+			if diff == nil || !reflect.DeepEqual(diff.Changes, tt.want) {
+				t.Errorf("compareTable() = %+v, want changes %v", diff, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanTableFollowsColumnChanges(t *testing.T) {
+	on := true
+	resource := tableResource(&on, nil)
+	sheetMeta := &sheets.Sheet{BasicFilter: &sheets.BasicFilter{Range: &sheets.GridRange{EndColumnIndex: 3}}}
+
+	if got := planTable(resource, nil, sheetMeta, &SheetDiff{}); got != nil {
+		t.Errorf("expected no table change, got %+v", got)
+	}
+
+	diff := &SheetDiff{FieldsToAdd: []FieldInfo{{Name: "note"}}, FieldsToRemove: []FieldInfo{{Name: "legacy"}}}
+	if got := planTable(resource, nil, sheetMeta, diff); got == nil {
+		t.Error("expected the filter to be checked again after the columns change")
+	}
+}
+
+func TestTableRequests(t *testing.T) {
+	on := true
+	banding := &schema.Banding{FirstBandColor: "#ffffff", SecondBandColor: "#e8f0fe"}
+	resource := tableResource(&on, banding)
+	sheetMeta := &sheets.Sheet{
+		Properties:   &sheets.SheetProperties{SheetId: 9},
+		BandedRanges: []*sheets.BandedRange{{BandedRangeId: 42, Range: &sheets.GridRange{EndColumnIndex: 2}}},
+	}
+
+	requests := TableRequests(sheetMeta, resource, nil, TableDiff{Banding: true, Filter: &on})
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	update := requests[0].UpdateBanding
+	if update == nil || update.BandedRange.BandedRangeId != 42 || update.BandedRange.Range.EndColumnIndex != 3 {
+		t.Errorf("expected banding 42 to be stretched to 3 columns, got %+v", requests[0])
+	}
+	filter := requests[1].SetBasicFilter
+	if filter == nil || filter.Filter.Range.SheetId != 9 || filter.Filter.Range.EndColumnIndex != 3 {
+		t.Errorf("expected basic filter over the table, got %+v", requests[1])
+	}
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/ucpr/ss-migrate/internal/schema"
+)
+
+// plannedChange finds the change of a plan that targets the same field or sheet setting as a residual change
+func plannedChange(changes []Change, residual Change) (Change, bool) {
+	for _, change := range changes {
+		if change.Path == residual.Path && change.Type == residual.Type &&
+			reflect.TypeOf(change.NewValue) == reflect.TypeOf(residual.NewValue) {
+			return change, true
+		}
+	}
+	// A change can remain with another type, e.g. a field that was added but is still reported as modified
+	for _, change := range changes {
+		if change.Path == residual.Path && !isSheetChange(change) && !isSheetChange(residual) {
+			return change, true
+		}
+	}
+	return Change{}, false
+}
+
+// residualErrors reports each change that a plan still shows after it has been applied, together with the
+// change of the applied plan that was supposed to make it unnecessary
+func residualErrors(applied, residual *DiffResult) []error {
+	errs := []error{}
+	for _, change := range residual.Changes {
+		if planned, ok := plannedChange(applied.Changes, change); ok {
+			errs = append(errs, fmt.Errorf("%s did not converge: the plan still shows %q after applying %q",
+				change.Path, change.Description, planned.Description))
+		} else {
+			errs = append(errs, fmt.Errorf("%s did not converge: the plan now shows %q, which no applied change accounts for",
+				change.Path, change.Description))
+		}
+	}
+	return errs
+}
+
+// verifyConverged plans each resource that was applied without errors again and records any change that
+// remains as an error in its result. The planner infers some settings, such as number formats, from the
+// sheet, so a schema it cannot read back would otherwise show the same change on every run.
+func (a *Applier) verifyConverged(ctx context.Context, planner *Planner, schemaConfig *schema.Schema, diffs []*DiffResult, results []*ApplyResult) {
+	for i, diff := range diffs {
+		result := results[i]
+		if !diff.HasChanges || !result.Success || result.ChangesApplied == 0 {
+			continue
+		}
+
+		resource := schemaConfig.Resources[i]
+		residual, err := planner.planSheet(ctx, schemaConfig, resource)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to verify %s: %w", resource.Name, err))
+			result.Success = false
+			continue
+		}
+		if !residual.HasChanges {
+			continue
+		}
+
+		result.Errors = append(result.Errors, residualErrors(diff, residual)...)
+		result.Success = false
+		result.Message = fmt.Sprintf("Applied %d changes, but %d change(s) remain in the plan afterwards", result.ChangesApplied, len(residual.Changes))
+	}
+}
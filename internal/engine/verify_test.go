@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestResidualErrors(t *testing.T) {
+	applied := &DiffResult{
+		Changes: []Change{
+			{Type: ChangeTypeAdd, Path: "orders.memo", Description: "Add field memo", NewValue: FieldInfo{Name: "memo"}},
+			{Type: ChangeTypeModify, Path: "orders.paid", Description: "Change type of paid from string to boolean", NewValue: FieldDiff{Name: "paid"}},
+			{Type: ChangeTypeModify, Path: "orders", Description: "Freeze 1 row", NewValue: SheetPropertiesDiff{}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		residual Change
+		want     string
+	}{
+		{
+			name:     "same change again",
+			residual: Change{Type: ChangeTypeModify, Path: "orders.paid", Description: "Change type of paid from string to boolean", NewValue: FieldDiff{Name: "paid"}},
+			want:     `orders.paid did not converge: the plan still shows "Change type of paid from string to boolean" after applying "Change type of paid from string to boolean"`,
+		},
+		{
+			name:     "added field still differs",
+			residual: Change{Type: ChangeTypeModify, Path: "orders.memo", Description: "Change format of memo", NewValue: FieldDiff{Name: "memo"}},
+			want:     `orders.memo did not converge: the plan still shows "Change format of memo" after applying "Add field memo"`,
+		},
+		{
+			name:     "sheet change matched by kind",
+			residual: Change{Type: ChangeTypeModify, Path: "orders", Description: "Freeze 1 row", NewValue: SheetPropertiesDiff{}},
+			want:     `orders did not converge: the plan still shows "Freeze 1 row" after applying "Freeze 1 row"`,
+		},
+		{
+			name:     "unplanned change",
+			residual: Change{Type: ChangeTypeModify, Path: "orders", Description: "Update table", NewValue: TableDiff{}},
+			want:     `orders did not converge: the plan now shows "Update table", which no applied change accounts for`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := residualErrors(applied, &DiffResult{Changes: []Change{tt.residual}, HasChanges: true})
+			if len(errs) != 1 || errs[0].Error() != tt.want {
+				t.Errorf("residualErrors() = %v, want [%s]", errs, tt.want)
+			}
+		})
+	}
+}
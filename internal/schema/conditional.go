@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConditionalFormat is a conditional formatting rule applied to the data cells of a field
+type ConditionalFormat struct {
+	Type string `yaml:"type"` // color-scale, text-contains or formula
+
+	// color-scale: colors for the lowest, middle (optional) and highest values.
+	// The scale spans the column's values unless min-value or max-value is given.
+	MinColor string   `yaml:"min-color"`
+	MidColor string   `yaml:"mid-color"`
+	MaxColor string   `yaml:"max-color"`
+	MinValue *float64 `yaml:"min-value"`
+	MaxValue *float64 `yaml:"max-value"`
+
+	// text-contains and formula: the condition and the format of matching cells
+	Text            string `yaml:"text"`
+	Formula         string `yaml:"formula"`
+	BackgroundColor string `yaml:"background-color"`
+	FontColor       string `yaml:"font-color"`
+	Bold            bool   `yaml:"bold"`
+}
+
+// validate checks that the options needed by the rule type are set
+func (cf ConditionalFormat) validate() error {
+	colors := []struct{ name, value string }{
+		{"min-color", cf.MinColor},
+		{"mid-color", cf.MidColor},
+		{"max-color", cf.MaxColor},
+		{"background-color", cf.BackgroundColor},
+		{"font-color", cf.FontColor},
+	}
+	for _, color := range colors {
+		if color.value != "" && !colorPattern.MatchString(color.value) {
+			return fmt.Errorf("%s must be a #RRGGBB color, got %q", color.name, color.value)
+		}
+	}
+
+	hasFormat := cf.BackgroundColor != "" || cf.FontColor != "" || cf.Bold
+	switch cf.Type {
+	case "color-scale":
+		if cf.MinColor == "" || cf.MaxColor == "" {
+			return fmt.Errorf("color-scale needs min-color and max-color")
+		}
+		if cf.MinValue != nil && cf.MaxValue != nil && *cf.MinValue >= *cf.MaxValue {
+			return fmt.Errorf("min-value must be less than max-value")
+		}
+	case "text-contains":
+		if cf.Text == "" {
+			return fmt.Errorf("text-contains needs text")
+		}
+		if !hasFormat {
+			return fmt.Errorf("text-contains needs background-color, font-color or bold")
+		}
+	case "formula":
+		if !strings.HasPrefix(cf.Formula, "=") {
+			return fmt.Errorf("formula must start with '='")
+		}
+		if !hasFormat {
+			return fmt.Errorf("formula needs background-color, font-color or bold")
+		}
+	default:
+		return fmt.Errorf("type must be one of color-scale, text-contains or formula, got %q", cf.Type)
+	}
+	return nil
+}
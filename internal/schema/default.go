@@ -10,6 +10,11 @@ const DefaultSchemaTemplate = `resources:
     # x-header-row: 1
     # optional: specify a specific column within the spreadsheet (default is 1)
     # x-header-column: 1
+    # optional: field(s) whose values must be unique and non-blank
+    # primaryKey: id
+    # optional: number of rows and columns to freeze (rows default to the header row)
+    # x-frozen-rows: 1
+    # x-frozen-columns: 1
     fields:
       - name: id
         type: integer
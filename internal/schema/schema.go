@@ -1,10 +1,18 @@
 package schema
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
 
 	"github.com/goccy/go-yaml"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 )
 
 type Schema struct {
@@ -12,19 +20,150 @@ type Schema struct {
 }
 
 type Resource struct {
-	Name         string  `yaml:"name"`
-	Path         string  `yaml:"path"`
-	HeaderRow    int     `yaml:"x-header-row"`
-	HeaderColumn int     `yaml:"x-header-column"`
-	Fields       []Field `yaml:"fields"`
+	Name             string     `yaml:"name"`
+	Path             string     `yaml:"path"`
+	HeaderRow        int        `yaml:"x-header-row"`
+	HeaderColumn     int        `yaml:"x-header-column"`
+	Fields           []Field    `yaml:"fields"`
+	PrimaryKey       PrimaryKey `yaml:"primaryKey"`
+	HeaderStyle      *Style     `yaml:"x-header-style"`
+	FrozenRows       *int       `yaml:"x-frozen-rows"`    // Defaults to the header row
+	FrozenColumns    *int       `yaml:"x-frozen-columns"` // Left as is when not set
+	TabColor         string     `yaml:"x-tab-color"`      // #RRGGBB
+	HiddenSheet      *bool      `yaml:"x-hidden-sheet"`
+	RightToLeft      *bool      `yaml:"x-right-to-left"`
+	Banding          *Banding   `yaml:"x-banding"`
+	Filter           *bool      `yaml:"x-filter"`            // Basic filter over the table
+	NamedRange       string     `yaml:"x-named-range"`       // Name of the table's named range, derived from the name by default
+	HeaderMatching   string     `yaml:"x-header-matching"`   // exact (default) or normalized
+	DuplicateHeaders string     `yaml:"x-duplicate-headers"` // error (default) or suffix
+	BlankHeaders     string     `yaml:"x-blank-headers"`     // error (default) or unmanaged
+	Order            string     `yaml:"x-order"`             // strict (default), append or ignore
+}
+
+// Banding describes the alternating row colors of a table
+type Banding struct {
+	HeaderColor     string `yaml:"header-color"` // Bands start below the header when set
+	FirstBandColor  string `yaml:"first-band-color"`
+	SecondBandColor string `yaml:"second-band-color"`
+}
+
+// FrozenRowCount returns the number of rows to freeze, which defaults to the rows up to the header
+func (r *Resource) FrozenRowCount() int {
+	if r.FrozenRows != nil {
+		return *r.FrozenRows
+	}
+	if r.HeaderRow == 0 {
+		return 1
+	}
+	return r.HeaderRow
+}
+
+// PrimaryKey lists the fields whose combined values must be unique and non-blank.
+// As in Frictionless, it may be written either as a single field name or as a list.
+type PrimaryKey []string
+
+// UnmarshalYAML accepts both `primaryKey: id` and `primaryKey: [id, code]`
+func (k *PrimaryKey) UnmarshalYAML(unmarshal func(any) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		if single != "" {
+			*k = PrimaryKey{single}
+		}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return fmt.Errorf("primaryKey must be a field name or a list of field names: %w", err)
+	}
+	*k = PrimaryKey(list)
+	return nil
+}
+
+// TableRangeName returns the name of the named range covering the table. A name derived from a
+// resource name whose characters had to be replaced ends in a hash of the resource name, so that
+// sheets such as 注文 and 顧客 do not end up with the same range.
+func (r *Resource) TableRangeName() string {
+	if r.NamedRange != "" {
+		return r.NamedRange
+	}
+	var sb strings.Builder
+	for i, c := range r.Name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+			sb.WriteRune(c)
+		case c >= '0' && c <= '9' || c == '.':
+			if i == 0 {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	if sb.String() != r.Name {
+		sum := sha256.Sum256([]byte(r.Name))
+		sb.WriteString("_" + hex.EncodeToString(sum[:4]))
+	}
+	return sb.String() + "_table"
+}
+
+// IsKeyField reports whether the named field is part of the primary key
+func (r *Resource) IsKeyField(name string) bool {
+	for _, key := range r.PrimaryKey {
+		if key == name {
+			return true
+		}
+	}
+	return false
 }
 
 type Field struct {
-	Name    string `yaml:"name"`
-	Type    string `yaml:"type"`
-	Format  string `yaml:"format"`
-	Protect bool   `yaml:"x-protect"`
-	Hidden  bool   `yaml:"x-hidden"`
+	Name               string `yaml:"name"`
+	Type               string `yaml:"type"`
+	Format             string `yaml:"format"`
+	Protect            bool   `yaml:"x-protect"`
+	Hidden             bool   `yaml:"x-hidden"`
+	NumberFormat       string `yaml:"x-number-format"`       // Raw Sheets number format pattern
+	DecimalPlaces      *int   `yaml:"x-decimal-places"`      // Digits after the decimal point
+	ThousandsSeparator bool   `yaml:"x-thousands-separator"` // Group digits with commas
+	Delimiter          string `yaml:"x-delimiter"`           // Separator between array items
+	ItemType           string `yaml:"x-item-type"`           // Type of array items
+	Formula            string `yaml:"x-formula"`             // Formula computing the column, {row} is replaced by the row number
+	Default            any    `yaml:"x-default"`             // Literal or formula written to existing rows when the column is added
+	Style              *Style `yaml:"x-style"`               // Appearance of the column's data cells
+	NamedRange         string `yaml:"x-named-range"`         // Named range covering the column's data cells
+	Title              string `yaml:"title"`                 // Human-readable name, shown in the header note
+	Description        string `yaml:"description"`           // Meaning of the field, shown in the header note
+	Header             string `yaml:"x-header"`              // Text shown in the header cell, the name by default
+	ID                 string `yaml:"x-id"`                  // Stable identity of the column, derived from the name by default
+
+	ConditionalFormats []ConditionalFormat `yaml:"x-conditional-formats"`
+}
+
+// HeaderText returns the text shown in the field's header cell
+func (f Field) HeaderText() string {
+	if f.Header != "" {
+		return f.Header
+	}
+	return f.Name
+}
+
+// ColumnID returns the stable ID that ss-migrate tags the field's column with. Without an explicit
+// x-id it is derived from the name, so setting x-id is what lets a field be renamed in the schema.
+func (f Field) ColumnID() string {
+	if f.ID != "" {
+		return f.ID
+	}
+	sum := sha256.Sum256([]byte(f.Name))
+	return hex.EncodeToString(sum[:8])
+}
+
+// NormalizeHeader folds the differences that are invisible or irrelevant in a header cell:
+// Unicode compatibility forms such as full-width letters, surrounding whitespace and case
+func NormalizeHeader(header string) string {
+	return cases.Fold().String(strings.TrimSpace(norm.NFKC.String(header)))
 }
 
 func ParseYAML(data []byte) (*Schema, error) {
@@ -75,6 +214,7 @@ func (s *Schema) Validate() error {
 			return errors.New("at least one field is required")
 		}
 		
+		fieldNames := make(map[string]bool)
 		for _, field := range resource.Fields {
 			if field.Name == "" {
 				return errors.New("field name is required")
@@ -82,8 +222,236 @@ func (s *Schema) Validate() error {
 			if field.Type == "" {
 				return errors.New("field type is required")
 			}
+			if err := validateNumberFormat(field); err != nil {
+				return err
+			}
+			if err := validateArrayOptions(field); err != nil {
+				return err
+			}
+			if field.Formula != "" && !strings.HasPrefix(field.Formula, "=") {
+				return fmt.Errorf("field %s: x-formula must start with '='", field.Name)
+			}
+			if err := field.Style.validate(); err != nil {
+				return fmt.Errorf("field %s: x-style: %w", field.Name, err)
+			}
+			for i, cf := range field.ConditionalFormats {
+				if err := cf.validate(); err != nil {
+					return fmt.Errorf("field %s: x-conditional-formats[%d]: %w", field.Name, i, err)
+				}
+			}
+			if field.Formula != "" && field.Default != nil {
+				return fmt.Errorf("field %s: x-default cannot be combined with x-formula", field.Name)
+			}
+			fieldNames[field.Name] = true
+		}
+
+		// A header cell must identify a single field, whether it shows a name or a label
+		headerKey := func(header string) string { return header }
+		if resource.HeaderMatching == "normalized" {
+			headerKey = NormalizeHeader
+		}
+		headerFields := make(map[string]string)
+		for _, field := range resource.Fields {
+			if other, ok := headerFields[headerKey(field.Name)]; ok && other != field.Name {
+				return fmt.Errorf("field %s: name matches the header of field %s", field.Name, other)
+			}
+			headerFields[headerKey(field.Name)] = field.Name
+		}
+		for _, field := range resource.Fields {
+			if field.Header == "" || headerKey(field.Header) == headerKey(field.Name) {
+				continue
+			}
+			if other, ok := headerFields[headerKey(field.Header)]; ok {
+				return fmt.Errorf("field %s: x-header %q is already used by field %s", field.Name, field.Header, other)
+			}
+			headerFields[headerKey(field.Header)] = field.Name
+		}
+
+		// Columns are matched by ID before their header, so an ID must identify a single field
+		idFields := make(map[string]string)
+		for _, field := range resource.Fields {
+			if other, ok := idFields[field.ColumnID()]; ok {
+				return fmt.Errorf("field %s: x-id %q is already used by field %s", field.Name, field.ColumnID(), other)
+			}
+			idFields[field.ColumnID()] = field.Name
+		}
+
+		if err := resource.HeaderStyle.validate(); err != nil {
+			return fmt.Errorf("resource %s: x-header-style: %w", resource.Name, err)
+		}
+		if resource.HeaderStyle != nil && resource.HeaderStyle.Width != nil {
+			return fmt.Errorf("resource %s: x-header-style: width is set per field", resource.Name)
+		}
+
+		if err := oneOf("x-header-matching", resource.HeaderMatching, "exact", "normalized"); err != nil {
+			return fmt.Errorf("resource %s: %w", resource.Name, err)
+		}
+		if err := oneOf("x-duplicate-headers", resource.DuplicateHeaders, "error", "suffix"); err != nil {
+			return fmt.Errorf("resource %s: %w", resource.Name, err)
+		}
+		if err := oneOf("x-blank-headers", resource.BlankHeaders, "error", "unmanaged"); err != nil {
+			return fmt.Errorf("resource %s: %w", resource.Name, err)
+		}
+		if err := oneOf("x-order", resource.Order, "strict", "append", "ignore"); err != nil {
+			return fmt.Errorf("resource %s: %w", resource.Name, err)
+		}
+		if resource.TabColor != "" && !colorPattern.MatchString(resource.TabColor) {
+			return fmt.Errorf("resource %s: x-tab-color must be a #RRGGBB color, got %q", resource.Name, resource.TabColor)
+		}
+		if err := resource.Banding.validate(); err != nil {
+			return fmt.Errorf("resource %s: x-banding: %w", resource.Name, err)
+		}
+		if resource.FrozenRows != nil && *resource.FrozenRows < 0 {
+			return fmt.Errorf("resource %s: x-frozen-rows must not be negative", resource.Name)
+		}
+		if resource.FrozenColumns != nil && *resource.FrozenColumns < 0 {
+			return fmt.Errorf("resource %s: x-frozen-columns must not be negative", resource.Name)
+		}
+
+		seenKeys := make(map[string]bool)
+		for _, key := range resource.PrimaryKey {
+			if !fieldNames[key] {
+				return fmt.Errorf("primaryKey field %s is not defined in resource %s", key, resource.Name)
+			}
+			if seenKeys[key] {
+				return fmt.Errorf("primaryKey field %s is listed more than once in resource %s", key, resource.Name)
+			}
+			seenKeys[key] = true
+		}
+	}
+
+	return s.validateNamedRanges()
+}
+
+// validateNumberFormat checks the number formatting options of a field
+func validateNumberFormat(field Field) error {
+	if field.DecimalPlaces != nil && *field.DecimalPlaces < 0 {
+		return fmt.Errorf("field %s: x-decimal-places must not be negative", field.Name)
+	}
+
+	isNumeric := field.Type == "number" || field.Type == "integer"
+	if strings.HasPrefix(field.Format, "currency:") {
+		code := strings.TrimPrefix(field.Format, "currency:")
+		if len(code) != 3 || strings.ToUpper(code) != code {
+			return fmt.Errorf("field %s: currency format needs a 3-letter ISO 4217 code, got %q", field.Name, code)
+		}
+	}
+	if !isNumeric && (field.Format == "percent" || field.Format == "scientific" || strings.HasPrefix(field.Format, "currency:")) {
+		return fmt.Errorf("field %s: format %s is only supported on number and integer fields", field.Name, field.Format)
+	}
+	if !isNumeric && (field.DecimalPlaces != nil || field.ThousandsSeparator) {
+		return fmt.Errorf("field %s: x-decimal-places and x-thousands-separator are only supported on number and integer fields", field.Name)
+	}
+
+	return nil
+}
+
+// validateArrayOptions checks the options of array fields
+func validateArrayOptions(field Field) error {
+	if field.Type != "array" {
+		if field.Delimiter != "" || field.ItemType != "" {
+			return fmt.Errorf("field %s: x-delimiter and x-item-type are only supported on array fields", field.Name)
+		}
+		return nil
+	}
+
+	switch field.ItemType {
+	case "array", "object":
+		return fmt.Errorf("field %s: array items must be of a scalar type, got %s", field.Name, field.ItemType)
+	}
+	return nil
+}
+
+// validate checks the colors of a banding, which may be nil
+func (b *Banding) validate() error {
+	if b == nil {
+		return nil
+	}
+	if b.FirstBandColor == "" || b.SecondBandColor == "" {
+		return errors.New("first-band-color and second-band-color are required")
+	}
+	for _, color := range []string{b.HeaderColor, b.FirstBandColor, b.SecondBandColor} {
+		if color != "" && !colorPattern.MatchString(color) {
+			return fmt.Errorf("colors must be #RRGGBB, got %q", color)
+		}
+	}
+	return nil
+}
+
+var (
+	namedRangePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+	cellRefPattern    = regexp.MustCompile(`^(?i:[a-z]{1,3}[0-9]+|r[0-9]*c[0-9]*)$`)
+)
+
+// SpreadsheetID extracts the spreadsheet ID from the Google Sheets URL of a resource's path
+func SpreadsheetID(sheetURL string) (string, error) {
+	parsedURL, err := url.Parse(sheetURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	// Check if it's a Google Sheets URL
+	if !strings.Contains(parsedURL.Host, "docs.google.com") {
+		return "", fmt.Errorf("not a Google Sheets URL")
+	}
+
+	// Extract ID from path: /spreadsheets/d/{ID}/...
+	parts := strings.Split(parsedURL.Path, "/")
+	for i, part := range parts {
+		if part == "d" && i+1 < len(parts) {
+			return parts[i+1], nil
+		}
+	}
+
+	return "", fmt.Errorf("spreadsheet ID not found in URL")
+}
+
+// validateNamedRanges checks that named ranges are valid names and are not declared twice in a spreadsheet.
+// Table ranges named after their resource are taken first, so a declared name that collides with one is rejected.
+func (s *Schema) validateNamedRanges() error {
+	seen := make(map[string]map[string]bool)
+	spreadsheetNames := func(resource Resource) map[string]bool {
+		spreadsheetID, err := SpreadsheetID(resource.Path)
+		if err != nil {
+			spreadsheetID = resource.Path
+		}
+		if seen[spreadsheetID] == nil {
+			seen[spreadsheetID] = make(map[string]bool)
+		}
+		return seen[spreadsheetID]
+	}
+	for _, resource := range s.Resources {
+		if resource.NamedRange == "" {
+			spreadsheetNames(resource)[strings.ToLower(resource.TableRangeName())] = true
+		}
+	}
+
+	for _, resource := range s.Resources {
+		names := spreadsheetNames(resource)
+		check := func(name string) error {
+			if !namedRangePattern.MatchString(name) || cellRefPattern.MatchString(name) {
+				return fmt.Errorf("invalid named range %q: use letters, digits, underscores and periods, and do not use a cell reference", name)
+			}
+			if names[strings.ToLower(name)] {
+				return fmt.Errorf("named range %q is declared more than once", name)
+			}
+			names[strings.ToLower(name)] = true
+			return nil
+		}
+
+		if resource.NamedRange != "" {
+			if err := check(resource.NamedRange); err != nil {
+				return fmt.Errorf("resource %s: %w", resource.Name, err)
+			}
+		}
+		for _, field := range resource.Fields {
+			if field.NamedRange == "" {
+				continue
+			}
+			if err := check(field.NamedRange); err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
 		}
 	}
-	
 	return nil
-}
\ No newline at end of file
+}
@@ -186,6 +186,395 @@ func TestSchemaValidation(t *testing.T) {
 			wantErr: true,
 			errMsg:  "field type is required",
 		},
+		{
+			name: "primary key references unknown field",
+			yaml: `resources:
+  - name: users
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    primaryKey: user_id
+    fields:
+      - name: id
+        type: integer`,
+			wantErr: true,
+			errMsg:  "primaryKey field user_id is not defined in resource users",
+		},
+		{
+			name: "composite primary key",
+			yaml: `resources:
+  - name: users
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    primaryKey: [tenant, id]
+    fields:
+      - name: tenant
+        type: string
+      - name: id
+        type: integer`,
+			wantErr: false,
+		},
+		{
+			name: "currency format on string field",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: price
+        type: string
+        format: currency:JPY`,
+			wantErr: true,
+			errMsg:  "field price: format currency:JPY is only supported on number and integer fields",
+		},
+		{
+			name: "invalid currency code",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: price
+        type: number
+        format: currency:yen`,
+			wantErr: true,
+			errMsg:  `field price: currency format needs a 3-letter ISO 4217 code, got "yen"`,
+		},
+		{
+			name: "number format options",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: price
+        type: number
+        format: currency:USD
+        x-decimal-places: 0
+        x-thousands-separator: true`,
+			wantErr: false,
+		},
+		{
+			name: "array of arrays",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: tags
+        type: array
+        x-item-type: array`,
+			wantErr: true,
+			errMsg:  "field tags: array items must be of a scalar type, got array",
+		},
+		{
+			name: "formula without equals sign",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: total
+        type: number
+        x-formula: "B{row}*C{row}"`,
+			wantErr: true,
+			errMsg:  "field total: x-formula must start with '='",
+		},
+		{
+			name: "default with formula",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: total
+        type: number
+        x-formula: "=B{row}*C{row}"
+        x-default: 0`,
+			wantErr: true,
+			errMsg:  "field total: x-default cannot be combined with x-formula",
+		},
+		{
+			name: "invalid style color",
+			yaml: `resources:
+  - name: users
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: name
+        type: string
+        x-style:
+          background-color: yellow`,
+			wantErr: true,
+			errMsg:  `field name: x-style: background-color must be a #RRGGBB color, got "yellow"`,
+		},
+		{
+			name: "width in header style",
+			yaml: `resources:
+  - name: users
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    x-header-style:
+      width: 120
+    fields:
+      - name: name
+        type: string`,
+			wantErr: true,
+			errMsg:  "resource users: x-header-style: width is set per field",
+		},
+		{
+			name: "negative frozen columns",
+			yaml: `resources:
+  - name: users
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    x-frozen-columns: -1
+    fields:
+      - name: name
+        type: string`,
+			wantErr: true,
+			errMsg:  "resource users: x-frozen-columns must not be negative",
+		},
+		{
+			name: "invalid tab color",
+			yaml: `resources:
+  - name: users
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    x-tab-color: blue
+    fields:
+      - name: name
+        type: string`,
+			wantErr: true,
+			errMsg:  `resource users: x-tab-color must be a #RRGGBB color, got "blue"`,
+		},
+		{
+			name: "color scale without max color",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: total
+        type: number
+        x-conditional-formats:
+          - type: color-scale
+            min-color: "#ffffff"`,
+			wantErr: true,
+			errMsg:  "field total: x-conditional-formats[0]: color-scale needs min-color and max-color",
+		},
+		{
+			name: "text contains without format",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: status
+        type: string
+        x-conditional-formats:
+          - type: text-contains
+            text: late`,
+			wantErr: true,
+			errMsg:  "field status: x-conditional-formats[0]: text-contains needs background-color, font-color or bold",
+		},
+		{
+			name: "banding without second band color",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    x-banding:
+      first-band-color: "#ffffff"
+    fields:
+      - name: id
+        type: integer`,
+			wantErr: true,
+			errMsg:  "resource orders: x-banding: first-band-color and second-band-color are required",
+		},
+		{
+			name: "named range that looks like a cell reference",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: id
+        type: integer
+        x-named-range: AB12`,
+			wantErr: true,
+			errMsg:  `field id: invalid named range "AB12": use letters, digits, underscores and periods, and do not use a cell reference`,
+		},
+		{
+			name: "named range declared twice",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: id
+        type: integer
+        x-named-range: order_ids
+      - name: total
+        type: number
+        x-named-range: ORDER_IDS`,
+			wantErr: true,
+			errMsg:  `field total: named range "ORDER_IDS" is declared more than once`,
+		},
+		{
+			name: "field named range clashes with table range",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: id
+        type: integer
+        x-named-range: orders_table`,
+			wantErr: true,
+			errMsg:  `field id: named range "orders_table" is declared more than once`,
+		},
+		{
+			name: "same table range in different spreadsheets",
+			yaml: `resources:
+  - name: Orders
+    path: https://docs.google.com/spreadsheets/d/first-id
+    fields:
+      - name: id
+        type: integer
+  - name: Orders
+    path: https://docs.google.com/spreadsheets/d/second-id/edit#gid=0
+    fields:
+      - name: id
+        type: integer
+        x-named-range: order_ids
+  - name: Customers
+    path: https://docs.google.com/spreadsheets/d/first-id/edit
+    fields:
+      - name: id
+        type: integer
+        x-named-range: order_ids`,
+			wantErr: false,
+		},
+		{
+			name: "non-ASCII resource names",
+			yaml: `resources:
+  - name: 注文
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: id
+        type: integer
+  - name: 顧客
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: id
+        type: integer`,
+			wantErr: false,
+		},
+		{
+			name: "declared table range clashes with another sheet of the spreadsheet",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: id
+        type: integer
+  - name: customers
+    path: https://docs.google.com/spreadsheets/d/valid-id/edit
+    x-named-range: Orders_Table
+    fields:
+      - name: id
+        type: integer`,
+			wantErr: true,
+			errMsg:  `resource customers: named range "Orders_Table" is declared more than once`,
+		},
+		{
+			name: "header label used by another field",
+			yaml: `resources:
+  - name: customers
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: name
+        type: string
+      - name: customer_name
+        type: string
+        x-header: name`,
+			wantErr: true,
+			errMsg:  `field customer_name: x-header "name" is already used by field name`,
+		},
+		{
+			name: "header labels",
+			yaml: `resources:
+  - name: customers
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: id
+        type: integer
+        x-header: id
+      - name: customer_name
+        type: string
+        x-header: Customer Name`,
+			wantErr: false,
+		},
+		{
+			name: "labels that only differ by case with normalized matching",
+			yaml: `resources:
+  - name: customers
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    x-header-matching: normalized
+    fields:
+      - name: region
+        type: string
+        x-header: Area
+      - name: area
+        type: string`,
+			wantErr: true,
+			errMsg:  `field region: x-header "Area" is already used by field area`,
+		},
+		{
+			name: "duplicate x-id",
+			yaml: `resources:
+  - name: customers
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: email
+        type: string
+        x-id: contact
+      - name: phone
+        type: string
+        x-id: contact`,
+			wantErr: true,
+			errMsg:  `field phone: x-id "contact" is already used by field email`,
+		},
+		{
+			name: "unknown header matching",
+			yaml: `resources:
+  - name: customers
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    x-header-matching: fuzzy
+    fields:
+      - name: id
+        type: integer`,
+			wantErr: true,
+			errMsg:  `resource customers: x-header-matching must be one of [exact normalized], got "fuzzy"`,
+		},
+		{
+			name: "unknown order mode",
+			yaml: `resources:
+  - name: customers
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    x-order: sorted
+    fields:
+      - name: id
+        type: integer`,
+			wantErr: true,
+			errMsg:  `resource customers: x-order must be one of [strict append ignore], got "sorted"`,
+		},
+		{
+			name: "unknown blank header handling",
+			yaml: `resources:
+  - name: customers
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    x-blank-headers: ignore
+    fields:
+      - name: id
+        type: integer`,
+			wantErr: true,
+			errMsg:  `resource customers: x-blank-headers must be one of [error unmanaged], got "ignore"`,
+		},
+		{
+			name: "delimiter on string field",
+			yaml: `resources:
+  - name: orders
+    path: https://docs.google.com/spreadsheets/d/valid-id
+    fields:
+      - name: tags
+        type: string
+        x-delimiter: ";"`,
+			wantErr: true,
+			errMsg:  "field tags: x-delimiter and x-item-type are only supported on array fields",
+		},
 	}
 
 	for _, tt := range tests {
@@ -210,3 +599,105 @@ func TestSchemaValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeHeader(t *testing.T) {
+	tests := map[string]string{
+		"Name":     "name",
+		"  Name\t": "name",
+		"ＮＡＭＥ":     "name",
+		"ｶﾅ":       "カナ",
+		"Straße":   "strasse",
+		"Order ID": "order id",
+		"注文ID":     "注文id",
+	}
+	for header, want := range tests {
+		if got := NormalizeHeader(header); got != want {
+			t.Errorf("NormalizeHeader(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestColumnID(t *testing.T) {
+	named := Field{Name: "email"}
+	if got := named.ColumnID(); len(got) != 16 || got != (Field{Name: "email", Header: "E-mail"}).ColumnID() {
+		t.Errorf("ColumnID() = %q, want a 16 character hash that only depends on the name", got)
+	}
+	if named.ColumnID() == (Field{Name: "mail"}).ColumnID() {
+		t.Error("ColumnID() should differ between names")
+	}
+	if got := (Field{Name: "email", ID: "contact"}).ColumnID(); got != "contact" {
+		t.Errorf("ColumnID() = %q, want the explicit x-id", got)
+	}
+}
+
+func TestTableRangeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource Resource
+		want     string
+	}{
+		{name: "derived from the name", resource: Resource{Name: "orders"}, want: "orders_table"},
+		{name: "spaces and symbols replaced", resource: Resource{Name: "Q1 sales-2024"}, want: "Q1_sales_2024_07cd0f62_table"},
+		{name: "leading digit", resource: Resource{Name: "2024"}, want: "_2024_6557739a_table"},
+		{name: "non-ASCII name", resource: Resource{Name: "注文"}, want: "___266125dd_table"},
+		{name: "explicit name", resource: Resource{Name: "orders", NamedRange: "all_orders"}, want: "all_orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resource.TableRangeName(); got != tt.want {
+				t.Errorf("TableRangeName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePrimaryKey(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{
+			name: "single field",
+			yaml: `resources:
+  - name: users
+    primaryKey: id`,
+			want: []string{"id"},
+		},
+		{
+			name: "list of fields",
+			yaml: `resources:
+  - name: users
+    primaryKey:
+      - tenant
+      - id`,
+			want: []string{"tenant", "id"},
+		},
+		{
+			name: "not specified",
+			yaml: `resources:
+  - name: users`,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := ParseYAML([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("Failed to parse YAML: %v", err)
+			}
+
+			got := schema.Resources[0].PrimaryKey
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected primary key %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Expected primary key %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
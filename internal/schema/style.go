@@ -0,0 +1,89 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Style describes the appearance of cells. Properties that are not set are left as they are in the sheet.
+type Style struct {
+	Width               *Width `yaml:"width"`                // Column width in pixels or "auto"
+	HorizontalAlignment string `yaml:"horizontal-alignment"` // left, center or right
+	VerticalAlignment   string `yaml:"vertical-alignment"`   // top, middle or bottom
+	Wrap                string `yaml:"wrap"`                 // overflow, clip or wrap
+	Bold                *bool  `yaml:"bold"`
+	FontColor           string `yaml:"font-color"`       // #RRGGBB
+	BackgroundColor     string `yaml:"background-color"` // #RRGGBB
+}
+
+// Width is a column width, either a number of pixels or automatic sizing to fit the content
+type Width struct {
+	Pixels int
+	Auto   bool
+}
+
+// UnmarshalYAML accepts both `width: 120` and `width: auto`
+func (w *Width) UnmarshalYAML(unmarshal func(any) error) error {
+	var pixels int
+	if err := unmarshal(&pixels); err == nil {
+		*w = Width{Pixels: pixels}
+		return nil
+	}
+
+	var text string
+	if err := unmarshal(&text); err != nil || text != "auto" {
+		return fmt.Errorf("width must be a number of pixels or auto")
+	}
+	*w = Width{Auto: true}
+	return nil
+}
+
+func (w Width) String() string {
+	if w.Auto {
+		return "auto"
+	}
+	return strconv.Itoa(w.Pixels) + "px"
+}
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// validate checks the values of a style block, which may be nil
+func (s *Style) validate() error {
+	if s == nil {
+		return nil
+	}
+
+	if s.Width != nil && !s.Width.Auto && s.Width.Pixels <= 0 {
+		return fmt.Errorf("width must be positive, got %d", s.Width.Pixels)
+	}
+	if err := oneOf("horizontal-alignment", s.HorizontalAlignment, "left", "center", "right"); err != nil {
+		return err
+	}
+	if err := oneOf("vertical-alignment", s.VerticalAlignment, "top", "middle", "bottom"); err != nil {
+		return err
+	}
+	if err := oneOf("wrap", s.Wrap, "overflow", "clip", "wrap"); err != nil {
+		return err
+	}
+	if s.FontColor != "" && !colorPattern.MatchString(s.FontColor) {
+		return fmt.Errorf("font-color must be a #RRGGBB color, got %q", s.FontColor)
+	}
+	if s.BackgroundColor != "" && !colorPattern.MatchString(s.BackgroundColor) {
+		return fmt.Errorf("background-color must be a #RRGGBB color, got %q", s.BackgroundColor)
+	}
+	return nil
+}
+
+// oneOf checks that an optional value is one of the allowed values
+func oneOf(name, value string, allowed ...string) error {
+	if value == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of %v, got %q", name, allowed, value)
+}
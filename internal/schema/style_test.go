@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"testing"
+)
+
+func TestParseStyle(t *testing.T) {
+	yamlContent := `resources:
+  - name: users
+    path: https://docs.google.com/spreadsheets/d/test-id
+    x-header-style:
+      bold: true
+      background-color: "#d9ead3"
+    fields:
+      - name: id
+        type: integer
+        x-style:
+          width: 80
+          horizontal-alignment: right
+      - name: bio
+        type: string
+        x-style:
+          width: auto
+          wrap: wrap
+          bold: false`
+
+	schema, err := ParseYAML([]byte(yamlContent))
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	if err := schema.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+
+	resource := schema.Resources[0]
+	if resource.HeaderStyle == nil || resource.HeaderStyle.Bold == nil || !*resource.HeaderStyle.Bold {
+		t.Errorf("Expected bold header style, got %+v", resource.HeaderStyle)
+	}
+
+	id := resource.Fields[0].Style
+	if id == nil || id.Width == nil || id.Width.Pixels != 80 || id.HorizontalAlignment != "right" {
+		t.Errorf("Unexpected style for id: %+v", id)
+	}
+
+	bio := resource.Fields[1].Style
+	if bio == nil || bio.Width == nil || !bio.Width.Auto || bio.Bold == nil || *bio.Bold {
+		t.Errorf("Unexpected style for bio: %+v", bio)
+	}
+}
+
+func TestParseInvalidWidth(t *testing.T) {
+	yamlContent := `resources:
+  - name: users
+    path: https://docs.google.com/spreadsheets/d/test-id
+    fields:
+      - name: id
+        type: integer
+        x-style:
+          width: wide`
+
+	if _, err := ParseYAML([]byte(yamlContent)); err == nil {
+		t.Error("Expected error for invalid width")
+	}
+}
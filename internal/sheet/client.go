@@ -3,9 +3,8 @@ package sheet
 import (
 	"context"
 	"fmt"
-	"net/url"
-	"strings"
 
+	"github.com/ucpr/ss-migrate/internal/schema"
 	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
 )
@@ -28,25 +27,7 @@ func NewClient(ctx context.Context) (*Client, error) {
 
 // ExtractSpreadsheetID extracts the spreadsheet ID from a Google Sheets URL
 func ExtractSpreadsheetID(sheetURL string) (string, error) {
-	parsedURL, err := url.Parse(sheetURL)
-	if err != nil {
-		return "", fmt.Errorf("invalid URL: %w", err)
-	}
-
-	// Check if it's a Google Sheets URL
-	if !strings.Contains(parsedURL.Host, "docs.google.com") {
-		return "", fmt.Errorf("not a Google Sheets URL")
-	}
-
-	// Extract ID from path: /spreadsheets/d/{ID}/...
-	parts := strings.Split(parsedURL.Path, "/")
-	for i, part := range parts {
-		if part == "d" && i+1 < len(parts) {
-			return parts[i+1], nil
-		}
-	}
-
-	return "", fmt.Errorf("spreadsheet ID not found in URL")
+	return schema.SpreadsheetID(sheetURL)
 }
 
 // GetSpreadsheet retrieves spreadsheet metadata
@@ -67,6 +48,33 @@ func (c *Client) GetValues(ctx context.Context, spreadsheetID, readRange string)
 	return resp.Values, nil
 }
 
+// GetUnformattedValues retrieves values from a specific range as stored in the sheet,
+// with numbers, booleans and dates (as serial numbers) not converted to formatted text
+func (c *Client) GetUnformattedValues(ctx context.Context, spreadsheetID, readRange string) ([][]any, error) {
+	resp, err := c.Service.Spreadsheets.Values.Get(spreadsheetID, readRange).
+		ValueRenderOption("UNFORMATTED_VALUE").
+		DateTimeRenderOption("SERIAL_NUMBER").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get values: %w", err)
+	}
+	return resp.Values, nil
+}
+
+// GetFormulas retrieves values from a specific range with formulas returned as written
+// rather than as their calculated results
+func (c *Client) GetFormulas(ctx context.Context, spreadsheetID, readRange string) ([][]any, error) {
+	resp, err := c.Service.Spreadsheets.Values.Get(spreadsheetID, readRange).
+		ValueRenderOption("FORMULA").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get formulas: %w", err)
+	}
+	return resp.Values, nil
+}
+
 // UpdateValues updates values in a specific range
 func (c *Client) UpdateValues(ctx context.Context, spreadsheetID, writeRange string, values [][]any) error {
 	valueRange := &sheets.ValueRange{
@@ -231,6 +239,20 @@ func (c *Client) MoveColumn(ctx context.Context, spreadsheetID, sheetName string
 		return fmt.Errorf("sheet %s not found", sheetName)
 	}
 
+	batchUpdateReq := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{MoveColumnRequest(sheetID, sourceIndex, destinationIndex)},
+	}
+
+	_, err = c.Service.Spreadsheets.BatchUpdate(spreadsheetID, batchUpdateReq).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to move column: %w", err)
+	}
+
+	return nil
+}
+
+// MoveColumnRequest builds the request that moves a column so that it ends up at destinationIndex
+func MoveColumnRequest(sheetID int64, sourceIndex, destinationIndex int) *sheets.Request {
 	// Adjust destination index based on Google Sheets API behavior:
 	// When moving a column to the right (sourceIndex < destinationIndex),
 	// the API expects the destination index to be one more than the visual position
@@ -240,8 +262,7 @@ func (c *Client) MoveColumn(ctx context.Context, spreadsheetID, sheetName string
 		adjustedDestination = destinationIndex + 1
 	}
 
-	// Create move dimension request
-	req := &sheets.Request{
+	return &sheets.Request{
 		MoveDimension: &sheets.MoveDimensionRequest{
 			Source: &sheets.DimensionRange{
 				SheetId:    sheetID,
@@ -252,17 +273,6 @@ func (c *Client) MoveColumn(ctx context.Context, spreadsheetID, sheetName string
 			DestinationIndex: int64(adjustedDestination),
 		},
 	}
-
-	batchUpdateReq := &sheets.BatchUpdateSpreadsheetRequest{
-		Requests: []*sheets.Request{req},
-	}
-
-	_, err = c.Service.Spreadsheets.BatchUpdate(spreadsheetID, batchUpdateReq).Context(ctx).Do()
-	if err != nil {
-		return fmt.Errorf("failed to move column: %w", err)
-	}
-
-	return nil
 }
 
 // HideColumn hides a column at the specified index
@@ -408,14 +418,24 @@ func (c *Client) InsertColumn(ctx context.Context, spreadsheetID, sheetName stri
 
 // GetColumnFormat retrieves the number format pattern of a column
 func (c *Client) GetColumnFormat(ctx context.Context, spreadsheetID, sheetName string, columnIndex int) (string, error) {
+	numberFormat, err := c.GetColumnNumberFormat(ctx, spreadsheetID, sheetName, columnIndex, 2)
+	if err != nil || numberFormat == nil {
+		return "", err
+	}
+	return numberFormat.Pattern, nil
+}
+
+// GetColumnNumberFormat retrieves the number format of a column, as found in the given (1-based) row.
+// It returns nil when the cell has no number format.
+func (c *Client) GetColumnNumberFormat(ctx context.Context, spreadsheetID, sheetName string, columnIndex, row int) (*sheets.NumberFormat, error) {
 	// Get spreadsheet with cell format data
 	spreadsheet, err := c.Service.Spreadsheets.Get(spreadsheetID).
-		Ranges(fmt.Sprintf("%s!%s2:%s2", sheetName, ColumnToLetter(columnIndex), ColumnToLetter(columnIndex))).
+		Ranges(fmt.Sprintf("%s!%s%d:%s%d", sheetName, ColumnToLetter(columnIndex), row, ColumnToLetter(columnIndex), row)).
 		IncludeGridData(true).
 		Context(ctx).
 		Do()
 	if err != nil {
-		return "", fmt.Errorf("failed to get spreadsheet: %w", err)
+		return nil, fmt.Errorf("failed to get spreadsheet: %w", err)
 	}
 
 	// Find the sheet
@@ -425,21 +445,33 @@ func (c *Client) GetColumnFormat(ctx context.Context, spreadsheetID, sheetName s
 			if len(sheet.Data) > 0 && len(sheet.Data[0].RowData) > 0 && len(sheet.Data[0].RowData[0].Values) > 0 {
 				cellData := sheet.Data[0].RowData[0].Values[0]
 				if cellData.UserEnteredFormat != nil && cellData.UserEnteredFormat.NumberFormat != nil {
-					return cellData.UserEnteredFormat.NumberFormat.Pattern, nil
+					return cellData.UserEnteredFormat.NumberFormat, nil
 				}
 				if cellData.EffectiveFormat != nil && cellData.EffectiveFormat.NumberFormat != nil {
-					return cellData.EffectiveFormat.NumberFormat.Pattern, nil
+					return cellData.EffectiveFormat.NumberFormat, nil
 				}
 			}
 			break
 		}
 	}
 
-	return "", nil // No format found
+	return nil, nil // No format found
 }
 
 // FormatColumn applies number formatting to a column based on the data type
 func (c *Client) FormatColumn(ctx context.Context, spreadsheetID, sheetName string, columnIndex int, dataType, format string) error {
+	// Determine the number format based on type
+	numberFormat := NumberFormatFor(dataType, format)
+	if numberFormat == nil {
+		// Boolean and unknown types don't need number formatting
+		return nil
+	}
+
+	return c.SetColumnNumberFormat(ctx, spreadsheetID, sheetName, columnIndex, numberFormat)
+}
+
+// SetColumnNumberFormat applies the given number format to a column (excluding the header)
+func (c *Client) SetColumnNumberFormat(ctx context.Context, spreadsheetID, sheetName string, columnIndex int, numberFormat *sheets.NumberFormat) error {
 	// Get sheet ID
 	spreadsheet, err := c.GetSpreadsheet(ctx, spreadsheetID)
 	if err != nil {
@@ -462,34 +494,6 @@ func (c *Client) FormatColumn(ctx context.Context, spreadsheetID, sheetName stri
 		return fmt.Errorf("sheet %s not found", sheetName)
 	}
 
-	// Determine the number format pattern based on type
-	var pattern string
-	switch dataType {
-	case "integer":
-		pattern = "0" // No decimal places
-	case "number":
-		pattern = "0.00" // Two decimal places
-	case "datetime":
-		if format == "default" || format == "" {
-			pattern = "yyyy-mm-dd hh:mm:ss"
-		} else if format == "date" {
-			pattern = "yyyy-mm-dd"
-		} else if format == "time" {
-			pattern = "hh:mm:ss"
-		} else {
-			pattern = "yyyy-mm-dd hh:mm:ss"
-		}
-	case "boolean":
-		// Boolean doesn't need number formatting, skip
-		return nil
-	case "string":
-		// String doesn't need number formatting, but we'll clear any existing format
-		pattern = "@" // Text format
-	default:
-		// No specific formatting needed
-		return nil
-	}
-
 	// Create format request for the entire column (excluding header)
 	req := &sheets.Request{
 		RepeatCell: &sheets.RepeatCellRequest{
@@ -502,10 +506,7 @@ func (c *Client) FormatColumn(ctx context.Context, spreadsheetID, sheetName stri
 			},
 			Cell: &sheets.CellData{
 				UserEnteredFormat: &sheets.CellFormat{
-					NumberFormat: &sheets.NumberFormat{
-						Type:    "NUMBER",
-						Pattern: pattern,
-					},
+					NumberFormat: numberFormat,
 				},
 			},
 			Fields: "userEnteredFormat.numberFormat",
@@ -523,3 +524,163 @@ func (c *Client) FormatColumn(ctx context.Context, spreadsheetID, sheetName stri
 
 	return nil
 }
+
+// GetSheetID returns the ID of the sheet with the given name
+func (c *Client) GetSheetID(ctx context.Context, spreadsheetID, sheetName string) (int64, error) {
+	sheet, err := c.GetSheet(ctx, spreadsheetID, sheetName)
+	if err != nil {
+		return -1, err
+	}
+	return sheet.Properties.SheetId, nil
+}
+
+// GetSheet retrieves the metadata of a single sheet, such as its properties and conditional formats
+func (c *Client) GetSheet(ctx context.Context, spreadsheetID, sheetName string) (*sheets.Sheet, error) {
+	spreadsheet, err := c.GetSpreadsheet(ctx, spreadsheetID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties.Title == sheetName {
+			return sheet, nil
+		}
+	}
+
+	return nil, fmt.Errorf("sheet %s not found", sheetName)
+}
+
+// BatchUpdate sends the given requests to the spreadsheet in a single batch update
+func (c *Client) BatchUpdate(ctx context.Context, spreadsheetID string, requests []*sheets.Request) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	batchUpdateReq := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: requests,
+	}
+
+	_, err := c.Service.Spreadsheets.BatchUpdate(spreadsheetID, batchUpdateReq).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to batch update spreadsheet: %w", err)
+	}
+
+	return nil
+}
+
+// DuplicateSheet copies a sheet, with its contents and formatting, to a new tab at insertIndex
+// and returns the properties of the copy
+func (c *Client) DuplicateSheet(ctx context.Context, spreadsheetID string, sheetID int64, insertIndex int, name string) (*sheets.SheetProperties, error) {
+	batchUpdateReq := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			DuplicateSheet: &sheets.DuplicateSheetRequest{
+				SourceSheetId:    sheetID,
+				InsertSheetIndex: int64(insertIndex),
+				NewSheetName:     name,
+			},
+		}},
+	}
+
+	resp, err := c.Service.Spreadsheets.BatchUpdate(spreadsheetID, batchUpdateReq).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to duplicate sheet: %w", err)
+	}
+	if len(resp.Replies) == 0 || resp.Replies[0].DuplicateSheet == nil {
+		return nil, fmt.Errorf("failed to duplicate sheet: no reply")
+	}
+	return resp.Replies[0].DuplicateSheet.Properties, nil
+}
+
+// GetColumnProperties retrieves the size, visibility and developer metadata of each column of a sheet, looked up by its ID
+func (c *Client) GetColumnProperties(ctx context.Context, spreadsheetID string, sheetID int64) ([]*sheets.DimensionProperties, error) {
+	req := &sheets.GetSpreadsheetByDataFilterRequest{
+		DataFilters: []*sheets.DataFilter{{
+			GridRange: &sheets.GridRange{SheetId: sheetID, StartRowIndex: 0, EndRowIndex: 1},
+		}},
+		IncludeGridData: true,
+	}
+	spreadsheet, err := c.Service.Spreadsheets.GetByDataFilter(spreadsheetID, req).
+		Fields("sheets(properties(sheetId),data(columnMetadata(pixelSize,hiddenByUser,developerMetadata)))").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column properties: %w", err)
+	}
+
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties.SheetId == sheetID && len(sheet.Data) > 0 {
+			return sheet.Data[0].ColumnMetadata, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetGridData retrieves the cells of a range in a sheet, with their values, formats, notes and validation,
+// and the properties and metadata of its columns. It returns nil when the range holds no grid data.
+func (c *Client) GetGridData(ctx context.Context, spreadsheetID, sheetName, readRange string) (*sheets.GridData, error) {
+	spreadsheet, err := c.Service.Spreadsheets.Get(spreadsheetID).
+		Ranges(fmt.Sprintf("%s!%s", sheetName, readRange)).
+		IncludeGridData(true).
+		Fields("sheets(properties(title),data(rowData(values(userEnteredValue,userEnteredFormat,note,dataValidation)),columnMetadata(pixelSize,hiddenByUser,developerMetadata)))").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get grid data: %w", err)
+	}
+
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties.Title == sheetName && len(sheet.Data) > 0 {
+			return sheet.Data[0], nil
+		}
+	}
+	return nil, nil
+}
+
+// SearchSpreadsheetMetadata retrieves the developer metadata with the given key attached to the spreadsheet itself
+func (c *Client) SearchSpreadsheetMetadata(ctx context.Context, spreadsheetID, key string) ([]*sheets.DeveloperMetadata, error) {
+	req := &sheets.SearchDeveloperMetadataRequest{
+		DataFilters: []*sheets.DataFilter{{
+			DeveloperMetadataLookup: &sheets.DeveloperMetadataLookup{
+				MetadataKey:  key,
+				LocationType: "SPREADSHEET",
+			},
+		}},
+	}
+	resp, err := c.Service.Spreadsheets.DeveloperMetadata.Search(spreadsheetID, req).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search developer metadata: %w", err)
+	}
+
+	metadata := make([]*sheets.DeveloperMetadata, 0, len(resp.MatchedDeveloperMetadata))
+	for _, matched := range resp.MatchedDeveloperMetadata {
+		if matched.DeveloperMetadata != nil {
+			metadata = append(metadata, matched.DeveloperMetadata)
+		}
+	}
+	return metadata, nil
+}
+
+// CreateSpreadsheetMetadata attaches developer metadata to the spreadsheet itself and returns it with its assigned ID
+func (c *Client) CreateSpreadsheetMetadata(ctx context.Context, spreadsheetID, key, value string) (*sheets.DeveloperMetadata, error) {
+	batchUpdateReq := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			CreateDeveloperMetadata: &sheets.CreateDeveloperMetadataRequest{
+				DeveloperMetadata: &sheets.DeveloperMetadata{
+					MetadataKey:   key,
+					MetadataValue: value,
+					Location:      &sheets.DeveloperMetadataLocation{Spreadsheet: true},
+					Visibility:    "DOCUMENT",
+				},
+			},
+		}},
+	}
+
+	resp, err := c.Service.Spreadsheets.BatchUpdate(spreadsheetID, batchUpdateReq).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create developer metadata: %w", err)
+	}
+	if len(resp.Replies) == 0 || resp.Replies[0].CreateDeveloperMetadata == nil {
+		return nil, fmt.Errorf("failed to create developer metadata: no reply")
+	}
+	return resp.Replies[0].CreateDeveloperMetadata.DeveloperMetadata, nil
+}
@@ -0,0 +1,315 @@
+package sheet
+
+import (
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// defaultPatterns maps each field type to the number format pattern applied by default
+var defaultPatterns = map[string]string{
+	"integer":   "0",
+	"number":    "0.00",
+	"datetime":  "yyyy-mm-dd hh:mm:ss",
+	"date":      "yyyy-mm-dd",
+	"time":      "hh:mm:ss",
+	"year":      "0000",
+	"yearmonth": "yyyy-mm",
+	"duration":  "[h]:mm:ss",
+	"string":    "@",
+	"array":     "@",
+	"object":    "@",
+}
+
+// numberFormatTypes maps each field type to the Sheets NumberFormat.Type
+var numberFormatTypes = map[string]string{
+	"integer":   "NUMBER",
+	"number":    "NUMBER",
+	"datetime":  "DATE_TIME",
+	"date":      "DATE",
+	"time":      "TIME",
+	"year":      "NUMBER",
+	"yearmonth": "DATE",
+	"duration":  "TIME",
+	"string":    "TEXT",
+	"array":     "TEXT",
+	"object":    "TEXT",
+}
+
+// IsTemporalType reports whether the field type holds dates, times or durations
+func IsTemporalType(dataType string) bool {
+	switch dataType {
+	case "datetime", "date", "time", "yearmonth", "duration":
+		return true
+	}
+	return false
+}
+
+// NormalizeType maps legacy and equivalent type/format combinations to a canonical form.
+// `datetime` with format `date` or `time` becomes the `date` or `time` type, the Frictionless
+// formats `default` and `any` become empty, and strftime patterns of temporal types are
+// rewritten so that patterns producing the same sheet format compare equal.
+func NormalizeType(dataType, format string) (string, string) {
+	if dataType == "datetime" {
+		switch format {
+		case "date":
+			return "date", ""
+		case "time":
+			return "time", ""
+		}
+	}
+
+	if format == "default" || format == "any" {
+		return dataType, ""
+	}
+
+	if IsTemporalType(dataType) && strings.Contains(format, "%") {
+		pattern := StrftimeToPattern(format)
+		if pattern == defaultPatterns[dataType] {
+			return dataType, ""
+		}
+		return dataType, PatternToStrftime(pattern)
+	}
+
+	return dataType, format
+}
+
+// FormatOptions holds the number formatting options of a field
+type FormatOptions struct {
+	Pattern            string // Raw pattern that replaces the generated one
+	DecimalPlaces      *int
+	ThousandsSeparator bool
+}
+
+// currencySymbols maps ISO 4217 codes to the symbol used in currency patterns
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"JPY": "¥",
+	"EUR": "€",
+	"GBP": "£",
+	"CNY": "¥",
+	"KRW": "₩",
+	"INR": "₹",
+}
+
+// currencyDecimals lists currencies whose minor unit is not two digits
+var currencyDecimals = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+}
+
+// NumberFormatFor returns the number format to apply for a field type, or nil if the type needs none
+func NumberFormatFor(dataType, format string) *sheets.NumberFormat {
+	return NumberFormatWithOptions(dataType, format, FormatOptions{})
+}
+
+// NumberFormatWithOptions returns the number format for a field type with formatting options applied.
+// Number and integer fields accept the formats `percent`, `scientific` and `currency:<code>`.
+func NumberFormatWithOptions(dataType, format string, opts FormatOptions) *sheets.NumberFormat {
+	dataType, format = NormalizeType(dataType, format)
+
+	pattern, ok := defaultPatterns[dataType]
+	if !ok {
+		return nil
+	}
+	formatType := numberFormatTypes[dataType]
+
+	switch {
+	case IsTemporalType(dataType) && format != "":
+		pattern = StrftimeToPattern(format)
+	case dataType == "number" || dataType == "integer":
+		formatType, pattern = numericFormat(dataType, format, opts)
+	}
+
+	if opts.Pattern != "" {
+		pattern = opts.Pattern
+	}
+
+	return &sheets.NumberFormat{
+		Type:    formatType,
+		Pattern: pattern,
+	}
+}
+
+// numericFormat builds the format type and pattern for number and integer fields
+func numericFormat(dataType, format string, opts FormatOptions) (string, string) {
+	decimals := 2
+	if dataType == "integer" {
+		decimals = 0
+	}
+
+	formatType := "NUMBER"
+	prefix, suffix := "", ""
+	thousands := opts.ThousandsSeparator
+	switch {
+	case format == "percent":
+		formatType = "PERCENT"
+		suffix = "%"
+	case format == "scientific":
+		formatType = "SCIENTIFIC"
+		suffix = "E+00"
+	case strings.HasPrefix(format, "currency:"):
+		code := strings.TrimPrefix(format, "currency:")
+		formatType = "CURRENCY"
+		thousands = true
+		if symbol, ok := currencySymbols[code]; ok {
+			prefix = `"` + symbol + `"`
+		} else {
+			prefix = `"` + code + ` "`
+		}
+		if d, ok := currencyDecimals[code]; ok && dataType == "number" {
+			decimals = d
+		}
+	}
+
+	if opts.DecimalPlaces != nil && dataType == "number" {
+		decimals = *opts.DecimalPlaces
+	}
+
+	integerPart := "0"
+	if thousands {
+		integerPart = "#,##0"
+	}
+	decimalPart := ""
+	if decimals > 0 {
+		decimalPart = "." + strings.Repeat("0", decimals)
+	}
+
+	return formatType, prefix + integerPart + decimalPart + suffix
+}
+
+// FormatFromPattern returns the schema format corresponding to a column's number format pattern.
+// It is empty when the pattern is the default for the type or the type has no pattern-based format.
+func FormatFromPattern(dataType, pattern string) string {
+	if !IsTemporalType(dataType) || pattern == "" || pattern == defaultPatterns[dataType] {
+		return ""
+	}
+	return PatternToStrftime(pattern)
+}
+
+// strftimeTokens maps strftime directives to Sheets date/time pattern tokens
+var strftimeTokens = map[byte]string{
+	'Y': "yyyy",
+	'y': "yy",
+	'm': "mm",
+	'B': "mmmm",
+	'b': "mmm",
+	'd': "dd",
+	'A': "dddd",
+	'a': "ddd",
+	'H': "hh",
+	'I': "hh",
+	'M': "mm",
+	'S': "ss",
+	'p': "AM/PM",
+	'%': "%",
+}
+
+// StrftimeToPattern converts a strftime-style format such as "%d/%m/%Y" into a Sheets pattern
+func StrftimeToPattern(format string) string {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] == '%' && i+1 < len(format) {
+			if token, ok := strftimeTokens[format[i+1]]; ok {
+				sb.WriteString(token)
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(format[i])
+	}
+	return sb.String()
+}
+
+// PatternToStrftime converts a Sheets date/time pattern into a strftime-style format.
+// As in Sheets, "mm" means minutes when it follows hours or precedes seconds and months otherwise.
+func PatternToStrftime(pattern string) string {
+	lower := strings.ToLower(pattern)
+	var sb strings.Builder
+	lastWasHour := false
+	for i := 0; i < len(lower); {
+		run := 1
+		for i+run < len(lower) && lower[i+run] == lower[i] {
+			run++
+		}
+
+		switch lower[i] {
+		case 'y':
+			if run >= 4 {
+				sb.WriteString("%Y")
+			} else {
+				sb.WriteString("%y")
+			}
+			lastWasHour = false
+		case 'd':
+			switch {
+			case run >= 4:
+				sb.WriteString("%A")
+			case run == 3:
+				sb.WriteString("%a")
+			default:
+				sb.WriteString("%d")
+			}
+			lastWasHour = false
+		case 'h':
+			sb.WriteString("%H")
+			lastWasHour = true
+		case 's':
+			sb.WriteString("%S")
+			lastWasHour = false
+		case 'm':
+			switch {
+			case run >= 4:
+				sb.WriteString("%B")
+			case run == 3:
+				sb.WriteString("%b")
+			case lastWasHour || strings.HasPrefix(strings.TrimLeft(lower[i+run:], ":. "), "s"):
+				sb.WriteString("%M")
+			default:
+				sb.WriteString("%m")
+			}
+			lastWasHour = false
+		default:
+			if strings.HasPrefix(lower[i:], "am/pm") {
+				sb.WriteString("%p")
+				i += len("am/pm")
+				continue
+			}
+			sb.WriteString(pattern[i : i+run])
+		}
+		i += run
+	}
+	return sb.String()
+}
+
+// patternLetters strips quoted literals, escaped characters and bracketed sections other than
+// elapsed-time markers from a pattern, returning the lowercase remainder.
+func patternLetters(pattern string) string {
+	lower := strings.ToLower(pattern)
+	var sb strings.Builder
+	for i := 0; i < len(lower); i++ {
+		switch lower[i] {
+		case '"':
+			end := strings.IndexByte(lower[i+1:], '"')
+			if end == -1 {
+				return sb.String()
+			}
+			i += end + 1
+		case '\\':
+			i++
+		case '[':
+			end := strings.IndexByte(lower[i:], ']')
+			if end == -1 {
+				return sb.String()
+			}
+			inner := lower[i+1 : i+end]
+			if strings.Trim(inner, "hms") == "" {
+				sb.WriteString(lower[i : i+end+1])
+			}
+			i += end
+		default:
+			sb.WriteByte(lower[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+package sheet
+
+import (
+	"testing"
+)
+
+func TestNormalizeType(t *testing.T) {
+	tests := []struct {
+		name       string
+		dataType   string
+		format     string
+		wantType   string
+		wantFormat string
+	}{
+		{"legacy datetime date", "datetime", "date", "date", ""},
+		{"legacy datetime time", "datetime", "time", "time", ""},
+		{"datetime default", "datetime", "default", "datetime", ""},
+		{"date any", "date", "any", "date", ""},
+		{"strftime matching default", "date", "%Y-%m-%d", "date", ""},
+		{"strftime custom", "date", "%d/%m/%Y", "date", "%d/%m/%Y"},
+		{"strftime minutes", "time", "%H:%M", "time", "%H:%M"},
+		{"string format untouched", "string", "email", "string", "email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, gotFormat := NormalizeType(tt.dataType, tt.format)
+			if gotType != tt.wantType || gotFormat != tt.wantFormat {
+				t.Errorf("NormalizeType(%q, %q) = (%q, %q), want (%q, %q)",
+					tt.dataType, tt.format, gotType, gotFormat, tt.wantType, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestNumberFormatFor(t *testing.T) {
+	tests := []struct {
+		dataType    string
+		format      string
+		wantType    string
+		wantPattern string
+	}{
+		{"integer", "", "NUMBER", "0"},
+		{"number", "", "NUMBER", "0.00"},
+		{"datetime", "default", "DATE_TIME", "yyyy-mm-dd hh:mm:ss"},
+		{"datetime", "date", "DATE", "yyyy-mm-dd"},
+		{"date", "", "DATE", "yyyy-mm-dd"},
+		{"date", "%d/%m/%Y", "DATE", "dd/mm/yyyy"},
+		{"time", "", "TIME", "hh:mm:ss"},
+		{"year", "", "NUMBER", "0000"},
+		{"yearmonth", "", "DATE", "yyyy-mm"},
+		{"duration", "", "TIME", "[h]:mm:ss"},
+		{"string", "", "TEXT", "@"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dataType+tt.format, func(t *testing.T) {
+			got := NumberFormatFor(tt.dataType, tt.format)
+			if got == nil {
+				t.Fatalf("NumberFormatFor(%q, %q) = nil", tt.dataType, tt.format)
+			}
+			if got.Type != tt.wantType || got.Pattern != tt.wantPattern {
+				t.Errorf("NumberFormatFor(%q, %q) = (%s, %s), want (%s, %s)",
+					tt.dataType, tt.format, got.Type, got.Pattern, tt.wantType, tt.wantPattern)
+			}
+		})
+	}
+
+	if got := NumberFormatFor("boolean", ""); got != nil {
+		t.Errorf("NumberFormatFor(boolean) = %+v, want nil", got)
+	}
+}
+
+func TestPatternToStrftime(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"yyyy-mm-dd", "%Y-%m-%d"},
+		{"dd/mm/yyyy hh:mm", "%d/%m/%Y %H:%M"},
+		{"mm:ss", "%M:%S"},
+		{"h:mm AM/PM", "%H:%M %p"},
+		{"mmmm yyyy", "%B %Y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := PatternToStrftime(tt.pattern); got != tt.want {
+				t.Errorf("PatternToStrftime(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFromPattern(t *testing.T) {
+	if got := FormatFromPattern("date", "yyyy-mm-dd"); got != "" {
+		t.Errorf("expected default date pattern to have no format, got %q", got)
+	}
+	if got := FormatFromPattern("date", "dd/mm/yyyy"); got != "%d/%m/%Y" {
+		t.Errorf("expected %%d/%%m/%%Y, got %q", got)
+	}
+	if got := FormatFromPattern("integer", "#,##0"); got != "" {
+		t.Errorf("expected no format for integer, got %q", got)
+	}
+}
+
+func TestNumberFormatWithOptions(t *testing.T) {
+	one := 1
+	three := 3
+	tests := []struct {
+		name        string
+		dataType    string
+		format      string
+		opts        FormatOptions
+		wantType    string
+		wantPattern string
+	}{
+		{"percent", "number", "percent", FormatOptions{}, "PERCENT", "0.00%"},
+		{"integer percent", "integer", "percent", FormatOptions{}, "PERCENT", "0%"},
+		{"currency JPY", "number", "currency:JPY", FormatOptions{}, "CURRENCY", `"¥"#,##0`},
+		{"currency USD", "number", "currency:USD", FormatOptions{}, "CURRENCY", `"$"#,##0.00`},
+		{"currency unknown code", "number", "currency:CHF", FormatOptions{}, "CURRENCY", `"CHF "#,##0.00`},
+		{"scientific", "number", "scientific", FormatOptions{}, "SCIENTIFIC", "0.00E+00"},
+		{"decimal places", "number", "", FormatOptions{DecimalPlaces: &three}, "NUMBER", "0.000"},
+		{"thousands separator", "number", "", FormatOptions{ThousandsSeparator: true, DecimalPlaces: &one}, "NUMBER", "#,##0.0"},
+		{"integer thousands", "integer", "", FormatOptions{ThousandsSeparator: true}, "NUMBER", "#,##0"},
+		{"raw pattern", "number", "", FormatOptions{Pattern: "0.0# \"kg\""}, "NUMBER", "0.0# \"kg\""},
+		{"raw pattern on date", "date", "", FormatOptions{Pattern: "d mmm yyyy"}, "DATE", "d mmm yyyy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NumberFormatWithOptions(tt.dataType, tt.format, tt.opts)
+			if got == nil {
+				t.Fatal("NumberFormatWithOptions() = nil")
+			}
+			if got.Type != tt.wantType || got.Pattern != tt.wantPattern {
+				t.Errorf("NumberFormatWithOptions() = (%s, %s), want (%s, %s)",
+					got.Type, got.Pattern, tt.wantType, tt.wantPattern)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package sheet
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -128,16 +129,29 @@ func InferTypeFromFormat(pattern string) string {
 		return "integer"
 	case "0.00", "#,##0.00", "0.0", "#,##0.0":
 		return "number"
+	case "0000":
+		return "year"
 	case "@":
 		return "string"
 	}
 
-	// Check for date/time patterns
-	lowerPattern := strings.ToLower(pattern)
-	if strings.Contains(lowerPattern, "yyyy") || strings.Contains(lowerPattern, "yy") ||
-		strings.Contains(lowerPattern, "mm") || strings.Contains(lowerPattern, "dd") ||
-		strings.Contains(lowerPattern, "hh") || strings.Contains(lowerPattern, "ss") {
+	// Check for elapsed time, date and time patterns
+	letters := patternLetters(pattern)
+	if strings.Contains(letters, "[h") || strings.Contains(letters, "[m") || strings.Contains(letters, "[s") {
+		return "duration"
+	}
+
+	hasDate := strings.ContainsAny(letters, "yd")
+	hasTime := strings.ContainsAny(letters, "hs")
+	switch {
+	case hasDate && hasTime:
 		return "datetime"
+	case strings.Contains(letters, "y") && strings.Contains(letters, "m") && !strings.Contains(letters, "d"):
+		return "yearmonth"
+	case hasDate:
+		return "date"
+	case hasTime:
+		return "time"
 	}
 
 	// Check for percentage
@@ -151,6 +165,14 @@ func InferTypeFromFormat(pattern string) string {
 		return "number"
 	}
 
+	// Check for other numeric patterns, such as scientific notation or quoted units
+	if strings.Contains(letters, "0") && strings.Trim(letters, "0#?,.e+- ") == "" {
+		if strings.ContainsAny(letters, ".e") {
+			return "number"
+		}
+		return "integer"
+	}
+
 	return ""
 }
 
@@ -160,6 +182,16 @@ func InferColumnType(data []any) string {
 		return "string"
 	}
 
+	// JSON objects are stored as text, so check for them before other types
+	if allJSONObjects(data) {
+		return "object"
+	}
+
+	// Values that are all of a single temporal kind take precedence
+	if temporalType := inferTemporalType(data); temporalType != "" {
+		return temporalType
+	}
+
 	hasNumber := false
 	hasString := false
 	hasBoolean := false
@@ -214,6 +246,71 @@ func InferColumnType(data []any) string {
 	return "string"
 }
 
+// temporalPatterns lists the value patterns that identify each temporal type other than datetime.
+// Durations are matched with the grammar ParseDuration accepts, so that inferred columns can be converted.
+var temporalPatterns = []struct {
+	dataType string
+	match    func(string) bool
+}{
+	{"date", regexp.MustCompile(`^(\d{4}-\d{1,2}-\d{1,2}|\d{4}/\d{1,2}/\d{1,2}|\d{1,2}/\d{1,2}/\d{4}|\d{1,2}-\d{1,2}-\d{4})$`).MatchString},
+	{"time", regexp.MustCompile(`^([01]?\d|2[0-3]):[0-5]\d(:[0-5]\d)?$`).MatchString},
+	{"yearmonth", regexp.MustCompile(`^\d{4}-(0?[1-9]|1[0-2])$`).MatchString},
+	{"duration", func(text string) bool {
+		return elapsedTimePattern.MatchString(text) || isISODuration(text)
+	}},
+}
+
+// elapsedTimePattern matches a duration written as h:mm:ss
+var elapsedTimePattern = regexp.MustCompile(`^\d+:[0-5]\d:[0-5]\d$`)
+
+// inferTemporalType returns the temporal type shared by every non-empty value, if any.
+// Durations written as h:mm:ss are only recognized when at least one exceeds 24 hours,
+// as they are otherwise indistinguishable from times of day.
+func inferTemporalType(data []any) string {
+	for _, candidate := range temporalPatterns {
+		matched := 0
+		allMatch := true
+		for _, val := range data {
+			if val == nil {
+				continue
+			}
+			strVal := strings.TrimSpace(fmt.Sprintf("%v", val))
+			if strVal == "" {
+				continue
+			}
+			if !candidate.match(strVal) {
+				allMatch = false
+				break
+			}
+			matched++
+		}
+		if allMatch && matched > 0 {
+			return candidate.dataType
+		}
+	}
+	return ""
+}
+
+// allJSONObjects reports whether every non-empty value is a JSON object
+func allJSONObjects(data []any) bool {
+	matched := 0
+	for _, val := range data {
+		if val == nil {
+			continue
+		}
+		strVal := strings.TrimSpace(fmt.Sprintf("%v", val))
+		if strVal == "" {
+			continue
+		}
+		var object map[string]any
+		if !strings.HasPrefix(strVal, "{") || json.Unmarshal([]byte(strVal), &object) != nil {
+			return false
+		}
+		matched++
+	}
+	return matched > 0
+}
+
 // isNumeric checks if a string represents a number
 func isNumeric(s string) bool {
 	if s == "" {
@@ -13,7 +13,7 @@ func TestInferColumnType_DateTime(t *testing.T) {
 		{
 			name: "ISO 8601 date",
 			data: []any{"2024-01-15", "2024-02-20", "2024-03-25"},
-			want: "datetime",
+			want: "date",
 		},
 		{
 			name: "ISO 8601 datetime",
@@ -28,7 +28,7 @@ func TestInferColumnType_DateTime(t *testing.T) {
 		{
 			name: "US format date",
 			data: []any{"01/15/2024", "02/20/2024", "03/25/2024"},
-			want: "datetime",
+			want: "date",
 		},
 		{
 			name: "RFC3339 with timezone",
@@ -38,13 +38,43 @@ func TestInferColumnType_DateTime(t *testing.T) {
 		{
 			name: "mixed datetime and nil",
 			data: []any{"2024-01-15", nil, "2024-03-25", nil},
-			want: "datetime",
+			want: "date",
 		},
 		{
 			name: "mixed datetime and other types",
 			data: []any{"2024-01-15", "not a date", "2024-03-25"},
 			want: "string",
 		},
+		{
+			name: "mixed date and datetime",
+			data: []any{"2024-01-15", "2024-02-20 14:45:00"},
+			want: "datetime",
+		},
+		{
+			name: "time of day",
+			data: []any{"10:30:00", "9:05", "23:59:59"},
+			want: "time",
+		},
+		{
+			name: "year and month",
+			data: []any{"2024-01", "2024-12", nil},
+			want: "yearmonth",
+		},
+		{
+			name: "elapsed hours",
+			data: []any{"10:30:00", "26:15:00"},
+			want: "duration",
+		},
+		{
+			name: "ISO 8601 duration",
+			data: []any{"P1DT2H", "PT30M", "P2W"},
+			want: "duration",
+		},
+		{
+			name: "calendar and empty ISO durations",
+			data: []any{"P1Y", "P", "PT", "P1M"},
+			want: "string",
+		},
 		{
 			name: "integer that looks like date",
 			data: []any{"20240115", "20240220", "20240325"},
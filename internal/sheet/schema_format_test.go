@@ -29,11 +29,21 @@ func TestInferTypeFromFormat(t *testing.T) {
 		{"text format", "@", "string"},
 		
 		// DateTime patterns
-		{"date ISO", "yyyy-mm-dd", "datetime"},
+		{"date ISO", "yyyy-mm-dd", "date"},
 		{"datetime full", "yyyy-mm-dd hh:mm:ss", "datetime"},
-		{"date US", "mm/dd/yyyy", "datetime"},
-		{"time only", "hh:mm:ss", "datetime"},
+		{"date US", "mm/dd/yyyy", "date"},
+		{"time only", "hh:mm:ss", "time"},
 		{"date with time", "dd/mm/yyyy hh:mm", "datetime"},
+		{"time with AM/PM", "h:mm AM/PM", "time"},
+		{"year month", "yyyy-mm", "yearmonth"},
+		{"month name and year", "mmmm yyyy", "yearmonth"},
+		{"year", "0000", "year"},
+		{"duration", "[h]:mm:ss", "duration"},
+		{"duration in minutes", "[mm]:ss", "duration"},
+		{"currency with locale", "[$€-407]#,##0.00", "number"},
+		{"quoted unit", `0.00" days"`, "number"},
+		{"scientific", "0.00E+00", "number"},
+		{"quoted currency code", `"CHF "#,##0`, "integer"},
 		
 		// Empty or unknown
 		{"empty pattern", "", ""},
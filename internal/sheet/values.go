@@ -0,0 +1,305 @@
+package sheet
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sheetsEpoch is day zero of Google Sheets serial date numbers
+var sheetsEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// YearMonth is the value of a `yearmonth` field
+type YearMonth struct {
+	Year  int
+	Month time.Month
+}
+
+func (ym YearMonth) String() string {
+	return fmt.Sprintf("%04d-%02d", ym.Year, int(ym.Month))
+}
+
+// ValueOptions holds the type options needed to convert values of composite types
+type ValueOptions struct {
+	Delimiter string // Separator between array items, "," by default
+	ItemType  string // Type of array items, "string" by default
+}
+
+// ConvertValue converts a cell value read from a sheet into the Go value for the field type.
+// Dates, times and durations are accepted either as formatted text or as Sheets serial numbers.
+// Blank cells convert to nil.
+func ConvertValue(dataType, format string, value any) (any, error) {
+	return ConvertValueWithOptions(dataType, format, ValueOptions{}, value)
+}
+
+// ConvertValueWithOptions converts a cell value like ConvertValue, using the options for
+// `array` fields (delimited text) and `object` fields (JSON text).
+func ConvertValueWithOptions(dataType, format string, opts ValueOptions, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if s, ok := value.(string); ok && strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	dataType, format = NormalizeType(dataType, format)
+	serial, isSerial := value.(float64)
+	text := strings.TrimSpace(fmt.Sprintf("%v", value))
+	if isSerial {
+		text = strconv.FormatFloat(serial, 'f', -1, 64)
+	}
+
+	switch dataType {
+	case "array":
+		delimiter := opts.Delimiter
+		if delimiter == "" {
+			delimiter = ","
+		}
+		itemType := opts.ItemType
+		if itemType == "" {
+			itemType = "string"
+		}
+
+		items := []any{}
+		for i, part := range strings.Split(text, delimiter) {
+			item := strings.TrimSpace(part)
+			if item == "" {
+				return nil, fmt.Errorf("item %d of %q is empty", i+1, text)
+			}
+			converted, err := ConvertValue(itemType, "", item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i+1, err)
+			}
+			items = append(items, converted)
+		}
+		return items, nil
+	case "object":
+		var object map[string]any
+		if err := json.Unmarshal([]byte(text), &object); err != nil || object == nil {
+			return nil, fmt.Errorf("%q is not a valid JSON object", text)
+		}
+		return object, nil
+	case "integer", "year":
+		n, err := strconv.ParseInt(strings.ReplaceAll(text, ",", ""), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid %s", text, dataType)
+		}
+		if dataType == "year" {
+			return int(n), nil
+		}
+		return n, nil
+	case "number":
+		n, err := strconv.ParseFloat(strings.ReplaceAll(text, ",", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid number", text)
+		}
+		return n, nil
+	case "boolean":
+		switch strings.ToLower(text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a valid boolean", text)
+	case "datetime", "date", "time", "yearmonth":
+		var t time.Time
+		if isSerial {
+			t = serialToTime(serial)
+		} else {
+			parsed, err := parseTemporal(dataType, format, text)
+			if err != nil {
+				return nil, err
+			}
+			t = parsed
+		}
+		switch dataType {
+		case "date":
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		case "time":
+			return time.Date(0, 1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), nil
+		case "yearmonth":
+			return YearMonth{Year: t.Year(), Month: t.Month()}, nil
+		}
+		return t, nil
+	case "duration":
+		if isSerial {
+			return time.Duration(serial * float64(24*time.Hour)), nil
+		}
+		d, err := ParseDuration(text)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	default:
+		return text, nil
+	}
+}
+
+// ExportValue converts a value returned by ConvertValueWithOptions into a JSON-friendly form.
+// Dates and times become ISO 8601 text and array items are exported according to the item type.
+func ExportValue(dataType, format string, opts ValueOptions, value any) any {
+	dataType, _ = NormalizeType(dataType, format)
+
+	switch v := value.(type) {
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = ExportValue(opts.ItemType, "", ValueOptions{}, item)
+		}
+		return items
+	case time.Time:
+		switch dataType {
+		case "date":
+			return v.Format("2006-01-02")
+		case "time":
+			return v.Format("15:04:05")
+		}
+		return v.Format(time.RFC3339)
+	case time.Duration:
+		return FormatISODuration(v)
+	case YearMonth:
+		return v.String()
+	}
+	return value
+}
+
+// FormatISODuration formats a duration as an ISO 8601 duration such as "PT26H30M"
+func FormatISODuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var sb strings.Builder
+	if d < 0 {
+		sb.WriteString("-")
+		d = -d
+	}
+	sb.WriteString("PT")
+	if hours := d / time.Hour; hours > 0 {
+		sb.WriteString(fmt.Sprintf("%dH", hours))
+		d -= hours * time.Hour
+	}
+	if minutes := d / time.Minute; minutes > 0 {
+		sb.WriteString(fmt.Sprintf("%dM", minutes))
+		d -= minutes * time.Minute
+	}
+	if d > 0 {
+		sb.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S")
+	}
+	return sb.String()
+}
+
+// serialToTime converts a Sheets serial date number into a time
+func serialToTime(serial float64) time.Time {
+	return sheetsEpoch.Add(time.Duration(serial * float64(24*time.Hour))).Round(time.Millisecond)
+}
+
+// temporalLayouts lists the text layouts accepted for each temporal type when no format is given
+var temporalLayouts = map[string][]string{
+	"datetime":  {time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006/01/02 15:04:05", "1/2/2006 15:04:05"},
+	"date":      {"2006-01-02", "2006/01/02", "1/2/2006", "01-02-2006"},
+	"time":      {"15:04:05", "15:04", "3:04:05 PM", "3:04 PM"},
+	"yearmonth": {"2006-01", "2006/01"},
+}
+
+// parseTemporal parses formatted text for a date or time type, using the strftime format when given
+func parseTemporal(dataType, format, text string) (time.Time, error) {
+	layouts := temporalLayouts[dataType]
+	if format != "" {
+		layouts = []string{strftimeToLayout(format)}
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a valid %s", text, dataType)
+}
+
+// layoutTokens maps strftime directives to Go time layout elements
+var layoutTokens = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'B': "January",
+	'b': "Jan",
+	'd': "02",
+	'A': "Monday",
+	'a': "Mon",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'p': "PM",
+	'%': "%",
+}
+
+// strftimeToLayout converts a strftime-style format into a Go time layout
+func strftimeToLayout(format string) string {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] == '%' && i+1 < len(format) {
+			if token, ok := layoutTokens[format[i+1]]; ok {
+				sb.WriteString(token)
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(format[i])
+	}
+	return sb.String()
+}
+
+var (
+	elapsedPattern     = regexp.MustCompile(`^(-)?(\d+):([0-5]\d)(?::([0-5]\d(?:\.\d+)?))?$`)
+	isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+)
+
+// isISODuration reports whether text is an ISO 8601 duration of weeks, days, hours, minutes and seconds
+// with at least one component, such as "P1DT2H" or "PT30M"
+func isISODuration(text string) bool {
+	return isoDurationPattern.MatchString(text) && text != "P" && !strings.HasSuffix(text, "T")
+}
+
+// ParseDuration parses an elapsed time such as "26:30:00" or an ISO 8601 duration such as "P1DT2H".
+// Calendar durations with years or months are rejected as they have no fixed length.
+func ParseDuration(text string) (time.Duration, error) {
+	if m := elapsedPattern.FindStringSubmatch(text); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		seconds := 0.0
+		if m[4] != "" {
+			seconds, _ = strconv.ParseFloat(m[4], 64)
+		}
+		d := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+			time.Duration(seconds*float64(time.Second))
+		if m[1] == "-" {
+			d = -d
+		}
+		return d, nil
+	}
+
+	if !isISODuration(text) {
+		return 0, fmt.Errorf("%q is not a valid duration", text)
+	}
+	m := isoDurationPattern.FindStringSubmatch(text)
+
+	var d time.Duration
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute}
+	for i, unit := range units {
+		if m[i+1] != "" {
+			n, _ := strconv.Atoi(m[i+1])
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[5] != "" {
+		seconds, _ := strconv.ParseFloat(m[5], 64)
+		d += time.Duration(seconds * float64(time.Second))
+	}
+	return d, nil
+}
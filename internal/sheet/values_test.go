@@ -0,0 +1,109 @@
+package sheet
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestConvertValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataType string
+		format   string
+		value    any
+		want     any
+		wantErr  bool
+	}{
+		{"integer", "integer", "", "1,234", int64(1234), false},
+		{"invalid integer", "integer", "", "12.5", nil, true},
+		{"number", "number", "", "3.14", 3.14, false},
+		{"boolean", "boolean", "", "TRUE", true, false},
+		{"blank", "date", "", "  ", nil, false},
+		{"date", "date", "", "2024-01-15", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), false},
+		{"date with format", "date", "%d/%m/%Y", "15/01/2024", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), false},
+		{"date serial", "date", "", 45306.0, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), false},
+		{"legacy datetime date", "datetime", "date", "2024-01-15", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), false},
+		{"invalid date", "date", "", "15.01.2024", nil, true},
+		{"time", "time", "", "10:30:15", time.Date(0, 1, 1, 10, 30, 15, 0, time.UTC), false},
+		{"time serial", "time", "", 0.5, time.Date(0, 1, 1, 12, 0, 0, 0, time.UTC), false},
+		{"datetime", "datetime", "", "2024-01-15 10:30:00", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), false},
+		{"year", "year", "", "2024", 2024, false},
+		{"yearmonth", "yearmonth", "", "2024-03", YearMonth{Year: 2024, Month: time.March}, false},
+		{"duration elapsed", "duration", "", "26:30:00", 26*time.Hour + 30*time.Minute, false},
+		{"duration serial", "duration", "", 1.5, 36 * time.Hour, false},
+		{"duration ISO", "duration", "", "P1DT2H30M", 26*time.Hour + 30*time.Minute, false},
+		{"calendar duration", "duration", "", "P1Y", nil, true},
+		{"string", "string", "", "hello", "hello", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertValue(tt.dataType, tt.format, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConvertValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ConvertValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertValueWithOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataType string
+		opts     ValueOptions
+		value    any
+		want     string
+		wantErr  bool
+	}{
+		{"default delimiter", "array", ValueOptions{}, "a, b ,c", `[a b c]`, false},
+		{"custom delimiter", "array", ValueOptions{Delimiter: ";"}, "a;b", `[a b]`, false},
+		{"integer items", "array", ValueOptions{ItemType: "integer"}, "1,2,3", `[1 2 3]`, false},
+		{"invalid item", "array", ValueOptions{ItemType: "integer"}, "1,two", "", true},
+		{"empty item", "array", ValueOptions{}, "a,,b", "", true},
+		{"object", "object", ValueOptions{}, `{"a": 1, "b": [true]}`, `map[a:1 b:[true]]`, false},
+		{"json array as object", "object", ValueOptions{}, `[1, 2]`, "", true},
+		{"invalid json", "object", ValueOptions{}, `{a: 1}`, "", true},
+		{"blank", "object", ValueOptions{}, "", "<nil>", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertValueWithOptions(tt.dataType, "", tt.opts, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConvertValueWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && fmt.Sprint(got) != tt.want {
+				t.Errorf("ConvertValueWithOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataType string
+		opts     ValueOptions
+		value    any
+		want     string
+	}{
+		{"date", "date", ValueOptions{}, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), "2024-01-15"},
+		{"time", "time", ValueOptions{}, time.Date(0, 1, 1, 9, 5, 0, 0, time.UTC), "09:05:00"},
+		{"datetime", "datetime", ValueOptions{}, time.Date(2024, 1, 15, 9, 5, 0, 0, time.UTC), "2024-01-15T09:05:00Z"},
+		{"duration", "duration", ValueOptions{}, 26*time.Hour + 30*time.Minute, "PT26H30M"},
+		{"yearmonth", "yearmonth", ValueOptions{}, YearMonth{Year: 2024, Month: time.March}, "2024-03"},
+		{"date items", "array", ValueOptions{ItemType: "date"}, []any{time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)}, "[2024-01-15]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fmt.Sprint(ExportValue(tt.dataType, "", tt.opts, tt.value)); got != tt.want {
+				t.Errorf("ExportValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}